@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"errors"
+	"net/http"
+
+	"wallet/app/oops"
+	"wallet/app/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler contains TrailService and a router.
+type Handler struct {
+	router *chi.Mux
+	trail  TrailService
+}
+
+// NewHandler is a constructor which accepts TrailService and
+// returns a pointer to the Handler.
+func NewHandler(router *chi.Mux, service TrailService) *Handler {
+	return &Handler{
+		router: router,
+		trail:  service,
+	}
+}
+
+// Register audit routes.
+func (h *Handler) Register() {
+	h.router.Group(func(r chi.Router) {
+		r.Get("/wallets/{id}/audit", h.audit)
+	})
+}
+
+func (h *Handler) audit(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, "")
+		return
+	}
+
+	trail, err := h.trail.Trail(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, oops.ErrNotFound):
+			response.WalletError(w, http.StatusNotFound, oops.ErrNotFoundMessage, id)
+		case errors.Is(err, oops.ErrBadHistory):
+			response.WalletError(w, http.StatusInternalServerError, oops.ErrBadHistoryMessage, id)
+		default:
+			response.WalletError(w, http.StatusInternalServerError, oops.ErrIntServMessage, id)
+		}
+		return
+	}
+
+	response.Data(w, http.StatusOK, trail)
+}
@@ -0,0 +1,38 @@
+package audit
+
+import (
+	"context"
+
+	"wallet/app/event"
+)
+
+// TrailService contains event.Store interface.
+type TrailService struct {
+	store event.Store
+}
+
+// NewTrailService is a TrailService constructor.
+func NewTrailService(store event.Store) *TrailService {
+	return &TrailService{
+		store: store,
+	}
+}
+
+// Trail replays the events of a wallet.
+func (s *TrailService) Trail(ctx context.Context, id string) (Trail, error) {
+	w, err := event.Load(ctx, s.store, id)
+	if err != nil {
+		return Trail{}, err
+	}
+
+	return Trail{
+		WalletID: w.ID,
+		Name:     w.Name,
+		Currency: w.Currency,
+		Deleted:  w.Deleted,
+		Balance:  w.Balance,
+		Held:     w.Held,
+		Version:  w.Version,
+		Steps:    w.Steps,
+	}, nil
+}
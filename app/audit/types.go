@@ -0,0 +1,20 @@
+// Package audit shows how a wallet reached its state
+// by replaying the wallet events.
+package audit
+
+import (
+	"wallet/app/event"
+	"wallet/app/money"
+)
+
+// Trail is a wallet rebuilt from its events with every step of it.
+type Trail struct {
+	WalletID string         `json:"wallet_id"`
+	Name     string         `json:"name"`
+	Currency money.Currency `json:"currency"`
+	Deleted  bool           `json:"deleted"`
+	Balance  money.Amount   `json:"balance"`
+	Held     money.Amount   `json:"held"`
+	Version  uint64         `json:"version"`
+	Steps    []event.Step   `json:"steps"`
+}
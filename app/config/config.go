@@ -0,0 +1,289 @@
+// Package config reads application settings from environment variables.
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/app/fx"
+)
+
+// Storage backends.
+const (
+	StorageMemory = "memory"
+	StorageBolt   = "bolt"
+)
+
+// Config contains application settings.
+type Config struct {
+	// Storage selects the storage backend: memory or bolt.
+	Storage string
+	// BoltPath is the database file of the bolt storage.
+	BoltPath string
+	// MemoryDir enables the write-ahead log and snapshots of
+	// the memory storage in the directory, empty means volatile.
+	MemoryDir string
+	// WALSync is the log fsync policy: always, batch or interval.
+	WALSync string
+	// WALBatch is the number of records per fsync for the batch policy.
+	WALBatch int
+	// WALSyncInterval is the fsync period for the interval policy.
+	WALSyncInterval time.Duration
+	// SnapshotInterval is how often the memory storage is snapshotted.
+	SnapshotInterval time.Duration
+	// FXRatesFile is a path to a JSON rate table,
+	// the static development table is used when empty.
+	FXRatesFile string
+	// FXQuoteTTL is how long an fx quote locks its rate.
+	FXQuoteTTL time.Duration
+	// IdempotencyTTL is how long a response is replayed for its Idempotency-Key.
+	IdempotencyTTL time.Duration
+	// HoldTTL is the lifetime of a hold placed without one and
+	// HoldMaxTTL the longest lifetime a client can ask for.
+	HoldTTL, HoldMaxTTL time.Duration
+	// HoldExpiryInterval is how often expired holds are released.
+	HoldExpiryInterval time.Duration
+	// LimitsFile is a path to a JSON table of the wallet limit tiers,
+	// the development table is used when empty.
+	LimitsFile string
+	// FeesFile is a path to a JSON list of the withdrawal and transfer
+	// fee rules, no fees are charged when empty.
+	FeesFile string
+	// OverdraftRate is the daily interest of negative balances in percent,
+	// nothing is charged when it is zero.
+	OverdraftRate fx.Rate
+	// OverdraftSchedule is the cron schedule of the overdraft interest
+	// job, a run charges the last whole UTC day once per wallet.
+	OverdraftSchedule string
+	// InterestFile is a path to JSON daily interest rates of positive
+	// balances by product, no interest is paid when empty.
+	InterestFile string
+	// InterestSchedule is the cron schedule of the interest job,
+	// a run pays the last whole UTC day once.
+	InterestSchedule string
+	// OutboxPollInterval is how often the outbox relay looks for events.
+	OutboxPollInterval time.Duration
+	// OutboxBatch is the number of events the relay reads at once.
+	OutboxBatch int
+	// OutboxMinBackoff and OutboxMaxBackoff bound the delay
+	// before the relay retries a failed event.
+	OutboxMinBackoff, OutboxMaxBackoff time.Duration
+	// PublishWorkers is the number of goroutines publishing events.
+	PublishWorkers int
+	// PublishBuffer is the queue capacity of a publishing worker.
+	PublishBuffer int
+	// PublishBatch is the largest number of events published at once.
+	PublishBatch int
+	// PublishFlushInterval is how long a worker waits to fill a batch.
+	PublishFlushInterval time.Duration
+	// OutboxJitter is the random fraction from 0 to 1 taken off a retry delay.
+	OutboxJitter float64
+	// OutboxMaxAttempts is the number of publish attempts before an
+	// event is dead-lettered, zero means retry forever.
+	OutboxMaxAttempts int
+	// DeadLetters is the capacity of the dead-letter store.
+	DeadLetters int
+	// Queue lists the publisher backends: nsq, bus, file or noop,
+	// events are published to all of them.
+	Queue []string
+	// NSQAddr is the nsqd TCP address.
+	NSQAddr string
+	// QueueFile is the JSON Lines file of the file backend.
+	QueueFile string
+	// Consumer selects the source of the events for the projections:
+	// nsq, bus or empty to disable the projections.
+	Consumer string
+	// NSQChannel is the nsq channel of the projections consumer.
+	NSQChannel string
+	// EventFormat is the queue message format: envelope or cloudevents.
+	EventFormat string
+	// EventSource is the CloudEvents source of the published events.
+	EventSource string
+}
+
+// Load reads Config from the environment and applies defaults.
+func Load() (Config, error) {
+	cfg := Config{
+		Storage:          env("WALLET_STORAGE", StorageMemory),
+		BoltPath:         env("WALLET_BOLT_PATH", "wallet.db"),
+		MemoryDir:        os.Getenv("WALLET_MEMORY_DIR"),
+		WALSync:          env("WALLET_WAL_SYNC", "always"),
+		WALBatch:         100,
+		WALSyncInterval:  time.Second,
+		SnapshotInterval: 5 * time.Minute,
+		FXRatesFile:      os.Getenv("WALLET_FX_RATES_FILE"),
+		FXQuoteTTL:       30 * time.Second,
+		IdempotencyTTL:   24 * time.Hour,
+
+		HoldTTL:            7 * 24 * time.Hour,
+		HoldMaxTTL:         30 * 24 * time.Hour,
+		HoldExpiryInterval: 10 * time.Second,
+		LimitsFile:         os.Getenv("WALLET_LIMITS_FILE"),
+		FeesFile:           os.Getenv("WALLET_FEES_FILE"),
+		OverdraftSchedule:  env("WALLET_OVERDRAFT_SCHEDULE", "@hourly"),
+		InterestFile:       os.Getenv("WALLET_INTEREST_FILE"),
+		InterestSchedule:   env("WALLET_INTEREST_SCHEDULE", "@hourly"),
+
+		OutboxPollInterval:   500 * time.Millisecond,
+		OutboxBatch:          100,
+		OutboxMinBackoff:     time.Second,
+		OutboxMaxBackoff:     time.Minute,
+		PublishWorkers:       4,
+		PublishBuffer:        256,
+		PublishBatch:         100,
+		PublishFlushInterval: 10 * time.Millisecond,
+		OutboxJitter:         0.2,
+		OutboxMaxAttempts:    10,
+		DeadLetters:          1000,
+
+		Queue:       strings.Split(env("WALLET_QUEUE", "nsq"), ","),
+		NSQAddr:     env("WALLET_NSQ_ADDR", "127.0.0.1:4150"),
+		QueueFile:   env("WALLET_QUEUE_FILE", "events.jsonl"),
+		Consumer:    os.Getenv("WALLET_CONSUMER"),
+		NSQChannel:  env("WALLET_NSQ_CHANNEL", "projections"),
+		EventFormat: env("WALLET_EVENT_FORMAT", "envelope"),
+		EventSource: env("WALLET_EVENT_SOURCE", "/wallet"),
+	}
+
+	var err error
+	if cfg.FXQuoteTTL, err = duration("WALLET_FX_QUOTE_TTL", cfg.FXQuoteTTL); err != nil {
+		return Config{}, err
+	}
+	if cfg.IdempotencyTTL, err = duration("WALLET_IDEMPOTENCY_TTL", cfg.IdempotencyTTL); err != nil {
+		return Config{}, err
+	}
+	if cfg.HoldTTL, err = duration("WALLET_HOLD_TTL", cfg.HoldTTL); err != nil {
+		return Config{}, err
+	}
+	if cfg.HoldMaxTTL, err = duration("WALLET_HOLD_MAX_TTL", cfg.HoldMaxTTL); err != nil {
+		return Config{}, err
+	}
+	if cfg.HoldExpiryInterval, err = duration("WALLET_HOLD_EXPIRY_INTERVAL", cfg.HoldExpiryInterval); err != nil {
+		return Config{}, err
+	}
+	if v := os.Getenv("WALLET_OVERDRAFT_RATE"); v != "" {
+		// the percent is turned into a fraction of the balance, so it has
+		// two decimal places less than a rate.
+		if cfg.OverdraftRate, err = fx.ParseRate(v); err != nil || cfg.OverdraftRate%100 != 0 {
+			return Config{}, fmt.Errorf("config WALLET_OVERDRAFT_RATE error: invalid percent %q", v)
+		}
+	}
+	if cfg.HoldTTL <= 0 || cfg.HoldTTL > cfg.HoldMaxTTL {
+		return Config{}, fmt.Errorf("config WALLET_HOLD_TTL error: %v is not between 0 and WALLET_HOLD_MAX_TTL %v", cfg.HoldTTL, cfg.HoldMaxTTL)
+	}
+	if cfg.HoldExpiryInterval <= 0 {
+		return Config{}, fmt.Errorf("config WALLET_HOLD_EXPIRY_INTERVAL must be positive")
+	}
+	if cfg.WALBatch, err = integer("WALLET_WAL_BATCH", cfg.WALBatch); err != nil {
+		return Config{}, err
+	}
+	if cfg.WALSyncInterval, err = duration("WALLET_WAL_SYNC_INTERVAL", cfg.WALSyncInterval); err != nil {
+		return Config{}, err
+	}
+	if cfg.SnapshotInterval, err = duration("WALLET_SNAPSHOT_INTERVAL", cfg.SnapshotInterval); err != nil {
+		return Config{}, err
+	}
+	if cfg.OutboxPollInterval, err = duration("WALLET_OUTBOX_POLL_INTERVAL", cfg.OutboxPollInterval); err != nil {
+		return Config{}, err
+	}
+	if cfg.OutboxBatch, err = integer("WALLET_OUTBOX_BATCH", cfg.OutboxBatch); err != nil {
+		return Config{}, err
+	}
+	if cfg.OutboxMinBackoff, err = duration("WALLET_OUTBOX_MIN_BACKOFF", cfg.OutboxMinBackoff); err != nil {
+		return Config{}, err
+	}
+	if cfg.OutboxMaxBackoff, err = duration("WALLET_OUTBOX_MAX_BACKOFF", cfg.OutboxMaxBackoff); err != nil {
+		return Config{}, err
+	}
+	if cfg.OutboxPollInterval <= 0 || cfg.OutboxBatch < 1 {
+		return Config{}, fmt.Errorf("config WALLET_OUTBOX_POLL_INTERVAL and WALLET_OUTBOX_BATCH must be positive")
+	}
+	if cfg.OutboxMinBackoff <= 0 || cfg.OutboxMaxBackoff < cfg.OutboxMinBackoff {
+		return Config{}, fmt.Errorf("config WALLET_OUTBOX_MIN_BACKOFF error: %v is not between 0 and WALLET_OUTBOX_MAX_BACKOFF %v", cfg.OutboxMinBackoff, cfg.OutboxMaxBackoff)
+	}
+	if cfg.PublishWorkers, err = integer("WALLET_PUBLISH_WORKERS", cfg.PublishWorkers); err != nil {
+		return Config{}, err
+	}
+	if cfg.PublishBuffer, err = integer("WALLET_PUBLISH_BUFFER", cfg.PublishBuffer); err != nil {
+		return Config{}, err
+	}
+	if cfg.PublishBatch, err = integer("WALLET_PUBLISH_BATCH", cfg.PublishBatch); err != nil {
+		return Config{}, err
+	}
+	if cfg.PublishFlushInterval, err = duration("WALLET_PUBLISH_FLUSH_INTERVAL", cfg.PublishFlushInterval); err != nil {
+		return Config{}, err
+	}
+	if cfg.PublishWorkers < 1 || cfg.PublishBatch < 1 {
+		return Config{}, fmt.Errorf("config WALLET_PUBLISH_WORKERS and WALLET_PUBLISH_BATCH must be positive")
+	}
+	if cfg.OutboxMaxAttempts, err = integer("WALLET_OUTBOX_MAX_ATTEMPTS", cfg.OutboxMaxAttempts); err != nil {
+		return Config{}, err
+	}
+	if cfg.DeadLetters, err = integer("WALLET_DEAD_LETTERS", cfg.DeadLetters); err != nil {
+		return Config{}, err
+	}
+	if cfg.DeadLetters < 1 {
+		return Config{}, fmt.Errorf("config WALLET_DEAD_LETTERS must be positive")
+	}
+	if v := os.Getenv("WALLET_OUTBOX_JITTER"); v != "" {
+		if cfg.OutboxJitter, err = strconv.ParseFloat(v, 64); err != nil {
+			return Config{}, fmt.Errorf("config WALLET_OUTBOX_JITTER error: %w", err)
+		}
+	}
+	if cfg.OutboxJitter < 0 || cfg.OutboxJitter > 1 {
+		return Config{}, fmt.Errorf("config WALLET_OUTBOX_JITTER error: %v is not between 0 and 1", cfg.OutboxJitter)
+	}
+
+	if cfg.Storage != StorageMemory && cfg.Storage != StorageBolt {
+		return Config{}, fmt.Errorf("config WALLET_STORAGE error: unknown storage %q", cfg.Storage)
+	}
+	if cfg.Consumer != "" && cfg.Consumer != "nsq" && cfg.Consumer != "bus" {
+		return Config{}, fmt.Errorf("config WALLET_CONSUMER error: unknown consumer %q", cfg.Consumer)
+	}
+	if cfg.EventFormat != "envelope" && cfg.EventFormat != "cloudevents" {
+		return Config{}, fmt.Errorf("config WALLET_EVENT_FORMAT error: unknown format %q", cfg.EventFormat)
+	}
+
+	return cfg, nil
+}
+
+// env returns a variable or def if it is unset.
+func env(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// duration parses a time.Duration variable or returns def if it is unset.
+func duration(key string, def time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("config %s error: %w", key, err)
+	}
+
+	return d, nil
+}
+
+// integer parses an int variable or returns def if it is unset.
+func integer(key string, def int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("config %s error: %w", key, err)
+	}
+
+	return n, nil
+}
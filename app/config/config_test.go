@@ -0,0 +1,35 @@
+package config
+
+import (
+	"testing"
+)
+
+func TestLoadRejects(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		env  map[string]string
+	}{
+		{"zero poll interval", map[string]string{"WALLET_OUTBOX_POLL_INTERVAL": "0s"}},
+		{"negative poll interval", map[string]string{"WALLET_OUTBOX_POLL_INTERVAL": "-1s"}},
+		{"zero batch", map[string]string{"WALLET_OUTBOX_BATCH": "0"}},
+		{"zero min backoff", map[string]string{"WALLET_OUTBOX_MIN_BACKOFF": "0s"}},
+		{"max below min backoff", map[string]string{"WALLET_OUTBOX_MIN_BACKOFF": "10s", "WALLET_OUTBOX_MAX_BACKOFF": "5s"}},
+		{"zero dead letters", map[string]string{"WALLET_DEAD_LETTERS": "0"}},
+		{"negative dead letters", map[string]string{"WALLET_DEAD_LETTERS": "-1"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			for key, v := range test.env {
+				t.Setenv(key, v)
+			}
+			if _, err := Load(); err == nil {
+				t.Errorf("Load() with %v succeeded, want an error", test.env)
+			}
+		})
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	if _, err := Load(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,387 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"wallet/app/fx"
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage"
+)
+
+// Store is the event log, the source of truth of event-sourced wallets.
+type Store interface {
+	// Events returns up to limit events after the event id in order.
+	Events(ctx context.Context, after string, limit int) ([]Event, error)
+	// Stream returns the events of an aggregate in order.
+	Stream(ctx context.Context, aggregateID string) ([]Event, error)
+}
+
+// Wallet is a wallet aggregate built only from its events,
+// Version is the sequence of the last applied event and
+// Held is the sum of the active holds. Limits override the
+// limits of the Tier. The balance may go down to minus CreditLimit,
+// Overdrawn is set while it is below zero and ChargedOn is the date
+// of the last overdraft interest charge. InterestRate is the daily
+// interest rate of the wallet in percent, zero takes the product rate.
+// Revisions are the names and statuses of the wallet by event time.
+type Wallet struct {
+	ID           string
+	Name         string
+	Currency     money.Currency
+	Deleted      bool
+	Balance      money.Amount
+	Held         money.Amount
+	Tier         string
+	Limits       storage.Limits
+	CreditLimit  money.Amount
+	Overdrawn    bool
+	ChargedOn    string
+	InterestRate fx.Rate
+	Revisions    []storage.Revision
+	Version      uint64
+	// Steps record how every event changed the wallet.
+	Steps []Step
+
+	// holds are the amounts of the active holds by id.
+	holds map[string]money.Amount
+}
+
+// Step is an applied event, Amount is the signed balance change.
+type Step struct {
+	Sequence     uint64       `json:"sequence"`
+	EventID      string       `json:"event_id"`
+	Type         string       `json:"type"`
+	Amount       money.Amount `json:"amount"`
+	Counterparty string       `json:"counterparty,omitempty"`
+	BalanceAfter money.Amount `json:"balance_after"`
+	HeldAfter    money.Amount `json:"held_after"`
+	Time         time.Time    `json:"time"`
+}
+
+// Load replays the stream of a wallet, it returns oops.ErrNotFound
+// for a wallet without events.
+func Load(ctx context.Context, store Store, id string) (*Wallet, error) {
+	events, err := store.Stream(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, oops.ErrNotFound
+	}
+
+	w := &Wallet{ID: id}
+	for _, e := range events {
+		if err = w.Apply(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return w, nil
+}
+
+// Apply changes the wallet by the next event of its stream, it returns
+// oops.ErrBadHistory if the event doesn't follow the current state.
+func (w *Wallet) Apply(e Event) error {
+	if e.AggregateID != w.ID {
+		return w.errorf(e, "aggregate %s", e.AggregateID)
+	}
+	if e.Sequence != w.Version+1 {
+		return w.errorf(e, "sequence %d follows %d", e.Sequence, w.Version)
+	}
+	if e.Type != WalletCreated && w.Version == 0 {
+		return w.errorf(e, "wallet isn't created")
+	}
+	if w.Deleted {
+		return w.errorf(e, "wallet is deleted")
+	}
+
+	step := Step{
+		Sequence: e.Sequence,
+		EventID:  e.ID,
+		Type:     e.Type,
+		Time:     e.Time,
+	}
+
+	var err error
+	switch e.Type {
+	case WalletCreated:
+		var p Created
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		if w.Version != 0 {
+			return w.errorf(e, "wallet is created twice")
+		}
+		w.Name, w.Currency = p.Name, p.Currency
+		w.revise(e)
+	case WalletRenamed:
+		var p Renamed
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		w.Name = p.Name
+		w.revise(e)
+	case WalletDeleted:
+		w.Deleted = true
+		w.revise(e)
+	case WalletLimitsChanged:
+		var p LimitsChanged
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		w.Tier = p.Tier
+		w.Limits = storage.Limits{
+			MaxWithdrawal:   p.MaxWithdrawal,
+			DailyOutgoing:   p.DailyOutgoing,
+			MonthlyOutgoing: p.MonthlyOutgoing,
+			HourlyTransfers: p.HourlyTransfers,
+			MaxBalance:      p.MaxBalance,
+		}
+	case WalletDeposited, WalletWithdrawn:
+		var p Operation
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+
+		step.Amount = p.Amount
+		if e.Type == WalletWithdrawn {
+			step.Amount = -p.Amount
+		}
+		if err = w.change(e, step.Amount, p.Currency, p.BalanceAfter); err != nil {
+			return err
+		}
+	case WalletTransferSent, WalletTransferReceived:
+		var p Transfer
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+
+		step.Amount, step.Counterparty = p.Amount, p.Counterparty
+		if e.Type == WalletTransferSent {
+			step.Amount = -p.Amount
+		}
+		if err = w.change(e, step.Amount, p.Currency, p.BalanceAfter); err != nil {
+			return err
+		}
+	case WalletHoldPlaced:
+		var p HoldPlaced
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		if err = w.place(e, p); err != nil {
+			return err
+		}
+	case WalletHoldCaptured:
+		var p HoldCaptured
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		if err = w.close(e, p.HoldID, p.Amount+p.Released); err != nil {
+			return err
+		}
+
+		step.Amount = -p.Amount
+		if err = w.change(e, step.Amount, p.Currency, p.BalanceAfter); err != nil {
+			return err
+		}
+	case WalletHoldReleased:
+		var p HoldReleased
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		if p.Reason != storage.HoldReleased && p.Reason != storage.HoldExpired {
+			return w.errorf(e, "hold %s release reason %s", p.HoldID, p.Reason)
+		}
+		if err = w.close(e, p.HoldID, p.Amount); err != nil {
+			return err
+		}
+	case TransactionReversed:
+		var p Reversed
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+
+		step.Amount, step.Counterparty = p.Amount, p.Counterparty
+		switch p.Type {
+		case storage.TxReversalIn:
+		case storage.TxReversalOut:
+			step.Amount = -p.Amount
+		default:
+			return w.errorf(e, "reversal type %s", p.Type)
+		}
+		if err = w.change(e, step.Amount, p.Currency, p.BalanceAfter); err != nil {
+			return err
+		}
+	case WalletFeeCharged:
+		var p FeeCharged
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+
+		step.Amount = -p.Amount
+		if err = w.change(e, step.Amount, p.Currency, p.BalanceAfter); err != nil {
+			return err
+		}
+	case WalletFeeCollected:
+		var p FeeCollected
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+
+		step.Amount, step.Counterparty = p.Amount, p.ChargedTo
+		if err = w.change(e, step.Amount, p.Currency, p.BalanceAfter); err != nil {
+			return err
+		}
+	case WalletCreditChanged:
+		var p CreditChanged
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		w.CreditLimit = p.CreditLimit
+	case WalletOverdraftStarted, WalletOverdraftEnded:
+		var p Overdraft
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		if err = w.overdraft(e, p); err != nil {
+			return err
+		}
+	case WalletOverdraftCharged:
+		var p OverdraftCharged
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		if p.Date <= w.ChargedOn {
+			return w.errorf(e, "overdraft of %s is charged after %s", p.Date, w.ChargedOn)
+		}
+
+		step.Amount = -p.Amount
+		if err = w.change(e, step.Amount, p.Currency, p.BalanceAfter); err != nil {
+			return err
+		}
+		w.ChargedOn = p.Date
+	case WalletInterestChanged:
+		var p InterestChanged
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+		w.InterestRate = p.Rate
+	case WalletInterestPaid:
+		var p InterestPaid
+		if err = decode(e, &p); err != nil {
+			return err
+		}
+
+		step.Amount = p.Amount
+		if err = w.change(e, step.Amount, p.Currency, p.BalanceAfter); err != nil {
+			return err
+		}
+	default:
+		return w.errorf(e, "unknown type %s", e.Type)
+	}
+
+	step.BalanceAfter, step.HeldAfter = w.Balance, w.Held
+	w.Steps = append(w.Steps, step)
+	w.Version = e.Sequence
+
+	return nil
+}
+
+// change applies a balance change and checks it
+// against the balance recorded in the event.
+func (w *Wallet) change(e Event, amount money.Amount, currency money.Currency, after money.Amount) error {
+	if currency != w.Currency {
+		return w.errorf(e, "currency %s of a %s wallet", currency, w.Currency)
+	}
+
+	w.Balance += amount
+	if w.Balance != after {
+		return w.errorf(e, "balance %s, recorded %s", w.Balance, after)
+	}
+
+	return nil
+}
+
+// Status returns the status of the wallet data, inactive once deleted.
+func (w *Wallet) Status() string {
+	if w.Deleted {
+		return "inactive"
+	}
+	return "active"
+}
+
+// revise records the name and status the event left the wallet with.
+func (w *Wallet) revise(e Event) {
+	w.Revisions = storage.Revise(w.Revisions, storage.Revision{
+		Name:   w.Name,
+		Status: w.Status(),
+		Time:   e.Time,
+	})
+}
+
+// overdraft starts or ends an overdraft at the balance recorded in the event.
+func (w *Wallet) overdraft(e Event, p Overdraft) error {
+	if p.Balance != w.Balance {
+		return w.errorf(e, "overdraft balance %s, current %s", p.Balance, w.Balance)
+	}
+
+	started := e.Type == WalletOverdraftStarted
+	if started == w.Overdrawn || started != (w.Balance < 0) {
+		return w.errorf(e, "overdraft %s at balance %s", e.Type, w.Balance)
+	}
+
+	w.Overdrawn = started
+	return nil
+}
+
+// place adds a hold, it can't reserve more than the available balance.
+func (w *Wallet) place(e Event, p HoldPlaced) error {
+	if p.Currency != w.Currency {
+		return w.errorf(e, "hold currency %s of a %s wallet", p.Currency, w.Currency)
+	}
+	if _, found := w.holds[p.HoldID]; found {
+		return w.errorf(e, "hold %s is placed twice", p.HoldID)
+	}
+	if available := w.Balance - w.Held + w.CreditLimit; p.Amount > available {
+		return w.errorf(e, "hold %s of %s exceeds available %s", p.HoldID, p.Amount, available)
+	}
+
+	if w.holds == nil {
+		w.holds = make(map[string]money.Amount)
+	}
+	w.holds[p.HoldID] = p.Amount
+	w.Held += p.Amount
+
+	return nil
+}
+
+// close removes an active hold of the amount.
+func (w *Wallet) close(e Event, id string, amount money.Amount) error {
+	held, found := w.holds[id]
+	if !found {
+		return w.errorf(e, "hold %s isn't active", id)
+	}
+	if held != amount {
+		return w.errorf(e, "hold %s of %s is closed with %s", id, held, amount)
+	}
+
+	delete(w.holds, id)
+	w.Held -= held
+
+	return nil
+}
+
+func (w *Wallet) errorf(e Event, format string, args ...any) error {
+	return fmt.Errorf("%w: wallet %s event %s: %s", oops.ErrBadHistory, w.ID, e.ID, fmt.Sprintf(format, args...))
+}
+
+func decode(e Event, payload any) error {
+	if err := json.Unmarshal(e.Payload, payload); err != nil {
+		return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+	return nil
+}
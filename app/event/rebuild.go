@@ -0,0 +1,771 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage"
+)
+
+// rebuildBatch is the number of events replayed in one unit of work.
+const rebuildBatch = 500
+
+// Report is the result of a rebuild, Mismatches lists
+// wallets whose stored balance differs from their events.
+type Report struct {
+	Events     int
+	Wallets    int
+	Mismatches []Mismatch
+}
+
+// Mismatch is a wallet balance which can't be reached by its events.
+type Mismatch struct {
+	WalletID        string
+	Stored, Rebuilt money.Amount
+}
+
+// Rebuild replays the event log of src into the empty dst: wallets,
+// the journal, the transaction log and the outbox are recreated only
+// from the events, with the ids and times recorded in src. It returns
+// oops.ErrBadHistory if the events contradict each other.
+func Rebuild(ctx context.Context, src, dst storage.DB) (Report, error) {
+	if err := dst.View(empty); err != nil {
+		return Report{}, err
+	}
+
+	r := &rebuild{
+		dst:     dst,
+		wallets: make(map[string]*Wallet),
+	}
+
+	var (
+		after  string
+		report Report
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return Report{}, err
+		}
+
+		var batch []storage.Event
+		err := src.View(func(tx storage.Tx) error {
+			var err error
+			batch, err = tx.Events(after, rebuildBatch)
+			return err
+		})
+		if err != nil {
+			return Report{}, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		if err = dst.Update(func(tx storage.Tx) error {
+			for _, e := range batch {
+				if err := r.replay(tx, e); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return Report{}, err
+		}
+
+		report.Events += len(batch)
+		after = batch[len(batch)-1].ID
+	}
+
+	if r.sent != nil {
+		return Report{}, fmt.Errorf("%w: event %s: transfer isn't received", oops.ErrBadHistory, r.sent.ID)
+	}
+	if r.reversed != nil {
+		return Report{}, fmt.Errorf("%w: event %s: reversal isn't completed", oops.ErrBadHistory, r.reversed.ID)
+	}
+	if r.charged != nil {
+		return Report{}, fmt.Errorf("%w: event %s: fee isn't collected", oops.ErrBadHistory, r.charged.ID)
+	}
+
+	if err := dst.View(storage.CheckJournal); err != nil {
+		return Report{}, err
+	}
+
+	err := src.View(func(tx storage.Tx) error {
+		var err error
+		report.Mismatches, err = r.compare(tx)
+		return err
+	})
+	if err != nil {
+		return Report{}, err
+	}
+
+	report.Wallets = len(r.wallets)
+	return report, nil
+}
+
+// rebuild is the state of a running Rebuild, sent holds the sent half
+// of a transfer until its received half follows, reversed holds the
+// reversal_out half of a reversed transfer until its reversal_in half
+// and charged holds a fee or an overdraft interest until the house
+// wallet collects it.
+type rebuild struct {
+	dst      storage.DB
+	wallets  map[string]*Wallet
+	sent     *storage.Event
+	reversed *storage.Event
+	charged  *storage.Event
+}
+
+// replay applies a source event to its aggregate and writes
+// the changes it describes to tx.
+func (r *rebuild) replay(tx storage.Tx, e storage.Event) error {
+	w, found := r.wallets[e.AggregateID]
+	if !found {
+		w = &Wallet{ID: e.AggregateID}
+		r.wallets[e.AggregateID] = w
+	}
+
+	if err := w.Apply(FromStorage(e)); err != nil {
+		return err
+	}
+
+	if r.sent != nil && e.Name != WalletTransferReceived {
+		return fmt.Errorf("%w: event %s: transfer isn't received", oops.ErrBadHistory, r.sent.ID)
+	}
+	if r.reversed != nil && e.Name != TransactionReversed {
+		return fmt.Errorf("%w: event %s: reversal isn't completed", oops.ErrBadHistory, r.reversed.ID)
+	}
+	if r.charged != nil && e.Name != WalletFeeCollected {
+		return fmt.Errorf("%w: event %s: fee isn't collected", oops.ErrBadHistory, r.charged.ID)
+	}
+
+	switch e.Name {
+	case WalletCreated, WalletRenamed, WalletDeleted, WalletLimitsChanged, WalletCreditChanged,
+		WalletInterestChanged:
+		if err := putWallet(tx, w); err != nil {
+			return err
+		}
+	case WalletHoldPlaced, WalletHoldCaptured, WalletHoldReleased:
+		if err := r.hold(tx, e, w); err != nil {
+			return err
+		}
+	case WalletDeposited, WalletWithdrawn:
+		if err := r.operation(tx, e, w); err != nil {
+			return err
+		}
+	case WalletTransferSent:
+		// both halves of a transfer are recorded in one unit of work,
+		// the sent one is written together with the received one.
+		r.sent = &e
+		return nil
+	case WalletTransferReceived:
+		if err := r.transfer(tx, e); err != nil {
+			return err
+		}
+		r.sent = nil
+		return nil
+	case TransactionReversed:
+		return r.reverse(tx, e)
+	case WalletFeeCharged:
+		// a fee is written together with its collection by the house
+		// wallet, only the fees into the house account are written alone.
+		held, err := r.fee(tx, e, w)
+		if err != nil || held {
+			return err
+		}
+	case WalletFeeCollected:
+		if err := r.collect(tx, e); err != nil {
+			return err
+		}
+		r.charged = nil
+		return nil
+	case WalletOverdraftCharged:
+		// the interest is written with its collection like a fee.
+		held, err := r.overdraft(tx, e, w)
+		if err != nil || held {
+			return err
+		}
+	case WalletInterestPaid:
+		if err := r.interest(tx, e, w); err != nil {
+			return err
+		}
+	}
+
+	return copyEvent(tx, e)
+}
+
+// operation writes a deposit or a withdrawal.
+func (r *rebuild) operation(tx storage.Tx, e storage.Event, w *Wallet) error {
+	var p Operation
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+
+	typ, cash, amount := storage.TxDeposit, storage.AccountCashIn, p.Amount
+	if e.Name == WalletWithdrawn {
+		typ, cash, amount = storage.TxWithdrawal, storage.AccountCashOut, -p.Amount
+	}
+
+	_, err := tx.Post(
+		storage.Entry{Account: storage.WalletAccount(w.ID, p.Currency), Amount: amount},
+		storage.Entry{Account: storage.Account{Name: cash, Currency: p.Currency}, Amount: -amount},
+	)
+	if err != nil {
+		return err
+	}
+
+	return copyTransaction(tx, e, p.TransactionID, storage.Transaction{
+		WalletID:     w.ID,
+		Type:         typ,
+		Amount:       p.Amount,
+		BalanceAfter: p.BalanceAfter,
+		Currency:     p.Currency,
+	})
+}
+
+// fee writes a fee taken from the wallet into the house fee account,
+// a fee into a house wallet is held until it is collected.
+func (r *rebuild) fee(tx storage.Tx, e storage.Event, w *Wallet) (held bool, err error) {
+	var p FeeCharged
+	if err = json.Unmarshal(e.Payload, &p); err != nil {
+		return false, fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+
+	if p.FeeWallet != "" {
+		r.charged = &e
+		return true, nil
+	}
+
+	_, err = tx.Post(
+		storage.Entry{Account: storage.WalletAccount(w.ID, p.Currency), Amount: -p.Amount},
+		storage.Entry{Account: storage.Account{Name: storage.AccountFees, Currency: p.Currency}, Amount: p.Amount},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return false, copyTransaction(tx, e, p.TransactionID, storage.Transaction{
+		WalletID:     w.ID,
+		Type:         storage.TxFee,
+		Amount:       p.Amount,
+		BalanceAfter: p.BalanceAfter,
+		Currency:     p.Currency,
+	})
+}
+
+// collect writes a fee taken from a wallet into the house fee wallet.
+func (r *rebuild) collect(tx storage.Tx, e storage.Event) error {
+	if r.charged == nil {
+		return fmt.Errorf("%w: event %s: fee isn't charged", oops.ErrBadHistory, e.ID)
+	}
+
+	charged, id, house, err := chargedTransaction(*r.charged)
+	if err != nil {
+		return err
+	}
+
+	var collected FeeCollected
+	if err = json.Unmarshal(e.Payload, &collected); err != nil {
+		return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+	if house != e.AggregateID || collected.ChargedTo != r.charged.AggregateID ||
+		charged.Amount != collected.Amount || charged.Currency != collected.Currency {
+		return fmt.Errorf("%w: events %s and %s aren't one fee", oops.ErrBadHistory, r.charged.ID, e.ID)
+	}
+
+	_, err = tx.Post(
+		storage.Entry{Account: storage.WalletAccount(charged.WalletID, charged.Currency), Amount: -charged.Amount},
+		storage.Entry{Account: storage.WalletAccount(collected.WalletID, collected.Currency), Amount: collected.Amount},
+	)
+	if err != nil {
+		return err
+	}
+
+	if err = copyTransaction(tx, *r.charged, id, charged); err != nil {
+		return err
+	}
+	// the overdraft interest keeps its date with the charged wallet.
+	if charged.Type == storage.TxOverdraftInterest {
+		if err = putWallet(tx, r.wallets[charged.WalletID]); err != nil {
+			return err
+		}
+	}
+
+	err = copyTransaction(tx, e, collected.TransactionID, storage.Transaction{
+		WalletID:     collected.WalletID,
+		Type:         storage.TxFeeIncome,
+		Counterparty: collected.ChargedTo,
+		Amount:       collected.Amount,
+		BalanceAfter: collected.BalanceAfter,
+		Currency:     collected.Currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = copyEvent(tx, *r.charged); err != nil {
+		return err
+	}
+	return copyEvent(tx, e)
+}
+
+// overdraft writes the overdraft interest taken from the wallet into
+// the house fee account with the date of the charge and its run, the
+// interest into a house wallet is held until it is collected.
+func (r *rebuild) overdraft(tx storage.Tx, e storage.Event, w *Wallet) (held bool, err error) {
+	var p OverdraftCharged
+	if err = json.Unmarshal(e.Payload, &p); err != nil {
+		return false, fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+	if err = tx.PutRun(storage.Run{Key: storage.OverdraftRun(p.Date), At: e.CreatedAt}); err != nil {
+		return false, err
+	}
+
+	if p.FeeWallet != "" {
+		r.charged = &e
+		return true, nil
+	}
+
+	_, err = tx.Post(
+		storage.Entry{Account: storage.WalletAccount(w.ID, p.Currency), Amount: -p.Amount},
+		storage.Entry{Account: storage.Account{Name: storage.AccountFees, Currency: p.Currency}, Amount: p.Amount},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	err = copyTransaction(tx, e, p.TransactionID, storage.Transaction{
+		WalletID:     w.ID,
+		Type:         storage.TxOverdraftInterest,
+		Amount:       p.Amount,
+		BalanceAfter: p.BalanceAfter,
+		Currency:     p.Currency,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return false, putWallet(tx, w)
+}
+
+// chargedTransaction returns the transaction of a held fee or overdraft
+// interest with its recorded id and the house wallet collecting it.
+func chargedTransaction(e storage.Event) (t storage.Transaction, id, house string, err error) {
+	if e.Name == WalletOverdraftCharged {
+		var p OverdraftCharged
+		if err = json.Unmarshal(e.Payload, &p); err != nil {
+			return storage.Transaction{}, "", "", fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+		}
+
+		return storage.Transaction{
+			WalletID:     p.WalletID,
+			Type:         storage.TxOverdraftInterest,
+			Amount:       p.Amount,
+			BalanceAfter: p.BalanceAfter,
+			Currency:     p.Currency,
+		}, p.TransactionID, p.FeeWallet, nil
+	}
+
+	var p FeeCharged
+	if err = json.Unmarshal(e.Payload, &p); err != nil {
+		return storage.Transaction{}, "", "", fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+
+	return storage.Transaction{
+		WalletID:     p.WalletID,
+		Type:         storage.TxFee,
+		Amount:       p.Amount,
+		BalanceAfter: p.BalanceAfter,
+		Currency:     p.Currency,
+	}, p.TransactionID, p.FeeWallet, nil
+}
+
+// interest writes the interest given to the wallet and the run of its
+// date, so the interest of the date isn't paid again. A run which paid
+// no wallet can't be restored from the events.
+func (r *rebuild) interest(tx storage.Tx, e storage.Event, w *Wallet) error {
+	var p InterestPaid
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+
+	_, err := tx.Post(
+		storage.Entry{Account: storage.WalletAccount(w.ID, p.Currency), Amount: p.Amount},
+		storage.Entry{Account: storage.Account{Name: storage.AccountInterest, Currency: p.Currency}, Amount: -p.Amount},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = copyTransaction(tx, e, p.TransactionID, storage.Transaction{
+		WalletID:     w.ID,
+		Type:         storage.TxInterest,
+		Amount:       p.Amount,
+		BalanceAfter: p.BalanceAfter,
+		Currency:     p.Currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.PutRun(storage.Run{Key: storage.InterestRun(p.Date), At: e.CreatedAt})
+}
+
+// hold writes a placed hold or closes it with a capture or a release.
+func (r *rebuild) hold(tx storage.Tx, e storage.Event, w *Wallet) error {
+	if e.Name == WalletHoldPlaced {
+		var p HoldPlaced
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+		}
+
+		h, err := tx.AppendHold(storage.Hold{
+			WalletID:  w.ID,
+			Status:    storage.HoldActive,
+			Amount:    p.Amount,
+			Currency:  p.Currency,
+			CreatedAt: e.CreatedAt,
+			ExpiresAt: p.ExpiresAt,
+		})
+		if err != nil {
+			return err
+		}
+		if h.ID != p.HoldID {
+			return fmt.Errorf("%w: event %s: hold %s rebuilt as %s", oops.ErrBadHistory, e.ID, p.HoldID, h.ID)
+		}
+
+		return putWallet(tx, w)
+	}
+
+	if e.Name == WalletHoldReleased {
+		var p HoldReleased
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+		}
+		return closeHold(tx, e, w, p.HoldID, func(h *storage.Hold) {
+			h.Status = p.Reason
+		})
+	}
+
+	var p HoldCaptured
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+
+	_, err := tx.Post(
+		storage.Entry{Account: storage.WalletAccount(w.ID, p.Currency), Amount: -p.Amount},
+		storage.Entry{Account: storage.Account{Name: storage.AccountCashOut, Currency: p.Currency}, Amount: p.Amount},
+	)
+	if err != nil {
+		return err
+	}
+
+	err = copyTransaction(tx, e, p.TransactionID, storage.Transaction{
+		WalletID:     w.ID,
+		Type:         storage.TxCapture,
+		Amount:       p.Amount,
+		BalanceAfter: p.BalanceAfter,
+		Currency:     p.Currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	return closeHold(tx, e, w, p.HoldID, func(h *storage.Hold) {
+		h.Status, h.Captured, h.TransactionID = storage.HoldCaptured, p.Amount, p.TransactionID
+	})
+}
+
+// closeHold stores a hold closed by the event, Apply has
+// already checked that the hold is active.
+func closeHold(tx storage.Tx, e storage.Event, w *Wallet, id string, close func(*storage.Hold)) error {
+	h, _, err := tx.Hold(id)
+	if err != nil {
+		return err
+	}
+
+	h.ClosedAt = e.CreatedAt
+	close(&h)
+	if err = tx.PutHold(h); err != nil {
+		return err
+	}
+
+	return putWallet(tx, w)
+}
+
+// transfer writes a transfer from the held sent event and
+// the received one, which must describe the same transfer.
+func (r *rebuild) transfer(tx storage.Tx, e storage.Event) error {
+	if r.sent == nil {
+		return fmt.Errorf("%w: event %s: transfer isn't sent", oops.ErrBadHistory, e.ID)
+	}
+
+	var out, in Transfer
+	if err := json.Unmarshal(r.sent.Payload, &out); err != nil {
+		return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, r.sent.ID, err.Error())
+	}
+	if err := json.Unmarshal(e.Payload, &in); err != nil {
+		return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+	if out.Counterparty != e.AggregateID || in.Counterparty != r.sent.AggregateID || out.Rate != in.Rate {
+		return fmt.Errorf("%w: events %s and %s aren't one transfer", oops.ErrBadHistory, r.sent.ID, e.ID)
+	}
+
+	entries := []storage.Entry{
+		{Account: storage.WalletAccount(out.WalletID, out.Currency), Amount: -out.Amount},
+		{Account: storage.WalletAccount(in.WalletID, in.Currency), Amount: in.Amount},
+	}
+	if out.Currency != in.Currency {
+		entries = append(entries,
+			storage.Entry{Account: storage.Account{Name: storage.AccountFX, Currency: out.Currency}, Amount: out.Amount},
+			storage.Entry{Account: storage.Account{Name: storage.AccountFX, Currency: in.Currency}, Amount: -in.Amount},
+		)
+	}
+	if _, err := tx.Post(entries...); err != nil {
+		return err
+	}
+
+	err := copyTransaction(tx, *r.sent, out.TransactionID, storage.Transaction{
+		WalletID:     out.WalletID,
+		Type:         storage.TxTransferOut,
+		Counterparty: out.Counterparty,
+		Amount:       out.Amount,
+		BalanceAfter: out.BalanceAfter,
+		Currency:     out.Currency,
+		Rate:         out.Rate,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = copyTransaction(tx, e, in.TransactionID, storage.Transaction{
+		WalletID:     in.WalletID,
+		Type:         storage.TxTransferIn,
+		Counterparty: in.Counterparty,
+		Amount:       in.Amount,
+		BalanceAfter: in.BalanceAfter,
+		Currency:     in.Currency,
+		Rate:         in.Rate,
+	})
+	if err != nil {
+		return err
+	}
+
+	if err = copyEvent(tx, *r.sent); err != nil {
+		return err
+	}
+	return copyEvent(tx, e)
+}
+
+// reverse writes a reversal of a deposit, a withdrawal or a capture.
+// Both halves of a reversed transfer are recorded in one unit of work,
+// the reversal_out one is written together with the reversal_in one.
+func (r *rebuild) reverse(tx storage.Tx, e storage.Event) error {
+	var p Reversed
+	if err := json.Unmarshal(e.Payload, &p); err != nil {
+		return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, e.ID, err.Error())
+	}
+
+	if p.Counterparty == "" {
+		if r.reversed != nil {
+			return fmt.Errorf("%w: event %s: reversal isn't completed", oops.ErrBadHistory, r.reversed.ID)
+		}
+
+		// a reversed deposit goes back to cash in and
+		// a reversed withdrawal or capture from cash out.
+		cash, amount := storage.AccountCashOut, p.Amount
+		if p.Type == storage.TxReversalOut {
+			cash, amount = storage.AccountCashIn, -p.Amount
+		}
+
+		_, err := tx.Post(
+			storage.Entry{Account: storage.WalletAccount(p.WalletID, p.Currency), Amount: amount},
+			storage.Entry{Account: storage.Account{Name: cash, Currency: p.Currency}, Amount: -amount},
+		)
+		if err != nil {
+			return err
+		}
+
+		if err = copyTransaction(tx, e, p.TransactionID, reversalTransaction(p)); err != nil {
+			return err
+		}
+		return copyEvent(tx, e)
+	}
+
+	if r.reversed == nil {
+		if p.Type != storage.TxReversalOut {
+			return fmt.Errorf("%w: event %s: reversal isn't started", oops.ErrBadHistory, e.ID)
+		}
+		r.reversed = &e
+		return nil
+	}
+
+	var out Reversed
+	if err := json.Unmarshal(r.reversed.Payload, &out); err != nil {
+		return fmt.Errorf("%w: event %s decode error: %s", oops.ErrBadHistory, r.reversed.ID, err.Error())
+	}
+	if p.Type != storage.TxReversalIn || out.Counterparty != e.AggregateID || p.Counterparty != r.reversed.AggregateID {
+		return fmt.Errorf("%w: events %s and %s aren't one reversal", oops.ErrBadHistory, r.reversed.ID, e.ID)
+	}
+
+	entries := []storage.Entry{
+		{Account: storage.WalletAccount(out.WalletID, out.Currency), Amount: -out.Amount},
+		{Account: storage.WalletAccount(p.WalletID, p.Currency), Amount: p.Amount},
+	}
+	if out.Currency != p.Currency {
+		entries = append(entries,
+			storage.Entry{Account: storage.Account{Name: storage.AccountFX, Currency: out.Currency}, Amount: out.Amount},
+			storage.Entry{Account: storage.Account{Name: storage.AccountFX, Currency: p.Currency}, Amount: -p.Amount},
+		)
+	}
+	if _, err := tx.Post(entries...); err != nil {
+		return err
+	}
+
+	if err := copyTransaction(tx, *r.reversed, out.TransactionID, reversalTransaction(out)); err != nil {
+		return err
+	}
+	if err := copyTransaction(tx, e, p.TransactionID, reversalTransaction(p)); err != nil {
+		return err
+	}
+
+	if err := copyEvent(tx, *r.reversed); err != nil {
+		return err
+	}
+	r.reversed = nil
+	return copyEvent(tx, e)
+}
+
+// compare returns the wallets of src whose balance differs from the rebuilt one.
+func (r *rebuild) compare(tx storage.Tx) ([]Mismatch, error) {
+	stored, err := tx.Wallets()
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []Mismatch
+	for id, sw := range stored {
+		balance, err := tx.Balance(storage.WalletAccount(id, sw.Currency))
+		if err != nil {
+			return nil, err
+		}
+
+		var rebuilt money.Amount
+		if w, found := r.wallets[id]; found {
+			rebuilt = w.Balance
+		}
+		if balance != rebuilt {
+			mismatches = append(mismatches, Mismatch{WalletID: id, Stored: balance, Rebuilt: rebuilt})
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].WalletID < mismatches[j].WalletID
+	})
+	return mismatches, nil
+}
+
+// putWallet stores the wallet data of the aggregate.
+func putWallet(tx storage.Tx, w *Wallet) error {
+	return tx.PutWallet(w.ID, storage.Wallet{
+		Name:         w.Name,
+		Status:       w.Status(),
+		Currency:     w.Currency,
+		Held:         w.Held,
+		Tier:         w.Tier,
+		Limits:       w.Limits,
+		CreditLimit:  w.CreditLimit,
+		ChargedOn:    w.ChargedOn,
+		InterestRate: w.InterestRate,
+		Revisions:    w.Revisions,
+	})
+}
+
+// reversalTransaction returns the transaction of a reversal event.
+func reversalTransaction(p Reversed) storage.Transaction {
+	return storage.Transaction{
+		WalletID:     p.WalletID,
+		Type:         p.Type,
+		Counterparty: p.Counterparty,
+		Amount:       p.Amount,
+		BalanceAfter: p.BalanceAfter,
+		Currency:     p.Currency,
+		Reverses:     p.Reverses,
+	}
+}
+
+// copyTransaction appends the transaction of the event
+// and checks that it gets the recorded id.
+func copyTransaction(tx storage.Tx, e storage.Event, id string, t storage.Transaction) error {
+	t.CreatedAt = e.CreatedAt
+
+	t, err := tx.AppendTransaction(t)
+	if err != nil {
+		return err
+	}
+	if t.ID != id {
+		return fmt.Errorf("%w: event %s: transaction %s rebuilt as %s", oops.ErrBadHistory, e.ID, id, t.ID)
+	}
+
+	return nil
+}
+
+// copyEvent appends the event to the outbox with its delivery
+// state and checks that it gets the recorded id and sequence.
+func copyEvent(tx storage.Tx, e storage.Event) error {
+	c, err := tx.AppendEvent(storage.Event{
+		AggregateID:   e.AggregateID,
+		Name:          e.Name,
+		Version:       e.Version,
+		CorrelationID: e.CorrelationID,
+		Payload:       e.Payload,
+		CreatedAt:     e.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	if c.ID != e.ID || c.Seq != e.Seq {
+		return fmt.Errorf("%w: event %s rebuilt as %s", oops.ErrBadHistory, e.ID, c.ID)
+	}
+
+	// a discarded event is published as a tombstone, which
+	// is delivered or dead-lettered like any event.
+	if e.Discarded {
+		if err = tx.DeadLetter(e.ID, e.Reason); err != nil {
+			return err
+		}
+		if err = tx.Discard(e.ID); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case e.Delivered:
+		return tx.MarkDelivered(e.ID)
+	case e.Dead:
+		return tx.DeadLetter(e.ID, e.Reason)
+	}
+
+	return nil
+}
+
+// empty returns an error if tx already contains data.
+func empty(tx storage.Tx) error {
+	wallets, err := tx.Wallets()
+	if err != nil {
+		return err
+	}
+
+	events, err := tx.Events("", 1)
+	if err != nil {
+		return err
+	}
+
+	if len(wallets) > 0 || len(events) > 0 {
+		return fmt.Errorf("rebuild target isn't empty")
+	}
+	return nil
+}
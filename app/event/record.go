@@ -0,0 +1,57 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"wallet/app/correlation"
+	"wallet/app/storage"
+)
+
+// Record appends an event with the payload to the outbox of tx,
+// the correlation id is taken from ctx.
+func Record(ctx context.Context, tx storage.Tx, aggregateID, typ string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("event %s encode error: %w", typ, err)
+	}
+
+	_, err = tx.AppendEvent(storage.Event{
+		AggregateID:   aggregateID,
+		Name:          typ,
+		Version:       Version,
+		CorrelationID: correlation.ID(ctx),
+		Payload:       data,
+	})
+	return err
+}
+
+// FromStorage converts an outbox record to Event.
+func FromStorage(e storage.Event) Event {
+	return Event{
+		ID:            e.ID,
+		Type:          e.Name,
+		Version:       e.Version,
+		AggregateID:   e.AggregateID,
+		Sequence:      e.Seq,
+		CorrelationID: e.CorrelationID,
+		Time:          e.CreatedAt,
+		Payload:       e.Payload,
+	}
+}
+
+// Tombstone returns the EventDiscarded published in place of a discarded
+// event, it keeps the sequence of the event so consumers of its aggregate
+// don't wait for the missing one.
+func Tombstone(e Event) Event {
+	payload, _ := json.Marshal(Discarded{
+		EventID: e.ID,
+		Type:    e.Type,
+	})
+
+	e.Type = EventDiscarded
+	e.Version = Version
+	e.Payload = payload
+	return e
+}
@@ -0,0 +1,64 @@
+// Package store contains all implementation to read
+// the event log from storage.DB.
+package store
+
+import (
+	"context"
+
+	"wallet/app/event"
+	"wallet/app/storage"
+)
+
+// Storage reads the recorded events from storage.DB.
+type Storage struct {
+	db storage.DB
+}
+
+// NewStorage is a constructor for storage.
+func NewStorage(db storage.DB) *Storage {
+	return &Storage{
+		db: db,
+	}
+}
+
+// Events returns up to limit recorded events after the event id in order.
+func (s *Storage) Events(ctx context.Context, after string, limit int) ([]event.Event, error) {
+	var events []event.Event
+
+	err := s.db.View(func(tx storage.Tx) error {
+		recorded, err := tx.Events(after, limit)
+		if err != nil {
+			return err
+		}
+
+		events = convert(recorded)
+		return nil
+	})
+
+	return events, err
+}
+
+// Stream returns the recorded events of an aggregate in order.
+func (s *Storage) Stream(ctx context.Context, aggregateID string) ([]event.Event, error) {
+	var events []event.Event
+
+	err := s.db.View(func(tx storage.Tx) error {
+		recorded, err := tx.Stream(aggregateID)
+		if err != nil {
+			return err
+		}
+
+		events = convert(recorded)
+		return nil
+	})
+
+	return events, err
+}
+
+func convert(recorded []storage.Event) []event.Event {
+	events := make([]event.Event, 0, len(recorded))
+	for _, e := range recorded {
+		events = append(events, event.FromStorage(e))
+	}
+	return events
+}
@@ -0,0 +1,263 @@
+// Package event contains the domain events recorded in the outbox
+// and published to the queue. A payload is versioned: a change that
+// breaks consumers needs a new Version and schema.
+package event
+
+import (
+	"encoding/json"
+	"time"
+
+	"wallet/app/fx"
+	"wallet/app/money"
+)
+
+// Version is the schema version of the payloads produced.
+const Version = 1
+
+// Event types.
+const (
+	WalletCreated          = "Wallet_Created"
+	WalletRenamed          = "Wallet_Renamed"
+	WalletDeleted          = "Wallet_Deleted"
+	WalletDeposited        = "Wallet_Deposited"
+	WalletWithdrawn        = "Wallet_Withdrawn"
+	WalletTransferSent     = "Wallet_TransferSent"
+	WalletTransferReceived = "Wallet_TransferReceived"
+	WalletHoldPlaced       = "Wallet_HoldPlaced"
+	WalletHoldCaptured     = "Wallet_HoldCaptured"
+	WalletHoldReleased     = "Wallet_HoldReleased"
+	TransactionReversed    = "Transaction_Reversed"
+	WalletLimitsChanged    = "Wallet_LimitsChanged"
+	WalletFeeCharged       = "Wallet_FeeCharged"
+	WalletFeeCollected     = "Wallet_FeeCollected"
+	WalletCreditChanged    = "Wallet_CreditChanged"
+	WalletOverdraftStarted = "Wallet_OverdraftStarted"
+	WalletOverdraftEnded   = "Wallet_OverdraftEnded"
+	WalletOverdraftCharged = "Wallet_OverdraftCharged"
+	WalletInterestChanged  = "Wallet_InterestChanged"
+	WalletInterestPaid     = "Wallet_InterestPaid"
+	EventDiscarded         = "Event_Discarded"
+)
+
+// Types lists all event types.
+var Types = []string{
+	WalletCreated,
+	WalletRenamed,
+	WalletDeleted,
+	WalletDeposited,
+	WalletWithdrawn,
+	WalletTransferSent,
+	WalletTransferReceived,
+	WalletHoldPlaced,
+	WalletHoldCaptured,
+	WalletHoldReleased,
+	TransactionReversed,
+	WalletLimitsChanged,
+	WalletFeeCharged,
+	WalletFeeCollected,
+	WalletCreditChanged,
+	WalletOverdraftStarted,
+	WalletOverdraftEnded,
+	WalletOverdraftCharged,
+	WalletInterestChanged,
+	WalletInterestPaid,
+	EventDiscarded,
+}
+
+// Event is a recorded domain event.
+type Event struct {
+	ID            string
+	Type          string
+	Version       int
+	AggregateID   string
+	Sequence      uint64
+	CorrelationID string
+	Time          time.Time
+	Payload       json.RawMessage
+}
+
+// Created is the payload of WalletCreated.
+type Created struct {
+	WalletID string         `json:"wallet_id"`
+	Name     string         `json:"name"`
+	Currency money.Currency `json:"currency"`
+}
+
+// Renamed is the payload of WalletRenamed.
+type Renamed struct {
+	WalletID string `json:"wallet_id"`
+	Name     string `json:"name"`
+}
+
+// Deleted is the payload of WalletDeleted.
+type Deleted struct {
+	WalletID string `json:"wallet_id"`
+}
+
+// LimitsChanged is the payload of WalletLimitsChanged, empty Tier is
+// the default tier and a zero limit is taken from the tier.
+type LimitsChanged struct {
+	WalletID        string       `json:"wallet_id"`
+	Tier            string       `json:"tier"`
+	MaxWithdrawal   money.Amount `json:"max_withdrawal"`
+	DailyOutgoing   money.Amount `json:"daily_outgoing"`
+	MonthlyOutgoing money.Amount `json:"monthly_outgoing"`
+	HourlyTransfers int          `json:"hourly_transfers"`
+	MaxBalance      money.Amount `json:"max_balance"`
+}
+
+// Operation is the payload of WalletDeposited and WalletWithdrawn.
+type Operation struct {
+	WalletID      string         `json:"wallet_id"`
+	TransactionID string         `json:"transaction_id"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	BalanceAfter  money.Amount   `json:"balance_after"`
+}
+
+// Transfer is the payload of WalletTransferSent and WalletTransferReceived,
+// Counterparty is the other wallet and Amount is in the wallet currency.
+type Transfer struct {
+	WalletID      string         `json:"wallet_id"`
+	TransactionID string         `json:"transaction_id"`
+	Counterparty  string         `json:"counterparty"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	BalanceAfter  money.Amount   `json:"balance_after"`
+	Rate          fx.Rate        `json:"rate"`
+}
+
+// HoldPlaced is the payload of WalletHoldPlaced.
+type HoldPlaced struct {
+	WalletID  string         `json:"wallet_id"`
+	HoldID    string         `json:"hold_id"`
+	Amount    money.Amount   `json:"amount"`
+	Currency  money.Currency `json:"currency"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// HoldCaptured is the payload of WalletHoldCaptured, Amount is taken
+// from the balance and Released is the rest of the hold given back.
+type HoldCaptured struct {
+	WalletID      string         `json:"wallet_id"`
+	HoldID        string         `json:"hold_id"`
+	TransactionID string         `json:"transaction_id"`
+	Amount        money.Amount   `json:"amount"`
+	Released      money.Amount   `json:"released"`
+	Currency      money.Currency `json:"currency"`
+	BalanceAfter  money.Amount   `json:"balance_after"`
+}
+
+// HoldReleased is the payload of WalletHoldReleased, Reason
+// is the status of the closed hold: released or expired.
+type HoldReleased struct {
+	WalletID string         `json:"wallet_id"`
+	HoldID   string         `json:"hold_id"`
+	Amount   money.Amount   `json:"amount"`
+	Currency money.Currency `json:"currency"`
+	Reason   string         `json:"reason"`
+}
+
+// Reversed is the payload of TransactionReversed, it is recorded for
+// every wallet whose balance a reversal changes. Type is the reversal
+// transaction type: Amount is taken from the wallet by reversal_out and
+// given back by reversal_in. Reverses is the reversed transaction and
+// Counterparty is the other wallet of a reversed transfer.
+type Reversed struct {
+	WalletID      string         `json:"wallet_id"`
+	TransactionID string         `json:"transaction_id"`
+	Reverses      string         `json:"reverses"`
+	Type          string         `json:"type"`
+	Counterparty  string         `json:"counterparty,omitempty"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	BalanceAfter  money.Amount   `json:"balance_after"`
+}
+
+// FeeCharged is the payload of WalletFeeCharged, Amount is taken from
+// the wallet into the house FeeWallet for the operation ChargedFor.
+// Fees recorded without FeeWallet went to the house fee account.
+type FeeCharged struct {
+	WalletID      string         `json:"wallet_id"`
+	TransactionID string         `json:"transaction_id"`
+	ChargedFor    string         `json:"charged_for"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	BalanceAfter  money.Amount   `json:"balance_after"`
+	FeeWallet     string         `json:"fee_wallet,omitempty"`
+}
+
+// FeeCollected is the payload of WalletFeeCollected, recorded for the
+// house fee wallet right after the WalletFeeCharged or the
+// WalletOverdraftCharged of the fee it gets from the ChargedTo wallet.
+type FeeCollected struct {
+	WalletID      string         `json:"wallet_id"`
+	TransactionID string         `json:"transaction_id"`
+	ChargedTo     string         `json:"charged_to"`
+	ChargedFor    string         `json:"charged_for"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	BalanceAfter  money.Amount   `json:"balance_after"`
+}
+
+// Discarded is the payload of EventDiscarded, it is published in place
+// of the dead-lettered event EventID of the type Type which was discarded.
+type Discarded struct {
+	EventID string `json:"event_id"`
+	Type    string `json:"type"`
+}
+
+// CreditChanged is the payload of WalletCreditChanged, the balance
+// of the wallet may go down to minus CreditLimit.
+type CreditChanged struct {
+	WalletID    string         `json:"wallet_id"`
+	CreditLimit money.Amount   `json:"credit_limit"`
+	Currency    money.Currency `json:"currency"`
+}
+
+// Overdraft is the payload of WalletOverdraftStarted, recorded when the
+// balance goes below zero, and WalletOverdraftEnded, recorded when it
+// is back at zero or above. Balance is the balance after the operation.
+type Overdraft struct {
+	WalletID    string         `json:"wallet_id"`
+	Balance     money.Amount   `json:"balance"`
+	CreditLimit money.Amount   `json:"credit_limit"`
+	Currency    money.Currency `json:"currency"`
+}
+
+// OverdraftCharged is the payload of WalletOverdraftCharged, Amount is
+// the interest of the day Date at the daily Rate in percent of the
+// Overdraft, taken from the wallet into the house FeeWallet. Interest
+// recorded without FeeWallet went to the house fee account.
+type OverdraftCharged struct {
+	WalletID      string         `json:"wallet_id"`
+	TransactionID string         `json:"transaction_id"`
+	Date          string         `json:"date"`
+	Overdraft     money.Amount   `json:"overdraft"`
+	Rate          fx.Rate        `json:"rate"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	BalanceAfter  money.Amount   `json:"balance_after"`
+	FeeWallet     string         `json:"fee_wallet,omitempty"`
+}
+
+// InterestChanged is the payload of WalletInterestChanged, Rate is the
+// daily interest of the wallet in percent, zero takes the product rate.
+type InterestChanged struct {
+	WalletID string  `json:"wallet_id"`
+	Rate     fx.Rate `json:"rate"`
+}
+
+// InterestPaid is the payload of WalletInterestPaid, Amount is the
+// interest of the day Date at the daily Rate in percent of the Balance,
+// given to the wallet from the interest account.
+type InterestPaid struct {
+	WalletID      string         `json:"wallet_id"`
+	TransactionID string         `json:"transaction_id"`
+	Date          string         `json:"date"`
+	Balance       money.Amount   `json:"balance"`
+	Rate          fx.Rate        `json:"rate"`
+	Amount        money.Amount   `json:"amount"`
+	Currency      money.Currency `json:"currency"`
+	BalanceAfter  money.Amount   `json:"balance_after"`
+}
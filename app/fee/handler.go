@@ -0,0 +1,60 @@
+package fee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"wallet/app/oops"
+	"wallet/app/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler contains Schedule and a router.
+type Handler struct {
+	router   *chi.Mux
+	schedule *Schedule
+}
+
+// NewHandler is a constructor which accepts Schedule and
+// returns a pointer to the Handler.
+func NewHandler(router *chi.Mux, schedule *Schedule) *Handler {
+	return &Handler{
+		router:   router,
+		schedule: schedule,
+	}
+}
+
+// Register fee routes.
+func (h *Handler) Register() {
+	h.router.Group(func(r chi.Router) {
+		r.Post("/fees/quote", h.quote)
+	})
+}
+
+func (h *Handler) quote(w http.ResponseWriter, r *http.Request) {
+	var requestBody QuoteRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, oops.ErrBadReqMessage)
+		return
+	}
+
+	fee, err := h.schedule.Quote(r.Context(), requestBody)
+	if err != nil {
+		switch {
+		case errors.Is(err, oops.ErrInvalidOperation):
+			response.Error(w, http.StatusBadRequest, oops.ErrInvalidOperationMessage)
+		case errors.Is(err, oops.ErrInvalidCurrency):
+			response.Error(w, http.StatusBadRequest, oops.ErrInvalidCurrencyMessage)
+		case errors.Is(err, oops.ErrInvalidAmount):
+			response.Error(w, http.StatusBadRequest, oops.ErrInvalidAmountMessage)
+		default:
+			response.Error(w, http.StatusInternalServerError, oops.ErrIntServMessage)
+		}
+		return
+	}
+
+	response.Data(w, http.StatusOK, fee)
+}
@@ -0,0 +1,189 @@
+package fee
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"wallet/app/fx"
+	"wallet/app/money"
+	"wallet/app/oops"
+)
+
+// Schedule computes fees from rules, an operation in a currency takes
+// the rule of the currency or the rule of all currencies.
+type Schedule struct {
+	rules map[key]Rule
+}
+
+type key struct {
+	operation string
+	currency  money.Currency
+}
+
+// NewSchedule is a constructor for Schedule, it validates the rules.
+func NewSchedule(rules []Rule) (*Schedule, error) {
+	s := &Schedule{
+		rules: make(map[key]Rule, len(rules)),
+	}
+
+	for i, r := range rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("%s/%d", r.Operation, i)
+		}
+		if err := r.validate(); err != nil {
+			return nil, fmt.Errorf("fee rule %q error: %w", r.Name, err)
+		}
+
+		k := key{r.Operation, r.Currency}
+		if _, found := s.rules[k]; found {
+			return nil, fmt.Errorf("fee rule %q error: duplicate %s rule of %q", r.Name, r.Operation, r.Currency)
+		}
+		s.rules[k] = r
+	}
+
+	return s, nil
+}
+
+// LoadSchedule reads JSON rules like [{"operation": "withdrawal",
+// "flat": 0.5, "percent": 1, "max": 10}], an empty path returns
+// a schedule without fees.
+func LoadSchedule(path string) (*Schedule, error) {
+	if path == "" {
+		return NewSchedule(nil)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("fee file read error: %w", err)
+	}
+
+	var rules []Rule
+	if err = json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("fee file decode error: %w", err)
+	}
+
+	return NewSchedule(rules)
+}
+
+// Currencies returns the currencies the schedule charges fees in,
+// all known ones if it has a rule of all currencies.
+func (s *Schedule) Currencies() []money.Currency {
+	seen := make(map[money.Currency]bool)
+	for k := range s.rules {
+		if k.currency == "" {
+			return money.Currencies()
+		}
+		seen[k.currency] = true
+	}
+
+	currencies := make([]money.Currency, 0, len(seen))
+	for c := range seen {
+		currencies = append(currencies, c)
+	}
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i] < currencies[j]
+	})
+	return currencies
+}
+
+// Quote returns the fee the operation amount would be charged.
+func (s *Schedule) Quote(ctx context.Context, req QuoteRequest) (Fee, error) {
+	if req.Operation != Withdrawal && req.Operation != Transfer {
+		return Fee{}, oops.ErrInvalidOperation
+	}
+
+	currency, err := money.ParseCurrency(string(req.Currency))
+	if err != nil {
+		return Fee{}, err
+	}
+
+	if err = currency.Check(req.Amount); err != nil {
+		return Fee{}, err
+	}
+
+	return s.Fee(req.Operation, req.Amount, currency)
+}
+
+// Fee computes the fee of the operation amount in the currency.
+func (s *Schedule) Fee(operation string, amount money.Amount, currency money.Currency) (Fee, error) {
+	fee := Fee{
+		Operation: operation,
+		Currency:  currency,
+	}
+
+	r, found := s.rules[key{operation, currency}]
+	if !found {
+		r, found = s.rules[key{operation, ""}]
+	}
+	if !found {
+		return fee, nil
+	}
+
+	flat, percent := r.Flat, r.Percent
+	for _, t := range r.Tiers {
+		if t.UpTo == 0 || amount <= t.UpTo {
+			flat, percent = t.Flat, t.Percent
+			break
+		}
+	}
+
+	fee.Rule = r.Name
+	fee.Flat = flat
+	fee.Variable = (percent / 100).Convert(amount, currency)
+	fee.Amount = fee.Flat + fee.Variable
+
+	switch {
+	case r.Min != 0 && fee.Amount < r.Min:
+		fee.Adjustment = r.Min - fee.Amount
+	case r.Max != 0 && fee.Amount > r.Max:
+		fee.Adjustment = r.Max - fee.Amount
+	}
+	fee.Amount += fee.Adjustment
+
+	// amounts of a rule of all currencies may be too precise for this one.
+	if !fee.Amount.Fits(currency.Precision()) {
+		return Fee{}, fmt.Errorf("fee rule %q error: fee %s doesn't fit %s", r.Name, fee.Amount, currency)
+	}
+
+	return fee, nil
+}
+
+// validate checks the rule is consistent, percents keep their
+// precision as fractions of the amount.
+func (r Rule) validate() error {
+	if r.Operation != Withdrawal && r.Operation != Transfer {
+		return fmt.Errorf("unknown operation %q", r.Operation)
+	}
+	if r.Currency != "" {
+		if _, err := money.ParseCurrency(string(r.Currency)); err != nil {
+			return err
+		}
+	}
+	if !valid(r.Flat, r.Percent) || r.Min < 0 || r.Max < 0 {
+		return fmt.Errorf("negative or too precise fee")
+	}
+	if r.Max != 0 && r.Min > r.Max {
+		return fmt.Errorf("min %s exceeds max %s", r.Min, r.Max)
+	}
+
+	for i, t := range r.Tiers {
+		if !valid(t.Flat, t.Percent) || t.UpTo < 0 {
+			return fmt.Errorf("tier %d: negative or too precise fee", i)
+		}
+		if t.UpTo == 0 && i != len(r.Tiers)-1 {
+			return fmt.Errorf("tier %d: only the last tier may be unbounded", i)
+		}
+		if i > 0 && t.UpTo != 0 && t.UpTo <= r.Tiers[i-1].UpTo {
+			return fmt.Errorf("tier %d: tiers aren't ascending", i)
+		}
+	}
+
+	return nil
+}
+
+func valid(flat money.Amount, percent fx.Rate) bool {
+	return flat >= 0 && percent >= 0 && percent%100 == 0
+}
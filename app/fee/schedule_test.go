@@ -0,0 +1,39 @@
+package fee
+
+import (
+	"reflect"
+	"testing"
+
+	"wallet/app/money"
+)
+
+func TestCurrencies(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		want  []money.Currency
+	}{
+		{name: "no fees", want: []money.Currency{}},
+		{name: "own rules", rules: []Rule{
+			{Operation: Withdrawal, Currency: "USD", Flat: 1},
+			{Operation: Transfer, Currency: "EUR", Flat: 1},
+			{Operation: Transfer, Currency: "USD", Flat: 1},
+		}, want: []money.Currency{"EUR", "USD"}},
+		{name: "rule of all currencies", rules: []Rule{
+			{Operation: Withdrawal, Currency: "USD", Flat: 1},
+			{Operation: Transfer, Flat: 1},
+		}, want: money.Currencies()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSchedule(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Currencies(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Currencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
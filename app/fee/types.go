@@ -0,0 +1,58 @@
+// Package fee computes the fees of withdrawals and transfers
+// from a configured set of rules.
+package fee
+
+import (
+	"wallet/app/fx"
+	"wallet/app/money"
+)
+
+// Operations with fees.
+const (
+	Withdrawal = "withdrawal"
+	Transfer   = "transfer"
+)
+
+// Rule is the fee of an operation in a currency, empty Currency matches
+// the currencies without a rule of their own. The fee is Flat plus Percent
+// of the amount, or those of the first of Tiers covering the amount,
+// bounded by Min and Max. Amounts are in the operation currency and a
+// zero Min or Max is not set.
+type Rule struct {
+	Name      string         `json:"name,omitempty"`
+	Operation string         `json:"operation"`
+	Currency  money.Currency `json:"currency,omitempty"`
+	Flat      money.Amount   `json:"flat,omitempty"`
+	Percent   fx.Rate        `json:"percent,omitempty"`
+	Tiers     []Tier         `json:"tiers,omitempty"`
+	Min       money.Amount   `json:"min,omitempty"`
+	Max       money.Amount   `json:"max,omitempty"`
+}
+
+// Tier is the fee of the amounts up to UpTo inclusive,
+// zero UpTo covers all amounts above the previous tier.
+type Tier struct {
+	UpTo    money.Amount `json:"up_to,omitempty"`
+	Flat    money.Amount `json:"flat,omitempty"`
+	Percent fx.Rate      `json:"percent,omitempty"`
+}
+
+// Fee is the fee of an operation amount: Flat plus the Variable percentage
+// part with the Adjustment of the Min and Max bounds is Amount. Rule is
+// the name of the applied rule, a fee without a rule is zero.
+type Fee struct {
+	Operation  string         `json:"operation"`
+	Amount     money.Amount   `json:"amount"`
+	Currency   money.Currency `json:"currency"`
+	Flat       money.Amount   `json:"flat"`
+	Variable   money.Amount   `json:"variable"`
+	Adjustment money.Amount   `json:"adjustment"`
+	Rule       string         `json:"rule,omitempty"`
+}
+
+// QuoteRequest contains fields for client request.
+type QuoteRequest struct {
+	Operation string         `json:"operation"`
+	Amount    money.Amount   `json:"amount"`
+	Currency  money.Currency `json:"currency"`
+}
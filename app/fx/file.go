@@ -0,0 +1,79 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"wallet/app/money"
+)
+
+// File is a Provider which reads a JSON rate table like
+// {"EUR/USD": "1.08"} and reloads it when the file changes.
+type File struct {
+	path    string
+	modTime time.Time
+	rates   map[Pair]Rate
+	sync.Mutex
+}
+
+// NewFile is a constructor for File provider, it loads the table once
+// to fail fast on a missing or malformed file.
+func NewFile(path string) (*File, error) {
+	f := &File{
+		path: path,
+	}
+
+	if err := f.reload(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Rate returns a rate for the pair from the latest version of the file.
+func (f *File) Rate(ctx context.Context, from, to money.Currency) (Rate, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	if err := f.reload(); err != nil {
+		return 0, err
+	}
+
+	return lookup(f.rates, from, to)
+}
+
+// reload reads the file if it was modified since the last read.
+func (f *File) reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return fmt.Errorf("fx file stat error: %w", err)
+	}
+
+	if f.rates != nil && info.ModTime().Equal(f.modTime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("fx file read error: %w", err)
+	}
+
+	var table map[string]string
+	if err = json.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("fx file decode error: %w", err)
+	}
+
+	rates, err := parseTable(table)
+	if err != nil {
+		return err
+	}
+
+	f.rates = rates
+	f.modTime = info.ModTime()
+
+	return nil
+}
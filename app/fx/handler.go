@@ -0,0 +1,58 @@
+package fx
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"wallet/app/oops"
+	"wallet/app/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler contains QuoteService and a router.
+type Handler struct {
+	router *chi.Mux
+	quotes *QuoteService
+}
+
+// NewHandler is a constructor which accepts QuoteService and
+// returns a pointer to the Handler.
+func NewHandler(router *chi.Mux, service *QuoteService) *Handler {
+	return &Handler{
+		router: router,
+		quotes: service,
+	}
+}
+
+// Register fx routes.
+func (h *Handler) Register() {
+	h.router.Group(func(r chi.Router) {
+		r.Post("/fx/quotes", h.quote)
+	})
+}
+
+func (h *Handler) quote(w http.ResponseWriter, r *http.Request) {
+	var requestBody QuoteRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		response.Error(w, http.StatusBadRequest, oops.ErrBadReqMessage)
+		return
+	}
+
+	quote, err := h.quotes.Quote(r.Context(), requestBody)
+	if err != nil {
+		switch {
+		case errors.Is(err, oops.ErrInvalidCurrency):
+			response.Error(w, http.StatusBadRequest, oops.ErrInvalidCurrencyMessage)
+		case errors.Is(err, oops.ErrRateNotFound):
+			response.Error(w, http.StatusUnprocessableEntity, oops.ErrRateNotFoundMessage)
+		default:
+			response.Error(w, http.StatusInternalServerError, oops.ErrIntServMessage)
+		}
+		return
+	}
+
+	response.Data(w, http.StatusCreated, quote)
+}
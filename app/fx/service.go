@@ -0,0 +1,99 @@
+package fx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+)
+
+// QuoteService locks provider rates for a short TTL.
+type QuoteService struct {
+	provider Provider
+	ttl      time.Duration
+	quotes   map[string]Quote
+	sync.Mutex
+}
+
+// NewQuoteService is a constructor for QuoteService.
+func NewQuoteService(provider Provider, ttl time.Duration) *QuoteService {
+	return &QuoteService{
+		provider: provider,
+		ttl:      ttl,
+		quotes:   make(map[string]Quote),
+	}
+}
+
+// Quote fetches the current rate for the pair and locks it.
+func (s *QuoteService) Quote(ctx context.Context, req QuoteRequest) (Quote, error) {
+	pair, err := parsePair(string(req.From), string(req.To))
+	if err != nil {
+		return Quote{}, err
+	}
+
+	rate, err := s.provider.Rate(ctx, pair.From, pair.To)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	id, err := generateID()
+	if err != nil {
+		return Quote{}, err
+	}
+
+	now := time.Now().UTC()
+	quote := Quote{
+		ID:        id,
+		From:      pair.From,
+		To:        pair.To,
+		Rate:      rate,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// drop expired quotes so the map doesn't grow forever.
+	for k, q := range s.quotes {
+		if now.After(q.ExpiresAt) {
+			delete(s.quotes, k)
+		}
+	}
+	s.quotes[id] = quote
+
+	return quote, nil
+}
+
+// Rate returns the locked rate of the quote if quoteID is set,
+// otherwise the current rate from the provider.
+func (s *QuoteService) Rate(ctx context.Context, quoteID string, from, to money.Currency) (Rate, error) {
+	if quoteID == "" {
+		return s.provider.Rate(ctx, from, to)
+	}
+
+	s.Lock()
+	quote, found := s.quotes[quoteID]
+	s.Unlock()
+
+	if !found || time.Now().After(quote.ExpiresAt) {
+		return 0, oops.ErrQuoteNotFound
+	}
+
+	if quote.From != from || quote.To != to {
+		return 0, oops.ErrCurrencyMismatch
+	}
+
+	return quote.Rate, nil
+}
+
+func generateID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
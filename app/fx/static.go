@@ -0,0 +1,103 @@
+package fx
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+)
+
+// defaultRates is a rate table for local development.
+var defaultRates = map[string]string{
+	"EUR/USD": "1.08",
+	"GBP/USD": "1.27",
+	"USD/CHF": "0.88",
+	"USD/JPY": "149.5",
+	"USD/KZT": "470",
+	"EUR/GBP": "0.85",
+}
+
+// Static is a Provider with a fixed rate table.
+type Static struct {
+	rates map[Pair]Rate
+}
+
+// NewStatic is a constructor for Static provider. Keys of the
+// table are pairs in "FROM/TO" form and values are decimal rates.
+// An empty table falls back to the development rates.
+func NewStatic(table map[string]string) (*Static, error) {
+	if len(table) == 0 {
+		table = defaultRates
+	}
+
+	rates, err := parseTable(table)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Static{
+		rates: rates,
+	}, nil
+}
+
+// Rate returns a rate for the pair, inverse pairs are derived
+// from the table when only the opposite direction is known.
+func (s *Static) Rate(ctx context.Context, from, to money.Currency) (Rate, error) {
+	return lookup(s.rates, from, to)
+}
+
+func lookup(rates map[Pair]Rate, from, to money.Currency) (Rate, error) {
+	if from == to {
+		return One, nil
+	}
+
+	if r, ok := rates[Pair{From: from, To: to}]; ok {
+		return r, nil
+	}
+
+	if r, ok := rates[Pair{From: to, To: from}]; ok {
+		return r.Inverse(), nil
+	}
+
+	return 0, oops.ErrRateNotFound
+}
+
+func parseTable(table map[string]string) (map[Pair]Rate, error) {
+	rates := make(map[Pair]Rate, len(table))
+	for k, v := range table {
+		from, to, ok := strings.Cut(k, "/")
+		if !ok {
+			return nil, fmt.Errorf("invalid currency pair %q", k)
+		}
+
+		pair, err := parsePair(from, to)
+		if err != nil {
+			return nil, fmt.Errorf("invalid currency pair %q: %w", k, err)
+		}
+
+		rate, err := ParseRate(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate for %q: %w", k, err)
+		}
+
+		rates[pair] = rate
+	}
+
+	return rates, nil
+}
+
+func parsePair(from, to string) (Pair, error) {
+	f, err := money.ParseCurrency(from)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	t, err := money.ParseCurrency(to)
+	if err != nil {
+		return Pair{}, err
+	}
+
+	return Pair{From: f, To: t}, nil
+}
@@ -0,0 +1,151 @@
+// Package fx contains foreign exchange rates and quotes
+// used by cross-currency transfers.
+package fx
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+)
+
+const (
+	// RateScale is the number of decimal places kept by Rate.
+	RateScale = 8
+
+	rateUnit = 100000000 // 10^RateScale
+)
+
+// Rate is an exchange rate stored as an integer number of 10^-RateScale units.
+type Rate int64
+
+// One is the identity rate used for same-currency transfers.
+const One Rate = rateUnit
+
+// Pair is a currency pair, Rate for a Pair converts From into To.
+type Pair struct {
+	From, To money.Currency
+}
+
+// String returns the pair in "FROM/TO" form.
+func (p Pair) String() string {
+	return string(p.From) + "/" + string(p.To)
+}
+
+// Quote is an exchange rate locked until ExpiresAt.
+type Quote struct {
+	ID        string         `json:"id"`
+	From      money.Currency `json:"from"`
+	To        money.Currency `json:"to"`
+	Rate      Rate           `json:"rate"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
+
+// QuoteRequest contains fields for client request.
+type QuoteRequest struct {
+	From money.Currency `json:"from"`
+	To   money.Currency `json:"to"`
+}
+
+// Provider returns current exchange rates.
+type Provider interface {
+	Rate(context.Context, money.Currency, money.Currency) (Rate, error)
+}
+
+// ParseRate converts a positive decimal string like "1.0834" into Rate.
+func ParseRate(s string) (Rate, error) {
+	r, err := parseRate(s)
+	if err != nil || r == 0 {
+		return 0, oops.ErrInvalidRate
+	}
+	return r, nil
+}
+
+// Inverse returns the rate for the opposite direction rounded to RateScale.
+func (r Rate) Inverse() Rate {
+	return Rate(divRound(big.NewInt(rateUnit*rateUnit), big.NewInt(int64(r))).Int64())
+}
+
+// Convert converts amount with the rate and rounds the result
+// half to even to the precision of the target currency.
+func (r Rate) Convert(amount money.Amount, to money.Currency) money.Amount {
+	drop := pow10(money.Scale - to.Precision())
+
+	n := new(big.Int).Mul(big.NewInt(int64(amount)), big.NewInt(int64(r)))
+	n = divRound(n, new(big.Int).Mul(big.NewInt(rateUnit), big.NewInt(drop)))
+
+	return money.Amount(n.Int64() * drop)
+}
+
+// String formats the rate as a decimal without trailing zeros.
+func (r Rate) String() string {
+	s := strconv.FormatInt(int64(r)/rateUnit, 10)
+	frac := strings.TrimRight(strconv.FormatInt(rateUnit+int64(r)%rateUnit, 10)[1:], "0")
+	if frac != "" {
+		s += "." + frac
+	}
+	return s
+}
+
+// MarshalJSON writes the rate as an exact JSON number.
+func (r Rate) MarshalJSON() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalJSON reads the rate from a JSON number or a string.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	// zero is a valid value meaning no rate was applied.
+	v, err := parseRate(string(bytes.Trim(data, `"`)))
+	if err != nil {
+		return err
+	}
+
+	*r = v
+	return nil
+}
+
+func parseRate(s string) (Rate, error) {
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" || (hasDot && fracPart == "") || len(fracPart) > RateScale || len(intPart) > 10 {
+		return 0, oops.ErrInvalidRate
+	}
+
+	n, err := strconv.ParseUint(intPart+fracPart+strings.Repeat("0", RateScale-len(fracPart)), 10, 63)
+	if err != nil {
+		return 0, oops.ErrInvalidRate
+	}
+
+	return Rate(n), nil
+}
+
+// divRound divides n by d rounding half to even, d must be positive.
+func divRound(n, d *big.Int) *big.Int {
+	q, m := new(big.Int).QuoRem(n, d, new(big.Int))
+
+	cmp := new(big.Int).Mul(new(big.Int).Abs(m), big.NewInt(2)).Cmp(d)
+	if cmp > 0 || (cmp == 0 && q.Bit(0) == 1) {
+		if n.Sign() < 0 {
+			return q.Sub(q, big.NewInt(1))
+		}
+		return q.Add(q, big.NewInt(1))
+	}
+
+	return q
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
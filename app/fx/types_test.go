@@ -0,0 +1,82 @@
+package fx
+
+import (
+	"testing"
+
+	"wallet/app/money"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name   string
+		rate   Rate
+		amount money.Amount
+		to     money.Currency
+		want   money.Amount
+	}{
+		{"identity", One, 123400, "USD", 123400},
+		{"exact", 150000000, 100000, "USD", 150000},
+		{"round down", 108340000, 10000, "USD", 10800},
+		{"round up", 108360000, 10000, "USD", 10800},
+		{"half to even down", 100500000, 10000, "USD", 10000},
+		{"half to even up", 101500000, 10000, "USD", 10200},
+		{"zero precision", 15012345678, 10000, "JPY", 1500000},
+		{"half to even zero precision", 250000000, 5000, "JPY", 10000},
+		{"three digits", 30600000, 10000, "KWD", 3060},
+		{"negative half to even", 100500000, -10000, "USD", -10000},
+		{"negative round", 108360000, -10000, "USD", -10800},
+		{"tiny rounds to zero", 1000000, 1000, "USD", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rate.Convert(tt.amount, tt.to); got != tt.want {
+				t.Errorf("%s.Convert(%s, %s) = %s, want %s", tt.rate, tt.amount, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInverse(t *testing.T) {
+	tests := []struct {
+		rate, want Rate
+	}{
+		{One, One},
+		{200000000, 50000000},
+		{300000000, 33333333},
+		{15000000000, 666667},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rate.Inverse(); got != tt.want {
+			t.Errorf("%s.Inverse() = %s, want %s", tt.rate, got, tt.want)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{in: "1", want: One},
+		{in: "1.0834", want: 108340000},
+		{in: "0.00000001", want: 1},
+		{in: "0", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "1.000000001", wantErr: true},
+		{in: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRate(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRate(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package idempotency replays stored responses for retried
+// requests with the same Idempotency-Key header.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"wallet/app/oops"
+	"wallet/app/response"
+)
+
+const (
+	// Header is the request header with the client's idempotency key.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses replayed from the store.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// maxBody is the largest request body read to fingerprint a request.
+	maxBody = 1 << 20
+)
+
+// record is the first result of a request with some key,
+// done is false while the first request is still running.
+type record struct {
+	hash        [sha256.Size]byte
+	done        bool
+	status      int
+	contentType string
+	body        []byte
+	expiresAt   time.Time
+}
+
+// expiry is a key of a record finished at expiresAt.
+type expiry struct {
+	key       string
+	expiresAt time.Time
+}
+
+// Store keeps responses by idempotency key until they expire. Records
+// live for the same ttl, so they expire in the order they finish and
+// the expiries queue is sorted by time.
+type Store struct {
+	ttl      time.Duration
+	records  map[string]*record
+	expiries []expiry
+	sync.Mutex
+}
+
+// NewStore is a constructor for Store, keys expire after ttl.
+func NewStore(ttl time.Duration) *Store {
+	return &Store{
+		ttl:     ttl,
+		records: make(map[string]*record),
+	}
+}
+
+// Middleware stores the first response for a key and replays it for
+// repeated requests with the identical method, path and body. A reused
+// key with a different payload or while the first request is running
+// gets 409 Conflict. Requests without the header are passed through.
+// Server errors are not stored, so the client may retry them.
+func (s *Store) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, oops.ErrBadReqMessage)
+			return
+		}
+		// a truncated body would be fingerprinted and handled as a whole one.
+		if len(body) > maxBody {
+			response.Error(w, http.StatusRequestEntityTooLarge, oops.ErrBodyTooLargeMessage)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		hash := fingerprint(r, body)
+
+		rec, replay, err := s.begin(key, hash)
+		if err != nil {
+			response.Error(w, http.StatusConflict, err.Error())
+			return
+		}
+		if replay {
+			w.Header().Set("Content-Type", rec.contentType)
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(rec.status)
+			w.Write(rec.body)
+			return
+		}
+
+		rw := &recorder{ResponseWriter: w, status: http.StatusOK}
+
+		// forget the key if the handler panics so the client may retry.
+		completed := false
+		defer func() {
+			if !completed {
+				s.forget(key)
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+
+		// a server error is forgotten like a panic, it may not happen again.
+		if rw.status >= http.StatusInternalServerError {
+			return
+		}
+		s.finish(key, rec, rw.status, rw.Header().Get("Content-Type"), rw.body.Bytes())
+		completed = true
+	})
+}
+
+// begin returns a stored record to replay or registers a new running one.
+func (s *Store) begin(key string, hash [sha256.Size]byte) (*record, bool, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.expire(time.Now())
+
+	rec, found := s.records[key]
+	if found {
+		if rec.hash != hash {
+			return nil, false, oops.ErrIdempotencyConflict
+		}
+		if !rec.done {
+			return nil, false, oops.ErrIdempotencyInFlight
+		}
+		return rec, true, nil
+	}
+
+	rec = &record{hash: hash}
+	s.records[key] = rec
+
+	return rec, false, nil
+}
+
+func (s *Store) finish(key string, rec *record, status int, contentType string, body []byte) {
+	s.Lock()
+	defer s.Unlock()
+
+	rec.done = true
+	rec.status = status
+	rec.contentType = contentType
+	rec.body = body
+	rec.expiresAt = time.Now().Add(s.ttl)
+	s.expiries = append(s.expiries, expiry{key: key, expiresAt: rec.expiresAt})
+}
+
+// expire deletes the records expired by now from the front of the
+// queue. A key forgotten or reused since keeps its newer record.
+func (s *Store) expire(now time.Time) {
+	n := 0
+	for ; n < len(s.expiries) && now.After(s.expiries[n].expiresAt); n++ {
+		e := s.expiries[n]
+		if rec, found := s.records[e.key]; found && rec.done && rec.expiresAt.Equal(e.expiresAt) {
+			delete(s.records, e.key)
+		}
+	}
+
+	// the dropped front is freed when append moves the queue.
+	s.expiries = s.expiries[n:]
+}
+
+func (s *Store) forget(key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.records, key)
+}
+
+func fingerprint(r *http.Request, body []byte) [sha256.Size]byte {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// recorder writes the response to the client and keeps a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recorder) WriteHeader(status int) {
+	rw.status = status
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recorder) Write(b []byte) (int, error) {
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
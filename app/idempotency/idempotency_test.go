@@ -0,0 +1,118 @@
+package idempotency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []int
+		bodies     []string
+		want       []int
+		wantCalls  int
+		wantReplay []bool
+	}{
+		{
+			name:       "replays success",
+			statuses:   []int{http.StatusCreated},
+			bodies:     []string{"a", "a"},
+			want:       []int{http.StatusCreated, http.StatusCreated},
+			wantCalls:  1,
+			wantReplay: []bool{false, true},
+		},
+		{
+			name:       "replays client error",
+			statuses:   []int{http.StatusUnprocessableEntity},
+			bodies:     []string{"a", "a"},
+			want:       []int{http.StatusUnprocessableEntity, http.StatusUnprocessableEntity},
+			wantCalls:  1,
+			wantReplay: []bool{false, true},
+		},
+		{
+			name:       "retries server error",
+			statuses:   []int{http.StatusInternalServerError, http.StatusOK},
+			bodies:     []string{"a", "a", "a"},
+			want:       []int{http.StatusInternalServerError, http.StatusOK, http.StatusOK},
+			wantCalls:  2,
+			wantReplay: []bool{false, false, true},
+		},
+		{
+			name:       "conflicting payload",
+			statuses:   []int{http.StatusOK},
+			bodies:     []string{"a", "b"},
+			want:       []int{http.StatusOK, http.StatusConflict},
+			wantCalls:  1,
+			wantReplay: []bool{false, false},
+		},
+		{
+			name:       "body too large",
+			statuses:   []int{http.StatusOK},
+			bodies:     []string{strings.Repeat("x", maxBody+1)},
+			want:       []int{http.StatusRequestEntityTooLarge},
+			wantCalls:  0,
+			wantReplay: []bool{false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			h := NewStore(time.Hour).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.statuses[calls])
+				calls++
+			}))
+
+			for i, body := range tt.bodies {
+				r := httptest.NewRequest(http.MethodPost, "/wallets/1/deposit", strings.NewReader(body))
+				r.Header.Set(Header, "key")
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+
+				if w.Code != tt.want[i] {
+					t.Errorf("request %d: status = %d, want %d", i, w.Code, tt.want[i])
+				}
+				if replayed := w.Header().Get(ReplayedHeader) == "true"; replayed != tt.wantReplay[i] {
+					t.Errorf("request %d: replayed = %v, want %v", i, replayed, tt.wantReplay[i])
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("handler calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestExpire(t *testing.T) {
+	s := NewStore(time.Minute)
+	now := time.Now()
+
+	for _, key := range []string{"a", "b"} {
+		rec, _, err := s.begin(key, [32]byte{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.finish(key, rec, http.StatusOK, "", nil)
+	}
+	// b is reused after it was forgotten, the new record must stay.
+	s.forget("b")
+	if _, _, err := s.begin("b", [32]byte{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	s.expire(now.Add(2 * time.Minute))
+
+	if _, found := s.records["a"]; found {
+		t.Error("expired record a is kept")
+	}
+	if _, found := s.records["b"]; !found {
+		t.Error("running record b is deleted")
+	}
+	if len(s.expiries) != 0 {
+		t.Errorf("expiries = %d, want 0", len(s.expiries))
+	}
+}
@@ -0,0 +1,42 @@
+package ledger
+
+import (
+	"net/http"
+
+	"wallet/app/oops"
+	"wallet/app/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler contains JournalService and a router.
+type Handler struct {
+	router  *chi.Mux
+	journal JournalService
+}
+
+// NewHandler is a constructor which accepts JournalService and
+// returns a pointer to the Handler.
+func NewHandler(router *chi.Mux, service JournalService) *Handler {
+	return &Handler{
+		router:  router,
+		journal: service,
+	}
+}
+
+// Register ledger routes.
+func (h *Handler) Register() {
+	h.router.Group(func(r chi.Router) {
+		r.Get("/ledger/trial-balance", h.trialBalance)
+	})
+}
+
+func (h *Handler) trialBalance(w http.ResponseWriter, r *http.Request) {
+	data, err := h.journal.TrialBalance(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, oops.ErrIntServMessage)
+		return
+	}
+
+	response.Data(w, http.StatusOK, data)
+}
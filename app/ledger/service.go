@@ -0,0 +1,61 @@
+// Package ledger has a business logic for the double-entry journal
+// wallet balances are derived from.
+package ledger
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+)
+
+// JournalService contains Store interface.
+type JournalService struct {
+	store Store
+}
+
+// NewJournalService is a JournalService constructor.
+func NewJournalService(store Store) *JournalService {
+	return &JournalService{
+		store: store,
+	}
+}
+
+// TrialBalance returns balances of all accounts and verifies
+// that the journal sums to zero.
+func (s *JournalService) TrialBalance(ctx context.Context) (TrialBalance, error) {
+	accounts, err := s.store.Balances(ctx)
+	if err != nil {
+		return TrialBalance{}, err
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Currency != accounts[j].Currency {
+			return accounts[i].Currency < accounts[j].Currency
+		}
+		return accounts[i].Account < accounts[j].Account
+	})
+
+	tb := TrialBalance{
+		Accounts: accounts,
+		Totals:   make(map[money.Currency]money.Amount),
+		Balanced: true,
+	}
+	for _, a := range accounts {
+		tb.Totals[a.Currency] += a.Balance
+	}
+
+	err = s.store.Check(ctx)
+	if err != nil {
+		if !errors.Is(err, oops.ErrUnbalanced) {
+			return TrialBalance{}, err
+		}
+		log.Printf("journal check error: %s", err.Error())
+		tb.Balanced = false
+	}
+
+	return tb, nil
+}
@@ -0,0 +1,52 @@
+// Package store contains all implementation to read
+// the journal from storage.DB.
+package store
+
+import (
+	"context"
+
+	"wallet/app/ledger"
+	"wallet/app/storage"
+)
+
+// Storage works with the journal in storage.DB.
+type Storage struct {
+	db storage.DB
+}
+
+// NewStorage is a constructor for storage.
+func NewStorage(db storage.DB) *Storage {
+	return &Storage{
+		db: db,
+	}
+}
+
+// Balances returns balances of all journal accounts.
+func (s *Storage) Balances(ctx context.Context) ([]ledger.AccountBalance, error) {
+	var balances []ledger.AccountBalance
+
+	err := s.db.View(func(tx storage.Tx) error {
+		data, err := tx.Balances()
+		if err != nil {
+			return err
+		}
+
+		balances = make([]ledger.AccountBalance, 0, len(data))
+		for account, amount := range data {
+			balances = append(balances, ledger.AccountBalance{
+				Account:  account.Name,
+				Currency: account.Currency,
+				Balance:  amount,
+			})
+		}
+
+		return nil
+	})
+
+	return balances, err
+}
+
+// Check verifies the journal invariant.
+func (s *Storage) Check(ctx context.Context) error {
+	return s.db.View(storage.CheckJournal)
+}
@@ -0,0 +1,33 @@
+package ledger
+
+import (
+	"context"
+
+	"wallet/app/money"
+)
+
+// AccountBalance is a balance of one journal account.
+type AccountBalance struct {
+	Account  string         `json:"account"`
+	Currency money.Currency `json:"currency"`
+	Balance  money.Amount   `json:"balance"`
+}
+
+// TrialBalance lists balances of all journal accounts, Totals must be
+// zero in every currency and Balanced is the result of the journal check.
+type TrialBalance struct {
+	Accounts []AccountBalance                `json:"accounts"`
+	Totals   map[money.Currency]money.Amount `json:"totals"`
+	Balanced bool                            `json:"balanced"`
+}
+
+// Store contains all methods to read the journal from the storage.
+type Store interface {
+	Balances(context.Context) ([]AccountBalance, error)
+	Check(context.Context) error
+}
+
+// Service contains all methods from ledger service.
+type Service interface {
+	TrialBalance(context.Context) (TrialBalance, error)
+}
@@ -2,26 +2,93 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
 
+	"wallet/app/config"
+	"wallet/app/event"
 	"wallet/app/queue"
 	"wallet/app/server"
+	"wallet/app/storage"
+	"wallet/app/storage/wal"
 )
 
 func main() {
-	// Init nsq.
-	nsq, err := queue.NewNSQ()
+	rebuild := flag.String("rebuild", "", "replay the event log into durable memory storage in the dir and exit")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *rebuild != "" {
+		if err = rebuildStorage(cfg, *rebuild); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Init queue.
+	encoder, err := queue.NewEncoder(cfg.EventFormat, cfg.EventSource)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer nsq.Stop()
+
+	publisher, err := queue.Open(cfg.Queue, queue.Options{
+		NSQAddr:   cfg.NSQAddr,
+		File:      cfg.QueueFile,
+		BusBuffer: 100,
+		Encoder:   encoder,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer publisher.Stop()
 
 	// Init web server.
-	s := server.New(nsq)
+	s := server.New(cfg, publisher)
 	s.SetupMiddleware()
-	s.SetupApp()
+	if err = s.SetupApp(); err != nil {
+		log.Fatal(err)
+	}
 
 	if err = s.Start(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// rebuildStorage replays the event log of the configured storage into
+// a durable memory storage in dir and reports wallets whose stored
+// balance can't be reached by their events.
+func rebuildStorage(cfg config.Config, dir string) error {
+	src, err := server.OpenDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := storage.OpenMemory(storage.DurableOptions{
+		Dir: dir,
+		WAL: wal.Options{Sync: wal.SyncAlways},
+	})
+	if err != nil {
+		return err
+	}
+
+	report, err := event.Rebuild(context.Background(), src, dst)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("rebuilt %d wallets from %d events into %s", report.Wallets, report.Events, dir)
+	for _, m := range report.Mismatches {
+		log.Printf("wallet %s balance mismatch: stored %s, rebuilt %s", m.WalletID, m.Stored, m.Rebuilt)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package money
+
+import (
+	"sort"
+	"strings"
+
+	"wallet/app/oops"
+)
+
+// Currency is an ISO 4217 alphabetic currency code.
+type Currency string
+
+// precisions holds the number of minor unit digits for known currencies.
+var precisions = map[Currency]int{
+	"AED": 2, "AUD": 2, "BHD": 3, "BRL": 2, "CAD": 2, "CHF": 2,
+	"CLF": 4, "CLP": 0, "CNY": 2, "CZK": 2, "DKK": 2, "EUR": 2,
+	"GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "ILS": 2, "INR": 2,
+	"ISK": 0, "JOD": 3, "JPY": 0, "KRW": 0, "KWD": 3, "KZT": 2,
+	"MXN": 2, "NOK": 2, "NZD": 2, "OMR": 3, "PLN": 2, "RUB": 2,
+	"SAR": 2, "SEK": 2, "SGD": 2, "THB": 2, "TND": 3, "TRY": 2,
+	"UAH": 2, "USD": 2, "VND": 0, "ZAR": 2,
+}
+
+// ParseCurrency validates an ISO 4217 code and returns it in upper case.
+func ParseCurrency(code string) (Currency, error) {
+	c := Currency(strings.ToUpper(strings.TrimSpace(code)))
+	if _, ok := precisions[c]; !ok {
+		return "", oops.ErrInvalidCurrency
+	}
+	return c, nil
+}
+
+// Currencies returns the known currencies in order.
+func Currencies() []Currency {
+	currencies := make([]Currency, 0, len(precisions))
+	for c := range precisions {
+		currencies = append(currencies, c)
+	}
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i] < currencies[j]
+	})
+	return currencies
+}
+
+// Precision returns the number of decimal places allowed for the currency.
+func (c Currency) Precision() int {
+	return precisions[c]
+}
+
+// Check returns an error if amount is not positive or has more
+// decimal places than the currency allows.
+func (c Currency) Check(amount Amount) error {
+	if !amount.IsPositive() || !amount.Fits(c.Precision()) {
+		return oops.ErrInvalidAmount
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Package money contains an exact fixed-point type for money amounts.
+package money
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"wallet/app/oops"
+)
+
+const (
+	// Scale is the number of decimal places kept by Amount.
+	Scale = 4
+
+	unit = 10000 // 10^Scale
+
+	// limit is the largest amount Parse accepts.
+	limit = 99999999999999_9999
+)
+
+// Amount is a money value stored as an integer number of 10^-Scale units,
+// e.g. 12.34 is stored as 123400. Arithmetic on Amount is exact.
+type Amount int64
+
+// Parse converts a decimal string like "12.34" into Amount.
+// Exponents and more than Scale decimal places are rejected.
+func Parse(s string) (Amount, error) {
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" || (hasDot && fracPart == "") || len(fracPart) > Scale {
+		return 0, oops.ErrInvalidAmount
+	}
+	// the integer part must fit into int64 after scaling.
+	if len(intPart) > 14 {
+		return 0, oops.ErrInvalidAmount
+	}
+
+	var n int64
+	for _, c := range intPart + fracPart + strings.Repeat("0", Scale-len(fracPart)) {
+		if c < '0' || c > '9' {
+			return 0, oops.ErrInvalidAmount
+		}
+		n = n*10 + int64(c-'0')
+	}
+
+	if neg {
+		n = -n
+	}
+
+	return Amount(n), nil
+}
+
+// Fits reports whether the amount has no more than precision decimal places.
+func (a Amount) Fits(precision int) bool {
+	if precision >= Scale {
+		return true
+	}
+	return int64(a)%pow10(Scale-precision) == 0
+}
+
+// Add returns the sum of the amounts, it returns oops.ErrAmountOverflow
+// if the sum is out of the range Parse accepts.
+func (a Amount) Add(b Amount) (Amount, error) {
+	sum := a + b
+	if (sum > a) != (b > 0) || sum > limit || sum < -limit {
+		return 0, oops.ErrAmountOverflow
+	}
+	return sum, nil
+}
+
+// Sub returns the difference of the amounts, it returns
+// oops.ErrAmountOverflow if the difference is out of the range
+// Parse accepts.
+func (a Amount) Sub(b Amount) (Amount, error) {
+	diff := a - b
+	if (diff < a) != (b > 0) || diff > limit || diff < -limit {
+		return 0, oops.ErrAmountOverflow
+	}
+	return diff, nil
+}
+
+// IsPositive reports whether the amount is greater than zero.
+func (a Amount) IsPositive() bool {
+	return a > 0
+}
+
+// String formats the amount as a decimal without trailing zeros.
+func (a Amount) String() string {
+	n := int64(a)
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+
+	s := strconv.FormatInt(n/unit, 10)
+	frac := strings.TrimRight(strconv.FormatInt(unit+n%unit, 10)[1:], "0")
+	if frac != "" {
+		s += "." + frac
+	}
+
+	return sign + s
+}
+
+// MarshalJSON writes the amount as an exact JSON number.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON reads the amount from a JSON number or a string
+// without going through float64.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	s := string(bytes.Trim(data, `"`))
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = v
+	return nil
+}
+
+func pow10(n int) int64 {
+	p := int64(1)
+	for i := 0; i < n; i++ {
+		p *= 10
+	}
+	return p
+}
@@ -0,0 +1,167 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"wallet/app/oops"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{in: "0", want: 0},
+		{in: "12.34", want: 123400},
+		{in: "-12.34", want: -123400},
+		{in: "0.0001", want: 1},
+		{in: "99999999999999.9999", want: 999999999999999999},
+		{in: "1.00001", wantErr: true},
+		{in: "1e3", wantErr: true},
+		{in: ".5", wantErr: true},
+		{in: "5.", wantErr: true},
+		{in: "", wantErr: true},
+		{in: "123456789012345", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		in   Amount
+		want string
+	}{
+		{0, "0"},
+		{123400, "12.34"},
+		{-123400, "-12.34"},
+		{1, "0.0001"},
+		{-1, "-0.0001"},
+		{10000, "1"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.String(); got != tt.want {
+			t.Errorf("Amount(%d).String() = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFits(t *testing.T) {
+	tests := []struct {
+		in        Amount
+		precision int
+		want      bool
+	}{
+		{123400, 2, true},
+		{123450, 2, false},
+		{10000, 0, true},
+		{15000, 0, false},
+		{1, 4, true},
+		{-123400, 2, true},
+		{-123450, 2, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.in.Fits(tt.precision); got != tt.want {
+			t.Errorf("Amount(%d).Fits(%d) = %v, want %v", tt.in, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var v struct {
+		A Amount `json:"a"`
+		B Amount `json:"b"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": 0.1, "b": "2.5"}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A != 1000 || v.B != 25000 {
+		t.Fatalf("unmarshal = %d, %d, want 1000, 25000", v.A, v.B)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"a":0.1,"b":2.5}` {
+		t.Fatalf("marshal = %s", data)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		currency Currency
+		amount   Amount
+		wantErr  bool
+	}{
+		{"USD", 123400, false},
+		{"USD", 123450, true},
+		{"JPY", 10000, false},
+		{"JPY", 15000, true},
+		{"KWD", 1230, false},
+		{"USD", 0, true},
+		{"USD", -10000, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.currency.Check(tt.amount); (err != nil) != tt.wantErr {
+			t.Errorf("%s.Check(%s) error = %v, want error %v", tt.currency, tt.amount, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAddSub(t *testing.T) {
+	max, err := Parse("99999999999999.9999")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		a, b            Amount
+		sum, diff       Amount
+		sumErr, diffErr bool
+	}{
+		{a: 123400, b: 100, sum: 123500, diff: 123300},
+		{a: -5, b: 10, sum: 5, diff: -15},
+		{a: max, b: 0, sum: max, diff: max},
+		{a: max, b: 1, sumErr: true, diff: max - 1},
+		{a: max, b: -1, sum: max - 1, diffErr: true},
+		{a: -max, b: 1, sum: -max + 1, diffErr: true},
+		{a: -max, b: -1, sumErr: true, diff: -max + 1},
+		{a: math.MaxInt64, b: math.MaxInt64, sumErr: true, diff: 0},
+		{a: math.MinInt64, b: math.MaxInt64, sum: -1, diffErr: true},
+	}
+
+	for _, tt := range tests {
+		sum, err := tt.a.Add(tt.b)
+		if (err != nil) != tt.sumErr || (err == nil && sum != tt.sum) {
+			t.Errorf("%d.Add(%d) = %d, %v, want %d, error %v", tt.a, tt.b, sum, err, tt.sum, tt.sumErr)
+		}
+		if err != nil && !errors.Is(err, oops.ErrAmountOverflow) {
+			t.Errorf("%d.Add(%d) error = %v, want %v", tt.a, tt.b, err, oops.ErrAmountOverflow)
+		}
+
+		diff, err := tt.a.Sub(tt.b)
+		if (err != nil) != tt.diffErr || (err == nil && diff != tt.diff) {
+			t.Errorf("%d.Sub(%d) = %d, %v, want %d, error %v", tt.a, tt.b, diff, err, tt.diff, tt.diffErr)
+		}
+		if err != nil && !errors.Is(err, oops.ErrAmountOverflow) {
+			t.Errorf("%d.Sub(%d) error = %v, want %v", tt.a, tt.b, err, oops.ErrAmountOverflow)
+		}
+	}
+}
@@ -14,10 +14,119 @@ const (
 	ErrBadReqMessage = "bad request"
 	// ErrNotEnoMonMessage - not enough memory
 	ErrNotEnoMonMessage = "not enough money"
+	// ErrInvalidAmountMessage - amount is not positive or too precise.
+	ErrInvalidAmountMessage = "invalid amount"
+	// ErrAmountOverflowMessage - sum of amounts is out of the amount range.
+	ErrAmountOverflowMessage = "amount overflow"
+	// ErrInvalidCurrencyMessage - unknown ISO 4217 currency code.
+	ErrInvalidCurrencyMessage = "invalid currency"
+	// ErrCurrencyMismatchMessage - operation currency differs from the wallet currency.
+	ErrCurrencyMismatchMessage = "currency mismatch"
+	// ErrInvalidRateMessage - exchange rate is not a positive decimal.
+	ErrInvalidRateMessage = "invalid rate"
+	// ErrRateNotFoundMessage - no exchange rate for the currency pair.
+	ErrRateNotFoundMessage = "rate not found"
+	// ErrQuoteNotFoundMessage - fx quote is unknown or expired.
+	ErrQuoteNotFoundMessage = "quote not found"
+	// ErrSelfTransferMessage - transfer to the same wallet.
+	ErrSelfTransferMessage = "self transfer"
+	// ErrBadFilterMessage - invalid cursor, limit, type or date range.
+	ErrBadFilterMessage = "bad filter"
+	// ErrUnbalancedMessage - journal entries don't sum to zero.
+	ErrUnbalancedMessage = "unbalanced journal"
+	// ErrIdempotencyConflictMessage - idempotency key reused with a different payload.
+	ErrIdempotencyConflictMessage = "idempotency key reused"
+	// ErrIdempotencyInFlightMessage - request with the same idempotency key is running.
+	ErrIdempotencyInFlightMessage = "idempotency key in progress"
+	// ErrBodyTooLargeMessage - request body exceeds the size limit.
+	ErrBodyTooLargeMessage = "request body too large"
+	// ErrOutOfOrderMessage - consumed event follows a missing one.
+	ErrOutOfOrderMessage = "event out of order"
+	// ErrDeadLetterFullMessage - dead-letter store reached its capacity.
+	ErrDeadLetterFullMessage = "dead letters full"
+	// ErrBadHistoryMessage - events of an aggregate are inconsistent.
+	ErrBadHistoryMessage = "inconsistent event history"
+	// ErrHoldNotFoundMessage - hold is unknown or belongs to another wallet.
+	ErrHoldNotFoundMessage = "hold not found"
+	// ErrHoldClosedMessage - hold is already captured, released or expired.
+	ErrHoldClosedMessage = "hold is closed"
+	// ErrInvalidExpiryMessage - hold expiry is not positive or too far.
+	ErrInvalidExpiryMessage = "invalid hold expiry"
+	// ErrTransactionNotFoundMessage - requested transaction not found.
+	ErrTransactionNotFoundMessage = "transaction not found"
+	// ErrNotReversibleMessage - transaction is a reversal itself.
+	ErrNotReversibleMessage = "transaction can't be reversed"
+	// ErrAlreadyReversedMessage - the whole transaction amount is reversed.
+	ErrAlreadyReversedMessage = "transaction already reversed"
+	// ErrInvalidLimitsMessage - limit is negative, the tier is unknown or
+	// has no limits in the wallet currency.
+	ErrInvalidLimitsMessage = "invalid limits"
+	// ErrWithdrawalLimitMessage - withdrawal is larger than the wallet allows.
+	ErrWithdrawalLimitMessage = "withdrawal limit exceeded"
+	// ErrDailyLimitMessage - outgoing total of the day would exceed the limit.
+	ErrDailyLimitMessage = "daily limit exceeded"
+	// ErrMonthlyLimitMessage - outgoing total of the month would exceed the limit.
+	ErrMonthlyLimitMessage = "monthly limit exceeded"
+	// ErrTransferRateMessage - too many transfers sent within the last hour.
+	ErrTransferRateMessage = "transfer rate exceeded"
+	// ErrBalanceLimitMessage - balance would exceed the wallet maximum.
+	ErrBalanceLimitMessage = "balance limit exceeded"
+	// ErrInvalidOperationMessage - fee quote of an operation without fees.
+	ErrInvalidOperationMessage = "invalid operation"
+	// ErrInvalidCreditMessage - credit limit is negative or too precise.
+	ErrInvalidCreditMessage = "invalid credit limit"
+	// ErrCreditInUseMessage - credit limit is below the credit in use.
+	ErrCreditInUseMessage = "credit in use"
+	// ErrInvalidInterestMessage - interest rate is negative or too precise.
+	ErrInvalidInterestMessage = "invalid interest rate"
+	// ErrReservedWalletMessage - house fee wallet is changed by the fees only.
+	ErrReservedWalletMessage = "reserved wallet"
 )
 
 // ErrNotFound — wallet not found.
 var (
-	ErrNotFound  = errors.New(ErrNotFoundMessage)
-	ErrNotEnoMon = errors.New(ErrNotEnoMonMessage)
+	ErrNotFound       = errors.New(ErrNotFoundMessage)
+	ErrNotEnoMon      = errors.New(ErrNotEnoMonMessage)
+	ErrInvalidAmount  = errors.New(ErrInvalidAmountMessage)
+	ErrAmountOverflow = errors.New(ErrAmountOverflowMessage)
+
+	ErrInvalidCurrency  = errors.New(ErrInvalidCurrencyMessage)
+	ErrCurrencyMismatch = errors.New(ErrCurrencyMismatchMessage)
+
+	ErrInvalidRate   = errors.New(ErrInvalidRateMessage)
+	ErrRateNotFound  = errors.New(ErrRateNotFoundMessage)
+	ErrQuoteNotFound = errors.New(ErrQuoteNotFoundMessage)
+	ErrSelfTransfer  = errors.New(ErrSelfTransferMessage)
+	ErrBadFilter     = errors.New(ErrBadFilterMessage)
+	ErrUnbalanced    = errors.New(ErrUnbalancedMessage)
+
+	ErrIdempotencyConflict = errors.New(ErrIdempotencyConflictMessage)
+	ErrIdempotencyInFlight = errors.New(ErrIdempotencyInFlightMessage)
+
+	ErrOutOfOrder     = errors.New(ErrOutOfOrderMessage)
+	ErrDeadLetterFull = errors.New(ErrDeadLetterFullMessage)
+	ErrBadHistory     = errors.New(ErrBadHistoryMessage)
+
+	ErrHoldNotFound  = errors.New(ErrHoldNotFoundMessage)
+	ErrHoldClosed    = errors.New(ErrHoldClosedMessage)
+	ErrInvalidExpiry = errors.New(ErrInvalidExpiryMessage)
+
+	ErrTransactionNotFound = errors.New(ErrTransactionNotFoundMessage)
+	ErrNotReversible       = errors.New(ErrNotReversibleMessage)
+	ErrAlreadyReversed     = errors.New(ErrAlreadyReversedMessage)
+
+	ErrInvalidLimits   = errors.New(ErrInvalidLimitsMessage)
+	ErrWithdrawalLimit = errors.New(ErrWithdrawalLimitMessage)
+	ErrDailyLimit      = errors.New(ErrDailyLimitMessage)
+	ErrMonthlyLimit    = errors.New(ErrMonthlyLimitMessage)
+	ErrTransferRate    = errors.New(ErrTransferRateMessage)
+	ErrBalanceLimit    = errors.New(ErrBalanceLimitMessage)
+
+	ErrInvalidOperation = errors.New(ErrInvalidOperationMessage)
+	ErrReservedWallet   = errors.New(ErrReservedWalletMessage)
+
+	ErrInvalidCredit = errors.New(ErrInvalidCreditMessage)
+	ErrCreditInUse   = errors.New(ErrCreditInUseMessage)
+
+	ErrInvalidInterest = errors.New(ErrInvalidInterestMessage)
 )
@@ -0,0 +1,21 @@
+package operation
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// ExpiryJob releases the holds expired by at, holds of a wallet
+// are also released by the next operation of the wallet.
+func (s *WalletService) ExpiryJob(ctx context.Context, at time.Time) error {
+	n, err := s.ExpireHolds(ctx, at)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("%d expired holds released", n)
+	}
+
+	return nil
+}
@@ -2,6 +2,8 @@ package operation
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
 	"wallet/app/oops"
@@ -10,27 +12,41 @@ import (
 	"github.com/go-chi/chi/v5"
 )
 
-// Handler contains app Service and a router.
+// Handler contains app Service, a router and
+// middlewares applied to operation routes.
 type Handler struct {
-	router    *chi.Mux
-	operation WalletService
+	router      *chi.Mux
+	operation   WalletService
+	middlewares []func(http.Handler) http.Handler
 }
 
 // NewHandler is a constructor which accepts operation Service and
 // returns a pointer to the Handler.
-func NewHandler(router *chi.Mux, service WalletService) *Handler {
+func NewHandler(router *chi.Mux, service WalletService, middlewares ...func(http.Handler) http.Handler) *Handler {
 	return &Handler{
-		router:    router,
-		operation: service,
+		router:      router,
+		operation:   service,
+		middlewares: middlewares,
 	}
 }
 
 // Register operation routes.
 func (h *Handler) Register() {
 	h.router.Group(func(r chi.Router) {
+		r.Use(h.middlewares...)
 		r.Post("/wallets/{id}/deposit", h.deposit)
 		r.Post("/wallets/{id}/withdraw", h.withdraw)
 		r.Post("/wallets/{id}/transfer", h.transfer)
+		r.Post("/wallets/{id}/holds", h.placeHold)
+		r.Get("/wallets/{id}/holds", h.holds)
+		r.Get("/wallets/{id}/holds/{hold}", h.hold)
+		r.Post("/wallets/{id}/holds/{hold}/capture", h.captureHold)
+		r.Post("/wallets/{id}/holds/{hold}/release", h.releaseHold)
+		r.Post("/transactions/{id}/reverse", h.reverse)
+		r.Get("/wallets/{id}/limits", h.limits)
+		r.Put("/wallets/{id}/limits", h.setLimits)
+		r.Put("/wallets/{id}/credit", h.setCredit)
+		r.Put("/wallets/{id}/interest", h.setInterest)
 	})
 }
 
@@ -50,7 +66,8 @@ func (h *Handler) deposit(w http.ResponseWriter, r *http.Request) {
 
 	err = h.operation.Deposit(r.Context(), id, requestBody)
 	if err != nil {
-		response.OperationError(w, http.StatusInternalServerError, oops.ErrIntServMessage, requestBody.Amount)
+		status, msg := errorStatus(err)
+		response.OperationError(w, status, msg, requestBody.Amount)
 		return
 	}
 
@@ -71,13 +88,14 @@ func (h *Handler) withdraw(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.operation.Withdraw(r.Context(), id, requestBody)
+	charged, err := h.operation.Withdraw(r.Context(), id, requestBody)
 	if err != nil {
-		response.OperationError(w, http.StatusInternalServerError, oops.ErrIntServMessage, requestBody.Amount)
+		status, msg := errorStatus(err)
+		response.OperationError(w, status, msg, requestBody.Amount)
 		return
 	}
 
-	response.OperationSuccess(w, http.StatusOK, requestBody.Amount)
+	response.OperationFee(w, http.StatusOK, requestBody.Amount, charged)
 }
 
 func (h *Handler) transfer(w http.ResponseWriter, r *http.Request) {
@@ -94,11 +112,285 @@ func (h *Handler) transfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.operation.Transfer(r.Context(), id, requestBody)
+	transfer, err := h.operation.Transfer(r.Context(), id, requestBody)
 	if err != nil {
-		response.OperationError(w, http.StatusInternalServerError, oops.ErrIntServMessage, requestBody.Amount)
+		status, msg := errorStatus(err)
+		response.OperationError(w, status, msg, requestBody.Amount)
 		return
 	}
 
-	response.OperationSuccess(w, http.StatusOK, requestBody.Amount)
+	response.Data(w, http.StatusOK, transfer)
+}
+
+func (h *Handler) placeHold(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.OperationError(w, http.StatusBadRequest, oops.ErrBadReqMessage, 0)
+		return
+	}
+
+	var requestBody HoldRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		response.OperationError(w, http.StatusBadRequest, oops.ErrBadReqMessage, requestBody.Amount)
+		return
+	}
+
+	hold, err := h.operation.PlaceHold(r.Context(), id, requestBody)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.OperationError(w, status, msg, requestBody.Amount)
+		return
+	}
+
+	response.Data(w, http.StatusCreated, hold)
+}
+
+func (h *Handler) holds(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, "")
+		return
+	}
+
+	holds, err := h.operation.Holds(r.Context(), id)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
+		return
+	}
+
+	response.Data(w, http.StatusOK, holds)
+}
+
+func (h *Handler) hold(w http.ResponseWriter, r *http.Request) {
+	id, holdID := chi.URLParam(r, "id"), chi.URLParam(r, "hold")
+	if id == "" || holdID == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, id)
+		return
+	}
+
+	hold, err := h.operation.Hold(r.Context(), id, holdID)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
+		return
+	}
+
+	response.Data(w, http.StatusOK, hold)
+}
+
+// captureHold reads an optional body, without it the whole hold is captured.
+func (h *Handler) captureHold(w http.ResponseWriter, r *http.Request) {
+	id, holdID := chi.URLParam(r, "id"), chi.URLParam(r, "hold")
+	if id == "" || holdID == "" {
+		response.OperationError(w, http.StatusBadRequest, oops.ErrBadReqMessage, 0)
+		return
+	}
+
+	var requestBody CaptureRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil && !errors.Is(err, io.EOF) {
+		response.OperationError(w, http.StatusBadRequest, oops.ErrBadReqMessage, requestBody.Amount)
+		return
+	}
+
+	hold, err := h.operation.CaptureHold(r.Context(), id, holdID, requestBody)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.OperationError(w, status, msg, requestBody.Amount)
+		return
+	}
+
+	response.Data(w, http.StatusOK, hold)
+}
+
+func (h *Handler) releaseHold(w http.ResponseWriter, r *http.Request) {
+	id, holdID := chi.URLParam(r, "id"), chi.URLParam(r, "hold")
+	if id == "" || holdID == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, id)
+		return
+	}
+
+	hold, err := h.operation.ReleaseHold(r.Context(), id, holdID)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
+		return
+	}
+
+	response.Data(w, http.StatusOK, hold)
+}
+
+// reverse reads an optional body, without it the whole
+// transaction amount which is not reversed yet is reversed.
+func (h *Handler) reverse(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.OperationError(w, http.StatusBadRequest, oops.ErrBadReqMessage, 0)
+		return
+	}
+
+	var requestBody ReverseRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil && !errors.Is(err, io.EOF) {
+		response.OperationError(w, http.StatusBadRequest, oops.ErrBadReqMessage, requestBody.Amount)
+		return
+	}
+
+	reversal, err := h.operation.Reverse(r.Context(), id, requestBody)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.OperationError(w, status, msg, requestBody.Amount)
+		return
+	}
+
+	response.Data(w, http.StatusOK, reversal)
+}
+
+func (h *Handler) limits(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, "")
+		return
+	}
+
+	limits, err := h.operation.Limits(r.Context(), id)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
+		return
+	}
+
+	response.Data(w, http.StatusOK, limits)
+}
+
+func (h *Handler) setLimits(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, "")
+		return
+	}
+
+	var requestBody LimitsRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, id)
+		return
+	}
+
+	limits, err := h.operation.SetLimits(r.Context(), id, requestBody)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
+		return
+	}
+
+	response.Data(w, http.StatusOK, limits)
+}
+
+func (h *Handler) setCredit(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, "")
+		return
+	}
+
+	var requestBody CreditRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, id)
+		return
+	}
+
+	credit, err := h.operation.SetCredit(r.Context(), id, requestBody)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
+		return
+	}
+
+	response.Data(w, http.StatusOK, credit)
+}
+
+func (h *Handler) setInterest(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, "")
+		return
+	}
+
+	var requestBody InterestRequest
+	err := json.NewDecoder(r.Body).Decode(&requestBody)
+	if err != nil {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, id)
+		return
+	}
+
+	interest, err := h.operation.SetInterest(r.Context(), id, requestBody)
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
+		return
+	}
+
+	response.Data(w, http.StatusOK, interest)
+}
+
+// errorStatus maps an error from the service to HTTP status and error message.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, oops.ErrNotFound):
+		return http.StatusNotFound, oops.ErrNotFoundMessage
+	case errors.Is(err, oops.ErrNotEnoMon):
+		return http.StatusUnprocessableEntity, oops.ErrNotEnoMonMessage
+	case errors.Is(err, oops.ErrSelfTransfer):
+		return http.StatusBadRequest, oops.ErrSelfTransferMessage
+	case errors.Is(err, oops.ErrInvalidAmount):
+		return http.StatusBadRequest, oops.ErrInvalidAmountMessage
+	case errors.Is(err, oops.ErrAmountOverflow):
+		return http.StatusUnprocessableEntity, oops.ErrAmountOverflowMessage
+	case errors.Is(err, oops.ErrInvalidCurrency):
+		return http.StatusBadRequest, oops.ErrInvalidCurrencyMessage
+	case errors.Is(err, oops.ErrCurrencyMismatch):
+		return http.StatusUnprocessableEntity, oops.ErrCurrencyMismatchMessage
+	case errors.Is(err, oops.ErrRateNotFound):
+		return http.StatusUnprocessableEntity, oops.ErrRateNotFoundMessage
+	case errors.Is(err, oops.ErrQuoteNotFound):
+		return http.StatusUnprocessableEntity, oops.ErrQuoteNotFoundMessage
+	case errors.Is(err, oops.ErrHoldNotFound):
+		return http.StatusNotFound, oops.ErrHoldNotFoundMessage
+	case errors.Is(err, oops.ErrHoldClosed):
+		return http.StatusConflict, oops.ErrHoldClosedMessage
+	case errors.Is(err, oops.ErrInvalidExpiry):
+		return http.StatusBadRequest, oops.ErrInvalidExpiryMessage
+	case errors.Is(err, oops.ErrTransactionNotFound):
+		return http.StatusNotFound, oops.ErrTransactionNotFoundMessage
+	case errors.Is(err, oops.ErrNotReversible):
+		return http.StatusUnprocessableEntity, oops.ErrNotReversibleMessage
+	case errors.Is(err, oops.ErrAlreadyReversed):
+		return http.StatusConflict, oops.ErrAlreadyReversedMessage
+	case errors.Is(err, oops.ErrInvalidLimits):
+		return http.StatusBadRequest, oops.ErrInvalidLimitsMessage
+	case errors.Is(err, oops.ErrWithdrawalLimit):
+		return http.StatusUnprocessableEntity, oops.ErrWithdrawalLimitMessage
+	case errors.Is(err, oops.ErrDailyLimit):
+		return http.StatusUnprocessableEntity, oops.ErrDailyLimitMessage
+	case errors.Is(err, oops.ErrMonthlyLimit):
+		return http.StatusUnprocessableEntity, oops.ErrMonthlyLimitMessage
+	case errors.Is(err, oops.ErrTransferRate):
+		return http.StatusTooManyRequests, oops.ErrTransferRateMessage
+	case errors.Is(err, oops.ErrBalanceLimit):
+		return http.StatusUnprocessableEntity, oops.ErrBalanceLimitMessage
+	case errors.Is(err, oops.ErrInvalidCredit):
+		return http.StatusBadRequest, oops.ErrInvalidCreditMessage
+	case errors.Is(err, oops.ErrCreditInUse):
+		return http.StatusConflict, oops.ErrCreditInUseMessage
+	case errors.Is(err, oops.ErrInvalidInterest):
+		return http.StatusBadRequest, oops.ErrInvalidInterestMessage
+	case errors.Is(err, oops.ErrReservedWallet):
+		return http.StatusForbidden, oops.ErrReservedWalletMessage
+	default:
+		return http.StatusInternalServerError, oops.ErrIntServMessage
+	}
 }
@@ -0,0 +1,93 @@
+package operation_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wallet/app/fee"
+	"wallet/app/oops"
+	"wallet/app/operation"
+	"wallet/app/operation/store"
+	"wallet/app/storage"
+	"wallet/app/wallet"
+	walletStorage "wallet/app/wallet/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestHandlerReservesFeeWallets(t *testing.T) {
+	ctx := context.Background()
+	db := storage.NewMemory()
+	house := storage.FeeWallet("USD")
+
+	wallets := walletStorage.NewStorage(db)
+	if err := wallets.OpenWallet(ctx, house, wallet.Request{Name: "house fees USD", Currency: "USD"}); err != nil {
+		t.Fatal(err)
+	}
+	other, err := wallets.CreateWallet(ctx, wallet.Request{Name: "test", Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.NewStorage(db).Deposit(ctx, house, 10000, "USD", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fees, err := fee.NewSchedule(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiers, err := operation.LoadTiers("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := operation.NewWalletService(store.NewStorage(db), nil, fees, operation.HoldOptions{}, tiers, 0, operation.InterestRates{})
+	router := chi.NewRouter()
+	operation.NewHandler(router, *service).Register()
+
+	for _, test := range []struct {
+		name, method, path, body string
+	}{
+		{"withdraw", http.MethodPost, "/withdraw", `{"amount":"1","currency":"USD"}`},
+		{"transfer", http.MethodPost, "/transfer", `{"amount":"1","currency":"USD","transfer_to":"` + other.ID + `"}`},
+		{"place hold", http.MethodPost, "/holds", `{"amount":"1","currency":"USD"}`},
+		{"capture hold", http.MethodPost, "/holds/1/capture", `{}`},
+		{"release hold", http.MethodPost, "/holds/1/release", ``},
+		{"set limits", http.MethodPut, "/limits", `{"tier":"standard"}`},
+		{"set credit", http.MethodPut, "/credit", `{"credit_limit":"100"}`},
+		{"set interest", http.MethodPut, "/interest", `{"rate":"1"}`},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(test.method, "/wallets/"+house+test.path, strings.NewReader(test.body)))
+
+			var res struct {
+				ErrCode string `json:"err_code"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != http.StatusForbidden || res.ErrCode != oops.ErrReservedWalletMessage {
+				t.Errorf("got %d %q, want %d %q", rec.Code, res.ErrCode, http.StatusForbidden, oops.ErrReservedWalletMessage)
+			}
+		})
+	}
+
+	// the money stays in the house wallet.
+	err = db.View(func(tx storage.Tx) error {
+		balance, err := tx.Balance(storage.WalletAccount(house, "USD"))
+		if err != nil {
+			return err
+		}
+		if balance != 10000 {
+			t.Errorf("house balance = %s, want 1", balance)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
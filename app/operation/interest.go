@@ -0,0 +1,54 @@
+package operation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"wallet/app/fx"
+)
+
+// LoadInterestRates reads JSON interest rates like {"default": 0.01,
+// "products": {"premium": 0.02}}, an empty path returns rates paying
+// no interest.
+func LoadInterestRates(path string) (InterestRates, error) {
+	if path == "" {
+		return InterestRates{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return InterestRates{}, fmt.Errorf("interest file read error: %w", err)
+	}
+
+	var rates InterestRates
+	if err = json.Unmarshal(data, &rates); err != nil {
+		return InterestRates{}, fmt.Errorf("interest file decode error: %w", err)
+	}
+
+	if !validInterest(rates.Default) {
+		return InterestRates{}, fmt.Errorf("interest file error: invalid default rate %s", rates.Default)
+	}
+	for name, rate := range rates.Products {
+		if !validInterest(rate) {
+			return InterestRates{}, fmt.Errorf("interest file error: invalid rate %s of product %q", rate, name)
+		}
+	}
+
+	return rates, nil
+}
+
+// Rate returns the rate of the product, or the default one
+// for a product without a rate.
+func (r InterestRates) Rate(product string) fx.Rate {
+	if rate, found := r.Products[product]; found {
+		return rate
+	}
+	return r.Default
+}
+
+// validInterest reports whether the percent is not negative and keeps
+// its precision as a fraction of the balance.
+func validInterest(rate fx.Rate) bool {
+	return rate >= 0 && rate%100 == 0
+}
@@ -0,0 +1,46 @@
+package operation
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// accrualDay returns the day a daily job scheduled at accrues:
+// the last whole UTC day before the scheduled time.
+func accrualDay(at time.Time) time.Time {
+	year, month, day := at.UTC().Date()
+	return time.Date(year, month, day-1, 0, 0, 0, 0, time.UTC)
+}
+
+// OverdraftJob is the scheduler job charging the overdraft interest
+// of the days up to the one before at, the days missed while the
+// service was down are charged by the next run. A wallet is charged
+// once a day however often the job runs.
+func (s *WalletService) OverdraftJob(ctx context.Context, at time.Time) error {
+	n, err := s.AccrueOverdrafts(ctx, accrualDay(at))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("overdraft interest charged to %d wallets", n)
+	}
+
+	return nil
+}
+
+// InterestJob is the scheduler job paying the interest of the days
+// up to the one before at, the days missed while the service was down
+// are paid by the next run. A day is paid once however often the job
+// runs.
+func (s *WalletService) InterestJob(ctx context.Context, at time.Time) error {
+	n, err := s.PayInterest(ctx, accrualDay(at))
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("interest paid to %d wallets", n)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package operation_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/fee"
+	"wallet/app/fx"
+	"wallet/app/money"
+	"wallet/app/operation"
+	"wallet/app/operation/store"
+	"wallet/app/scheduler"
+	"wallet/app/storage"
+	"wallet/app/wallet"
+	walletStorage "wallet/app/wallet/store"
+)
+
+// fakeClock moves to the end of every wait at once and
+// cancels the run when a wait would pass the end time.
+type fakeClock struct {
+	now, end time.Time
+	cancel   context.CancelFunc
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	if c.now.Add(d).After(c.end) {
+		c.cancel()
+		return nil
+	}
+
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+func TestInterestJobPaysOnceADay(t *testing.T) {
+	ctx := context.Background()
+	db := storage.NewMemory()
+
+	w, err := walletStorage.NewStorage(db).CreateWallet(ctx, wallet.Request{Name: "test", Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.NewStorage(db).Deposit(ctx, w.ID, 1000000, "USD", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	fees, err := fee.NewSchedule(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiers, err := operation.LoadTiers("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every run starts a new service and scheduler on the same
+	// storage, as a restart of the server does.
+	run := func(start, end time.Time) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		service := operation.NewWalletService(store.NewStorage(db), nil, fees, operation.HoldOptions{}, tiers, 0,
+			operation.InterestRates{Default: fx.One})
+		s := scheduler.New(&fakeClock{now: start, end: end, cancel: cancel})
+		if err := s.Add("interest", "@hourly", service.InterestJob); err != nil {
+			t.Fatal(err)
+		}
+		s.Run(ctx)
+	}
+
+	paid := func() []event.InterestPaid {
+		t.Helper()
+		var payments []event.InterestPaid
+		err := db.View(func(tx storage.Tx) error {
+			events, err := tx.Stream(w.ID)
+			if err != nil {
+				return err
+			}
+			for _, e := range events {
+				if e.Name != event.WalletInterestPaid {
+					continue
+				}
+				var p event.InterestPaid
+				if err = json.Unmarshal(e.Payload, &p); err != nil {
+					return err
+				}
+				payments = append(payments, p)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return payments
+	}
+
+	// the deposit is made today, so the interest is due from today on.
+	year, month, day := time.Now().UTC().Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	at := func(days, hours, minutes int) time.Time {
+		return today.AddDate(0, 0, days).Add(time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute)
+	}
+	date := func(days int) string {
+		return today.AddDate(0, 0, days).Format("2006-01-02")
+	}
+
+	for _, step := range []struct {
+		name       string
+		start, end time.Time
+		want       []string
+		balances   []money.Amount
+	}{
+		{
+			name:     "first day",
+			start:    at(0, 10, 30),
+			end:      at(1, 5, 0),
+			want:     []string{date(0)},
+			balances: []money.Amount{1000000},
+		},
+		{
+			name:     "restart",
+			start:    at(1, 5, 10),
+			end:      at(1, 23, 30),
+			want:     []string{date(0)},
+			balances: []money.Amount{1000000},
+		},
+		{
+			// every day of the gap is paid on its balance
+			// with the interest of the days before it.
+			name:     "restart after a gap",
+			start:    at(4, 1, 0),
+			end:      at(4, 2, 0),
+			want:     []string{date(0), date(1), date(2), date(3)},
+			balances: []money.Amount{1000000, 1010000, 1020100, 1030300},
+		},
+	} {
+		run(step.start, step.end)
+
+		got := paid()
+		if len(got) != len(step.want) {
+			t.Fatalf("%s: paid %+v, want on %v", step.name, got, step.want)
+		}
+		for i := range got {
+			if got[i].Date != step.want[i] || got[i].Balance != step.balances[i] {
+				t.Errorf("%s: paid on %s at %s, want on %s at %s", step.name,
+					got[i].Date, got[i].Balance, step.want[i], step.balances[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package operation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+)
+
+// defaultTiers is a tier table for local development, the
+// default tier sets no limits. Amounts are in 10^-4 units.
+var defaultTiers = Tiers{
+	Default: "standard",
+	Tiers: map[string]Tier{
+		"basic": {
+			"EUR": basicLimits,
+			"USD": basicLimits,
+		},
+		"standard": unlimited(),
+	},
+}
+
+var basicLimits = Limits{
+	MaxWithdrawal:   1000_0000,
+	DailyOutgoing:   2000_0000,
+	MonthlyOutgoing: 10000_0000,
+	HourlyTransfers: 10,
+	MaxBalance:      10000_0000,
+}
+
+// unlimited returns a tier with no limits in every known currency.
+func unlimited() Tier {
+	t := make(Tier)
+	for _, c := range money.Currencies() {
+		t[c] = Limits{}
+	}
+	return t
+}
+
+// LoadTiers reads a JSON tier table like {"default": "basic",
+// "tiers": {"basic": {"USD": {"max_withdrawal": 1000}}}}, the default
+// tier has limits in every known currency since new wallets get it.
+// An empty path returns the development table.
+func LoadTiers(path string) (Tiers, error) {
+	if path == "" {
+		return defaultTiers, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Tiers{}, fmt.Errorf("limits file read error: %w", err)
+	}
+
+	var tiers Tiers
+	if err = json.Unmarshal(data, &tiers); err != nil {
+		return Tiers{}, fmt.Errorf("limits file decode error: %w", err)
+	}
+
+	if err = tiers.validate(); err != nil {
+		return Tiers{}, fmt.Errorf("limits file error: %w", err)
+	}
+
+	return tiers, nil
+}
+
+// validate checks the currencies and the limits of the tiers.
+func (t Tiers) validate() error {
+	def, found := t.Tiers[t.Default]
+	if !found {
+		return fmt.Errorf("unknown default tier %q", t.Default)
+	}
+	for _, c := range money.Currencies() {
+		if _, found = def[c]; !found {
+			return fmt.Errorf("default tier %q has no %s limits", t.Default, c)
+		}
+	}
+
+	for name, tier := range t.Tiers {
+		for c, l := range tier {
+			if parsed, err := money.ParseCurrency(string(c)); err != nil || parsed != c {
+				return fmt.Errorf("tier %q has unknown currency %q", name, c)
+			}
+			if !l.valid() {
+				return fmt.Errorf("tier %q has a negative %s limit", name, c)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Name returns the tier name, the default one for an empty tier.
+func (t Tiers) Name(tier string) string {
+	if tier == "" {
+		return t.Default
+	}
+	return tier
+}
+
+// Resolve returns the tier name, the default one for an empty tier,
+// and its limits in the currency with the non-zero overrides applied,
+// found is false if the tier has no limits in the currency.
+func (t Tiers) Resolve(tier string, currency money.Currency, overrides Limits) (name string, l Limits, found bool) {
+	tier = t.Name(tier)
+
+	l, found = t.Tiers[tier][currency]
+	if overrides.MaxWithdrawal != 0 {
+		l.MaxWithdrawal = overrides.MaxWithdrawal
+	}
+	if overrides.DailyOutgoing != 0 {
+		l.DailyOutgoing = overrides.DailyOutgoing
+	}
+	if overrides.MonthlyOutgoing != 0 {
+		l.MonthlyOutgoing = overrides.MonthlyOutgoing
+	}
+	if overrides.HourlyTransfers != 0 {
+		l.HourlyTransfers = overrides.HourlyTransfers
+	}
+	if overrides.MaxBalance != 0 {
+		l.MaxBalance = overrides.MaxBalance
+	}
+
+	return tier, l, found
+}
+
+// valid reports whether no limit is negative.
+func (l Limits) valid() bool {
+	return l.MaxWithdrawal >= 0 && l.DailyOutgoing >= 0 && l.MonthlyOutgoing >= 0 &&
+		l.HourlyTransfers >= 0 && l.MaxBalance >= 0
+}
+
+// change is what an operation does to a wallet: withdrawal is the
+// amount of a withdrawal, out is taken from the balance and in is
+// added to it, transfer is set for a sent transfer.
+type change struct {
+	withdrawal, out, in money.Amount
+	transfer            bool
+}
+
+// guard returns a Guard of the change which resolves the wallet limits.
+func (t Tiers) guard(c change) Guard {
+	return func(s LimitState) error {
+		_, limits, found := t.Resolve(s.Tier, s.Currency, s.Overrides)
+		if !found {
+			return oops.ErrInvalidLimits
+		}
+		return limits.check(c, s.Usage)
+	}
+}
+
+// check returns the error of the first limit the change exceeds.
+func (l Limits) check(c change, u Usage) error {
+	if l.MaxWithdrawal != 0 && c.withdrawal > l.MaxWithdrawal {
+		return oops.ErrWithdrawalLimit
+	}
+	if l.DailyOutgoing != 0 && c.out != 0 && u.DailyOutgoing+c.out > l.DailyOutgoing {
+		return oops.ErrDailyLimit
+	}
+	if l.MonthlyOutgoing != 0 && c.out != 0 && u.MonthlyOutgoing+c.out > l.MonthlyOutgoing {
+		return oops.ErrMonthlyLimit
+	}
+	if l.HourlyTransfers != 0 && c.transfer && u.HourlyTransfers >= l.HourlyTransfers {
+		return oops.ErrTransferRate
+	}
+	if l.MaxBalance != 0 && c.in != 0 && u.Balance+c.in > l.MaxBalance {
+		return oops.ErrBalanceLimit
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package operation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+)
+
+// allCurrencies returns a JSON tier with the limits in every known currency.
+func allCurrencies(limits string) string {
+	var entries []string
+	for _, c := range money.Currencies() {
+		entries = append(entries, `"`+string(c)+`": `+limits)
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func TestLoadTiers(t *testing.T) {
+	tests := []struct {
+		name, file string
+		valid      bool
+	}{
+		{name: "valid", file: `{"default": "standard", "tiers": {"standard": ` + allCurrencies("{}") + `, "basic": {"USD": {"max_withdrawal": 1000}}}}`, valid: true},
+		{name: "unknown default", file: `{"default": "gold", "tiers": {"standard": ` + allCurrencies("{}") + `}}`},
+		{name: "default without a currency", file: `{"default": "basic", "tiers": {"basic": {"USD": {}}}}`},
+		{name: "unknown currency", file: `{"default": "standard", "tiers": {"standard": ` + allCurrencies("{}") + `, "basic": {"XXX": {}}}}`},
+		{name: "lower case currency", file: `{"default": "standard", "tiers": {"standard": ` + allCurrencies("{}") + `, "basic": {"usd": {}}}}`},
+		{name: "negative limit", file: `{"default": "standard", "tiers": {"standard": ` + allCurrencies("{}") + `, "basic": {"USD": {"max_balance": -1}}}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "limits.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadTiers(path)
+			if (err == nil) != tt.valid {
+				t.Fatalf("LoadTiers error = %v, want valid %t", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestGuardCurrency(t *testing.T) {
+	tiers := Tiers{
+		Default: "standard",
+		Tiers: map[string]Tier{
+			"standard": unlimited(),
+			"basic":    {"USD": {MaxWithdrawal: 100}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		tier     string
+		currency money.Currency
+		amount   money.Amount
+		err      error
+	}{
+		{name: "default tier", currency: "JPY", amount: 1000},
+		{name: "within the limit", tier: "basic", currency: "USD", amount: 100},
+		{name: "over the limit", tier: "basic", currency: "USD", amount: 101, err: oops.ErrWithdrawalLimit},
+		{name: "no limits in the currency", tier: "basic", currency: "EUR", amount: 1, err: oops.ErrInvalidLimits},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := tiers.guard(change{withdrawal: tt.amount, out: tt.amount})
+			err := guard(LimitState{Tier: tt.tier, Currency: tt.currency})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("guard error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDefaultTiers(t *testing.T) {
+	if err := defaultTiers.validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,90 +3,345 @@ package operation
 
 import (
 	"context"
-	"log"
+	"time"
 
-	"wallet/app/queue"
+	"wallet/app/fee"
+	"wallet/app/fx"
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage"
 )
 
-// WalletService has
+// WalletService has Store, RateSource, FeeSource, hold lifetimes, limit
+// tiers, the daily overdraft interest rate in percent and the interest
+// rates of positive balances.
+// Queue events are recorded by the Store in the outbox and published
+// by outbox.Relay. The money of the house fee wallets is moved by the
+// fees only, see storage.IsFeeWallet.
 type WalletService struct {
-	store    Store
-	producer queue.Service
+	store     Store
+	rates     RateSource
+	fees      FeeSource
+	holds     HoldOptions
+	tiers     Tiers
+	overdraft fx.Rate
+	interest  InterestRates
 }
 
 // NewWalletService ...
-func NewWalletService(store Store, producer queue.Service) *WalletService {
+func NewWalletService(store Store, rates RateSource, fees FeeSource, holds HoldOptions, tiers Tiers, overdraft fx.Rate, interest InterestRates) *WalletService {
 	return &WalletService{
-		store:    store,
-		producer: producer,
+		store:     store,
+		rates:     rates,
+		fees:      fees,
+		holds:     holds,
+		tiers:     tiers,
+		overdraft: overdraft,
+		interest:  interest,
 	}
 }
 
 // Deposit amount from request to the wallets's balance.
 func (s *WalletService) Deposit(ctx context.Context, id string, req Request) error {
-	err := s.store.Deposit(ctx, id, req.Amount)
+	currency, err := money.ParseCurrency(string(req.Currency))
 	if err != nil {
 		return err
 	}
 
-	// make a channel to catch an error from the goroutine.
-	errCh := make(chan error, 1)
-	defer close(errCh)
-
-	// publish message to the queue.
-	go s.producer.Operation("Wallet_Deposited", req.Amount, errCh)
+	err = currency.Check(req.Amount)
+	if err != nil {
+		return err
+	}
 
-	// catch error from the channel.
-	err = <-errCh
+	err = s.store.Deposit(ctx, id, req.Amount, currency, s.tiers.guard(change{in: req.Amount}))
 	if err != nil {
-		log.Printf("queue.Publish error: %s", err.Error())
+		return err
 	}
 
 	return nil
 }
 
-// Withdraw amount from wallet's balance.
-func (s *WalletService) Withdraw(ctx context.Context, id string, req Request) error {
-	err := s.store.Withdraw(ctx, id, req.Amount)
+// Withdraw amount from wallet's balance, the fee of
+// the withdrawal is charged on top of it.
+func (s *WalletService) Withdraw(ctx context.Context, id string, req Request) (fee.Fee, error) {
+	if storage.IsFeeWallet(id) {
+		return fee.Fee{}, oops.ErrReservedWallet
+	}
+
+	currency, err := money.ParseCurrency(string(req.Currency))
 	if err != nil {
-		return err
+		return fee.Fee{}, err
 	}
 
-	// make a channel to catch an error from the goroutine.
-	errCh := make(chan error, 1)
-	defer close(errCh)
+	err = currency.Check(req.Amount)
+	if err != nil {
+		return fee.Fee{}, err
+	}
 
-	// publish message to the queue.
-	go s.producer.Operation("Wallet_Withdrawn", req.Amount, errCh)
+	charge, err := s.fees.Fee(fee.Withdrawal, req.Amount, currency)
+	if err != nil {
+		return fee.Fee{}, err
+	}
 
-	// catch error from the channel.
-	err = <-errCh
+	guard := s.tiers.guard(change{withdrawal: req.Amount, out: req.Amount})
+	err = s.store.Withdraw(ctx, id, req.Amount, currency, charge.Amount, guard)
 	if err != nil {
-		log.Printf("queue.Publish error: %s", err.Error())
+		return fee.Fee{}, err
 	}
 
-	return nil
+	return charge, nil
 }
 
-// Transfer money from one wallet to another.
-func (s *WalletService) Transfer(ctx context.Context, id string, req TransferRequest) error {
-	err := s.store.Transfer(ctx, id, req)
+// Transfer money from one wallet to another, converting it
+// when the wallets have different currencies. The fee of the
+// transfer is charged to the sender on top of the amount.
+func (s *WalletService) Transfer(ctx context.Context, id string, req TransferRequest) (Transfer, error) {
+	if storage.IsFeeWallet(id) {
+		return Transfer{}, oops.ErrReservedWallet
+	}
+
+	currency, err := money.ParseCurrency(string(req.Currency))
 	if err != nil {
-		return err
+		return Transfer{}, err
 	}
 
-	// make a channel to catch an error from the goroutine.
-	errCh := make(chan error, 1)
-	defer close(errCh)
+	err = currency.Check(req.Amount)
+	if err != nil {
+		return Transfer{}, err
+	}
 
-	// publish message to the queue.
-	go s.producer.Operation("Wallet_Transfered", req.Amount, errCh)
+	if id == req.TransferTo {
+		return Transfer{}, oops.ErrSelfTransfer
+	}
 
-	// catch error from the channel.
-	err = <-errCh
+	target, err := s.store.Currency(ctx, req.TransferTo)
 	if err != nil {
-		log.Printf("queue.Publish error: %s", err.Error())
+		return Transfer{}, err
 	}
 
-	return nil
+	rate, err := s.rates.Rate(ctx, req.QuoteID, currency, target)
+	if err != nil {
+		return Transfer{}, err
+	}
+
+	transfer := Transfer{
+		From:           id,
+		To:             req.TransferTo,
+		Debit:          req.Amount,
+		DebitCurrency:  currency,
+		Credit:         rate.Convert(req.Amount, target),
+		CreditCurrency: target,
+		Rate:           rate,
+	}
+
+	// the amount may be rounded to zero in the target currency.
+	if !transfer.Credit.IsPositive() {
+		return Transfer{}, oops.ErrInvalidAmount
+	}
+
+	transfer.Fee, err = s.fees.Fee(fee.Transfer, transfer.Debit, currency)
+	if err != nil {
+		return Transfer{}, err
+	}
+
+	from := s.tiers.guard(change{out: transfer.Debit, transfer: true})
+	to := s.tiers.guard(change{in: transfer.Credit})
+	err = s.store.Transfer(ctx, transfer, from, to)
+	if err != nil {
+		return Transfer{}, err
+	}
+
+	return transfer, nil
+}
+
+// PlaceHold reserves an amount of the available balance.
+func (s *WalletService) PlaceHold(ctx context.Context, id string, req HoldRequest) (Hold, error) {
+	if storage.IsFeeWallet(id) {
+		return Hold{}, oops.ErrReservedWallet
+	}
+
+	currency, err := money.ParseCurrency(string(req.Currency))
+	if err != nil {
+		return Hold{}, err
+	}
+
+	err = currency.Check(req.Amount)
+	if err != nil {
+		return Hold{}, err
+	}
+
+	ttl := s.holds.TTL
+	if req.ExpiresIn != 0 {
+		if req.ExpiresIn < 0 || req.ExpiresIn > int64(s.holds.MaxTTL/time.Second) {
+			return Hold{}, oops.ErrInvalidExpiry
+		}
+		ttl = time.Duration(req.ExpiresIn) * time.Second
+	}
+
+	return s.store.PlaceHold(ctx, id, req.Amount, currency, time.Now().UTC().Add(ttl))
+}
+
+// CaptureHold takes a hold, or a part of it, from the wallet's balance.
+func (s *WalletService) CaptureHold(ctx context.Context, id, holdID string, req CaptureRequest) (Hold, error) {
+	if storage.IsFeeWallet(id) {
+		return Hold{}, oops.ErrReservedWallet
+	}
+
+	if req.Amount < 0 {
+		return Hold{}, oops.ErrInvalidAmount
+	}
+
+	// the guard needs the captured amount, a hold amount never changes.
+	amount := req.Amount
+	if amount == 0 {
+		h, err := s.store.Hold(ctx, id, holdID)
+		if err != nil {
+			return Hold{}, err
+		}
+		amount = h.Amount
+	}
+
+	return s.store.CaptureHold(ctx, id, holdID, req.Amount, s.tiers.guard(change{out: amount}))
+}
+
+// ReleaseHold gives a hold back to the available balance.
+func (s *WalletService) ReleaseHold(ctx context.Context, id, holdID string) (Hold, error) {
+	if storage.IsFeeWallet(id) {
+		return Hold{}, oops.ErrReservedWallet
+	}
+
+	return s.store.ReleaseHold(ctx, id, holdID)
+}
+
+// Hold returns a wallet hold.
+func (s *WalletService) Hold(ctx context.Context, id, holdID string) (Hold, error) {
+	return s.store.Hold(ctx, id, holdID)
+}
+
+// Holds returns wallet holds in order.
+func (s *WalletService) Holds(ctx context.Context, id string) ([]Hold, error) {
+	return s.store.Holds(ctx, id)
+}
+
+// ExpireHolds releases the holds expired by at.
+func (s *WalletService) ExpireHolds(ctx context.Context, at time.Time) (int, error) {
+	return s.store.ExpireHolds(ctx, at.UTC())
+}
+
+// Reverse refunds a completed transaction, or a part of it, with
+// compensating transactions linked to it.
+func (s *WalletService) Reverse(ctx context.Context, id string, req ReverseRequest) (Reversal, error) {
+	if req.Amount < 0 {
+		return Reversal{}, oops.ErrInvalidAmount
+	}
+
+	return s.store.Reverse(ctx, id, req.Amount)
+}
+
+// Limits returns the limits of a wallet and its current usage.
+func (s *WalletService) Limits(ctx context.Context, id string) (WalletLimits, error) {
+	state, err := s.store.Limits(ctx, id, time.Now().UTC())
+	if err != nil {
+		return WalletLimits{}, err
+	}
+
+	tier, limits, _ := s.tiers.Resolve(state.Tier, state.Currency, state.Overrides)
+	return WalletLimits{
+		WalletID:  id,
+		Tier:      tier,
+		Limits:    limits,
+		Overrides: state.Overrides,
+		Usage:     state.Usage,
+	}, nil
+}
+
+// SetLimits moves a wallet to a tier and replaces its limit overrides,
+// the tier must have limits in the wallet currency.
+func (s *WalletService) SetLimits(ctx context.Context, id string, req LimitsRequest) (WalletLimits, error) {
+	if storage.IsFeeWallet(id) {
+		return WalletLimits{}, oops.ErrReservedWallet
+	}
+
+	if !req.Overrides.valid() {
+		return WalletLimits{}, oops.ErrInvalidLimits
+	}
+
+	state, err := s.store.Limits(ctx, id, time.Now().UTC())
+	if err != nil {
+		return WalletLimits{}, err
+	}
+	if _, found := s.tiers.Tiers[s.tiers.Name(req.Tier)][state.Currency]; !found {
+		return WalletLimits{}, oops.ErrInvalidLimits
+	}
+
+	if err := s.store.SetLimits(ctx, id, req.Tier, req.Overrides); err != nil {
+		return WalletLimits{}, err
+	}
+
+	return s.Limits(ctx, id)
+}
+
+// SetCredit replaces the credit limit of a wallet, it can't be
+// lowered below the credit the wallet uses.
+func (s *WalletService) SetCredit(ctx context.Context, id string, req CreditRequest) (Credit, error) {
+	if storage.IsFeeWallet(id) {
+		return Credit{}, oops.ErrReservedWallet
+	}
+
+	if req.CreditLimit < 0 {
+		return Credit{}, oops.ErrInvalidCredit
+	}
+
+	return s.store.SetCredit(ctx, id, req.CreditLimit)
+}
+
+// AccrueOverdrafts charges the daily interest of the negative balances
+// for the UTC days up to day, a wallet is charged once a day. Nothing is
+// charged at a zero rate.
+func (s *WalletService) AccrueOverdrafts(ctx context.Context, day time.Time) (int, error) {
+	if s.overdraft == 0 {
+		return 0, nil
+	}
+
+	return s.store.AccrueOverdrafts(ctx, s.overdraft, day.UTC())
+}
+
+// SetInterest replaces the own daily interest rate of a wallet,
+// zero rate takes the rate of its product.
+func (s *WalletService) SetInterest(ctx context.Context, id string, req InterestRequest) (Interest, error) {
+	if storage.IsFeeWallet(id) {
+		return Interest{}, oops.ErrReservedWallet
+	}
+
+	if !validInterest(req.Rate) {
+		return Interest{}, oops.ErrInvalidInterest
+	}
+
+	tier, err := s.store.SetInterest(ctx, id, req.Rate)
+	if err != nil {
+		return Interest{}, err
+	}
+
+	return Interest{
+		WalletID:  id,
+		Product:   s.tiers.Name(tier),
+		Rate:      req.Rate,
+		Effective: s.interestRate(tier, req.Rate),
+	}, nil
+}
+
+// PayInterest pays the daily interest of the positive balances for the
+// UTC days up to day, a day is paid once however often it runs.
+func (s *WalletService) PayInterest(ctx context.Context, day time.Time) (int, error) {
+	return s.store.PayInterest(ctx, s.interestRate, day.UTC())
+}
+
+// interestRate is the InterestRate of the service: the own rate of
+// a wallet or the one of its product, the default tier for none.
+func (s *WalletService) interestRate(tier string, own fx.Rate) fx.Rate {
+	if own != 0 {
+		return own
+	}
+
+	return s.interest.Rate(s.tiers.Name(tier))
 }
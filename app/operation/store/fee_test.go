@@ -0,0 +1,103 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/oops"
+	"wallet/app/operation/store"
+	"wallet/app/storage"
+	"wallet/app/wallet"
+	walletStorage "wallet/app/wallet/store"
+)
+
+func TestFeeGoesToHouseWallet(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		id := newWallets(t, db, 1, 10000)[0]
+		house := openFeeWallet(t, db)
+
+		operations := store.NewStorage(db)
+		for i := 0; i < 2; i++ {
+			if err := operations.Withdraw(ctx, id, 1000, "USD", 50, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		last := func(db storage.DB) (wallet, fees storage.Transaction) {
+			t.Helper()
+			err := db.View(func(tx storage.Tx) error {
+				for _, b := range []struct {
+					id string
+					t  *storage.Transaction
+				}{{id, &wallet}, {house, &fees}} {
+					history, err := tx.History(b.id)
+					if err != nil {
+						return err
+					}
+					*b.t = history[len(history)-1]
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return wallet, fees
+		}
+
+		charged, collected := last(db)
+		if charged.Type != storage.TxFee || charged.BalanceAfter != 7900 {
+			t.Errorf("wallet transaction = %s balance %s, want fee balance 7900", charged.Type, charged.BalanceAfter)
+		}
+		if collected.Type != storage.TxFeeIncome || collected.Counterparty != id || collected.BalanceAfter != 100 {
+			t.Errorf("house transaction = %s from %s balance %s, want fee_income from %s balance 100",
+				collected.Type, collected.Counterparty, collected.BalanceAfter, id)
+		}
+
+		rebuilt := storage.NewMemory()
+		report, err := event.Rebuild(ctx, db, rebuilt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Mismatches) != 0 {
+			t.Fatalf("rebuild mismatches: %+v", report.Mismatches)
+		}
+		// the rebuilt transactions get the times of their events.
+		_, fees := last(rebuilt)
+		fees.CreatedAt, collected.CreatedAt = time.Time{}, time.Time{}
+		if fees != collected {
+			t.Errorf("rebuilt house transaction = %+v, want %+v", fees, collected)
+		}
+	})
+}
+
+// openFeeWallet opens the house fee wallet of USD and returns its id.
+func openFeeWallet(t *testing.T, db storage.DB) string {
+	t.Helper()
+	house := storage.FeeWallet("USD")
+
+	err := walletStorage.NewStorage(db).OpenWallet(context.Background(), house, wallet.Request{Name: "house fees USD", Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return house
+}
+
+func TestFeeWithoutHouseWallet(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		id := newWallets(t, db, 1, 10000)[0]
+
+		err := store.NewStorage(db).Withdraw(ctx, id, 1000, "USD", 50, nil)
+		if err == nil || errors.Is(err, oops.ErrNotFound) {
+			t.Fatalf("withdraw error = %v, want a fee wallet error", err)
+		}
+		if got := total(t, db, []string{id}); got != 10000 {
+			t.Errorf("balance = %s, want 10000", got)
+		}
+	})
+}
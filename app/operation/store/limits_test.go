@@ -0,0 +1,160 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wallet/app/money"
+	"wallet/app/operation"
+	"wallet/app/operation/store"
+	"wallet/app/storage"
+	"wallet/app/wallet"
+	walletStorage "wallet/app/wallet/store"
+)
+
+func TestLimitUsage(t *testing.T) {
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2030, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	type entry struct {
+		typ    string
+		amount money.Amount
+		at     time.Time
+	}
+	// the history is in time order with more than a batch
+	// of small withdrawals early in the month.
+	history := []entry{
+		{typ: storage.TxWithdrawal, amount: 1, at: at(4, 30, 23, 30)},
+		{typ: storage.TxTransferOut, amount: 10, at: at(5, 1, 10, 0)},
+	}
+	for i := 0; i < 600; i++ {
+		history = append(history, entry{typ: storage.TxWithdrawal, amount: 1, at: at(5, 2, 0, 0).Add(time.Duration(i) * time.Second)})
+	}
+	history = append(history,
+		entry{typ: storage.TxDeposit, amount: 5000, at: at(5, 14, 9, 0)},
+		entry{typ: storage.TxWithdrawal, amount: 100, at: at(5, 15, 8, 0)},
+		entry{typ: storage.TxTransferOut, amount: 1000, at: at(5, 15, 11, 30)},
+		entry{typ: storage.TxCapture, amount: 10000, at: at(5, 15, 11, 45)},
+	)
+
+	tests := []struct {
+		name  string
+		now   time.Time
+		usage operation.Usage
+	}{
+		{name: "same hour", now: at(5, 15, 12, 0), usage: operation.Usage{DailyOutgoing: 11100, MonthlyOutgoing: 11710, HourlyTransfers: 1}},
+		{name: "next day", now: at(5, 16, 0, 30), usage: operation.Usage{MonthlyOutgoing: 11710}},
+		{name: "next month", now: at(6, 1, 0, 20), usage: operation.Usage{}},
+	}
+
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		wallets := walletStorage.NewStorage(db)
+
+		var ids []string
+		for i := 0; i < 2; i++ {
+			w, err := wallets.CreateWallet(ctx, wallet.Request{Name: "test", Currency: "USD"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, w.ID)
+		}
+
+		// the other wallet sends a transfer with each transaction.
+		err := db.Update(func(tx storage.Tx) error {
+			for _, e := range history {
+				for i, id := range ids {
+					typ := e.typ
+					if i > 0 {
+						typ = storage.TxTransferOut
+					}
+					_, err := tx.AppendTransaction(storage.Transaction{
+						WalletID:  id,
+						Type:      typ,
+						Amount:    e.amount,
+						Currency:  "USD",
+						CreatedAt: e.at,
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		operations := store.NewStorage(db)
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				state, err := operations.Limits(ctx, ids[0], tt.now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if state.Usage != tt.usage {
+					t.Errorf("usage = %+v, want %+v", state.Usage, tt.usage)
+				}
+				if state.Currency != "USD" {
+					t.Errorf("currency = %s, want USD", state.Currency)
+				}
+			})
+		}
+	})
+}
+
+func TestLimitUsageClockBack(t *testing.T) {
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2030, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	// the clock goes back twice, the history isn't in time order.
+	history := []struct {
+		typ    string
+		amount money.Amount
+		at     time.Time
+	}{
+		{storage.TxWithdrawal, 1, at(4, 20, 10)},
+		{storage.TxWithdrawal, 10, at(5, 15, 10)},
+		{storage.TxWithdrawal, 100, at(4, 25, 10)},
+		{storage.TxWithdrawal, 1000, at(4, 26, 10)},
+		{storage.TxTransferOut, 10000, at(5, 15, 11).Add(45 * time.Minute)},
+		{storage.TxWithdrawal, 100000, at(5, 14, 9)},
+	}
+	want := operation.Usage{DailyOutgoing: 10010, MonthlyOutgoing: 110010, HourlyTransfers: 1}
+
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		id := newWallets(t, db, 1, 0)[0]
+
+		err := db.Update(func(tx storage.Tx) error {
+			for _, e := range history {
+				_, err := tx.AppendTransaction(storage.Transaction{
+					WalletID:  id,
+					Type:      e.typ,
+					Amount:    e.amount,
+					Currency:  "USD",
+					CreatedAt: e.at,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		state, err := store.NewStorage(db).Limits(ctx, id, at(5, 15, 12))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.Usage != want {
+			t.Errorf("usage = %+v, want %+v", state.Usage, want)
+		}
+	})
+}
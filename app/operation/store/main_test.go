@@ -0,0 +1,24 @@
+package store_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"wallet/app/storage"
+	"wallet/app/storage/bolt"
+)
+
+// forEachDB runs the test against the memory and the bolt storage.
+func forEachDB(t *testing.T, test func(*testing.T, storage.DB)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, storage.NewMemory())
+	})
+	t.Run("bolt", func(t *testing.T) {
+		db, err := bolt.Open(filepath.Join(t.TempDir(), "wallet.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		test(t, db)
+	})
+}
@@ -0,0 +1,240 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/fx"
+	"wallet/app/money"
+	"wallet/app/operation/store"
+	"wallet/app/storage"
+)
+
+func TestOverdraftGoesToHouseWallet(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		id := newWallets(t, db, 1, 0)[0]
+		house := openFeeWallet(t, db)
+		operations := store.NewStorage(db)
+
+		if _, err := operations.SetCredit(ctx, id, 1000000); err != nil {
+			t.Fatal(err)
+		}
+		if err := operations.Withdraw(ctx, id, 500000, "USD", 0, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		rate, err := fx.ParseRate("1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := operations.AccrueOverdrafts(ctx, rate, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Fatalf("charged %d wallets, want 1", n)
+		}
+
+		var names []string
+		err = db.View(func(tx storage.Tx) error {
+			balance, err := tx.Balance(storage.WalletAccount(house, "USD"))
+			if err != nil {
+				return err
+			}
+			if balance != 5000 {
+				t.Errorf("house balance = %s, want 0.5", balance)
+			}
+
+			fees, err := tx.Balance(storage.Account{Name: storage.AccountFees, Currency: "USD"})
+			if err != nil {
+				return err
+			}
+			if fees != 0 {
+				t.Errorf("house fee account = %s, want 0", fees)
+			}
+
+			events, err := tx.Events("", 100)
+			for _, e := range events {
+				names = append(names, e.Name)
+			}
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		last := names[len(names)-2:]
+		if last[0] != event.WalletOverdraftCharged || last[1] != event.WalletFeeCollected {
+			t.Errorf("last events = %q, want the charge and its collection", last)
+		}
+
+		report, err := event.Rebuild(ctx, db, storage.NewMemory())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Mismatches) != 0 {
+			t.Errorf("rebuild mismatches: %+v", report.Mismatches)
+		}
+	})
+}
+
+func TestOverdraftCappedByCredit(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		ids := newWallets(t, db, 2, 0)
+		openFeeWallet(t, db)
+		operations := store.NewStorage(db)
+
+		// the first wallet is at its limit, the second has half of it left.
+		for i, amount := range []money.Amount{100000, 50000} {
+			if _, err := operations.SetCredit(ctx, ids[i], 100000); err != nil {
+				t.Fatal(err)
+			}
+			if err := operations.Withdraw(ctx, ids[i], amount, "USD", 0, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		day := time.Now().UTC()
+		for _, step := range []struct {
+			name     string
+			rate     string
+			day      time.Time
+			charged  int
+			balances [2]money.Amount
+		}{
+			{"within credit", "10", day, 1, [2]money.Amount{-100000, -55000}},
+			{"same day", "10", day, 0, [2]money.Amount{-100000, -55000}},
+			{"capped", "200", day.AddDate(0, 0, 1), 1, [2]money.Amount{-100000, -100000}},
+			{"at limit", "10", day.AddDate(0, 0, 2), 0, [2]money.Amount{-100000, -100000}},
+		} {
+			rate, err := fx.ParseRate(step.rate)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := operations.AccrueOverdrafts(ctx, rate, step.day)
+			if err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			if n != step.charged {
+				t.Errorf("%s: charged %d wallets, want %d", step.name, n, step.charged)
+			}
+			err = db.View(func(tx storage.Tx) error {
+				for i, want := range step.balances {
+					got, err := tx.Balance(storage.WalletAccount(ids[i], "USD"))
+					if err != nil {
+						return err
+					}
+					if got != want {
+						t.Errorf("%s: balance of wallet %d = %s, want %s", step.name, i, got, want)
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestOverdraftCatchesUp(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		id := newWallets(t, db, 1, 0)[0]
+		openFeeWallet(t, db)
+		operations := store.NewStorage(db)
+
+		if _, err := operations.SetCredit(ctx, id, 10000000); err != nil {
+			t.Fatal(err)
+		}
+
+		// the job last ran on the first day, the wallet is overdrawn
+		// on the second one and gets the money back on the fourth one.
+		year, month, day := time.Now().UTC().AddDate(0, 0, -10).Date()
+		start := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		err := db.Update(func(tx storage.Tx) error {
+			err := tx.PutRun(storage.Run{Key: storage.OverdraftRun(start.Format("2006-01-02")), At: start})
+			if err != nil {
+				return err
+			}
+
+			balance := money.Amount(0)
+			for _, op := range []struct {
+				amount money.Amount
+				at     time.Time
+			}{
+				{-1000000, start.AddDate(0, 0, 1).Add(10 * time.Hour)},
+				{500000, start.AddDate(0, 0, 3).Add(10 * time.Hour)},
+			} {
+				_, err := tx.Post(
+					storage.Entry{Account: storage.WalletAccount(id, "USD"), Amount: op.amount},
+					storage.Entry{Account: storage.Account{Name: storage.AccountCashIn, Currency: "USD"}, Amount: -op.amount},
+				)
+				if err != nil {
+					return err
+				}
+
+				balance += op.amount
+				_, err = tx.AppendTransaction(storage.Transaction{
+					WalletID:     id,
+					Type:         storage.TxDeposit,
+					Amount:       op.amount,
+					BalanceAfter: balance,
+					Currency:     "USD",
+					CreatedAt:    op.at,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rate, err := fx.ParseRate("10")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the restart after the gap charges every day since the last run
+		// on the balance at its end with the interest of the days before:
+		// 10 and 11 of the overdraft, then 7.1 and 7.81 of the rest with
+		// the interest.
+		for _, step := range []struct {
+			name    string
+			day     time.Time
+			charged int
+			balance money.Amount
+		}{
+			{"after the gap", start.AddDate(0, 0, 4), 1, -859100},
+			{"same day", start.AddDate(0, 0, 4), 0, -859100},
+		} {
+			n, err := operations.AccrueOverdrafts(ctx, rate, step.day)
+			if err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			if n != step.charged {
+				t.Errorf("%s: charged %d wallets, want %d", step.name, n, step.charged)
+			}
+			err = db.View(func(tx storage.Tx) error {
+				got, err := tx.Balance(storage.WalletAccount(id, "USD"))
+				if err != nil {
+					return err
+				}
+				if got != step.balance {
+					t.Errorf("%s: balance = %s, want %s", step.name, got, step.balance)
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
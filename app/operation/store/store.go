@@ -0,0 +1,1594 @@
+// Package store contains all implementation to work
+// with wallet operations in storage.DB.
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/fx"
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/operation"
+	"wallet/app/storage"
+)
+
+// Storage works with wallet operations in storage.DB.
+type Storage struct {
+	db storage.DB
+}
+
+// NewStorage is a constructor for storage.
+func NewStorage(db storage.DB) *Storage {
+	return &Storage{
+		db: db,
+	}
+}
+
+// Deposit adds amount to the balance.
+func (s *Storage) Deposit(ctx context.Context, id string, amount money.Amount, currency money.Currency, guard operation.Guard) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		w, err := wallet(tx, id, currency)
+		if err != nil {
+			return err
+		}
+
+		before, err := balances(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err = check(tx, id, w, time.Now().UTC(), guard); err != nil {
+			return err
+		}
+
+		_, err = tx.Post(
+			storage.Entry{Account: storage.WalletAccount(id, currency), Amount: amount},
+			storage.Entry{Account: storage.Account{Name: storage.AccountCashIn, Currency: currency}, Amount: -amount},
+		)
+		if err != nil {
+			return err
+		}
+
+		t, err := appendTransaction(tx, storage.Transaction{
+			WalletID: id,
+			Type:     storage.TxDeposit,
+			Amount:   amount,
+			Currency: currency,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = event.Record(ctx, tx, id, event.WalletDeposited, event.Operation{
+			WalletID:      id,
+			TransactionID: t.ID,
+			Amount:        amount,
+			Currency:      currency,
+			BalanceAfter:  t.BalanceAfter,
+		})
+		if err != nil {
+			return err
+		}
+
+		return overdraft(ctx, tx, []string{id}, before)
+	})
+}
+
+// Withdraw takes amount and the fee on top of it from the balance.
+func (s *Storage) Withdraw(ctx context.Context, id string, amount money.Amount, currency money.Currency, fee money.Amount, guard operation.Guard) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		w, err := wallet(tx, id, currency)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		w, balance, err := available(ctx, tx, id, w, now)
+		if err != nil {
+			return err
+		}
+		if balance < amount+fee {
+			return oops.ErrNotEnoMon
+		}
+
+		before, err := balances(tx, id)
+		if err != nil {
+			return err
+		}
+
+		if err = check(tx, id, w, now, guard); err != nil {
+			return err
+		}
+
+		_, err = tx.Post(
+			storage.Entry{Account: storage.WalletAccount(id, currency), Amount: -amount},
+			storage.Entry{Account: storage.Account{Name: storage.AccountCashOut, Currency: currency}, Amount: amount},
+		)
+		if err != nil {
+			return err
+		}
+
+		t, err := appendTransaction(tx, storage.Transaction{
+			WalletID: id,
+			Type:     storage.TxWithdrawal,
+			Amount:   amount,
+			Currency: currency,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = event.Record(ctx, tx, id, event.WalletWithdrawn, event.Operation{
+			WalletID:      id,
+			TransactionID: t.ID,
+			Amount:        amount,
+			Currency:      currency,
+			BalanceAfter:  t.BalanceAfter,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err = chargeFee(ctx, tx, id, currency, fee, t.ID); err != nil {
+			return err
+		}
+
+		return overdraft(ctx, tx, []string{id}, before)
+	})
+}
+
+// Currency returns the currency of an active wallet.
+func (s *Storage) Currency(ctx context.Context, id string) (money.Currency, error) {
+	var currency money.Currency
+
+	err := s.db.View(func(tx storage.Tx) error {
+		w, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found || w.Status == "inactive" {
+			return oops.ErrNotFound
+		}
+
+		currency = w.Currency
+		return nil
+	})
+
+	return currency, err
+}
+
+// Transfer moves money between two wallets and charges its fee to the
+// sender in a single transaction, so a failed transfer never leaves
+// a partial update.
+func (s *Storage) Transfer(ctx context.Context, data operation.Transfer, fromGuard, toGuard operation.Guard) error {
+	if data.From == data.To {
+		return oops.ErrSelfTransfer
+	}
+
+	return s.db.Update(func(tx storage.Tx) error {
+		from, err := wallet(tx, data.From, data.DebitCurrency)
+		if err != nil {
+			return err
+		}
+
+		to, err := wallet(tx, data.To, data.CreditCurrency)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		from, balance, err := available(ctx, tx, data.From, from, now)
+		if err != nil {
+			return err
+		}
+		if balance < data.Debit+data.Fee.Amount {
+			return oops.ErrNotEnoMon
+		}
+
+		ids := []string{data.From, data.To}
+		before, err := balances(tx, ids...)
+		if err != nil {
+			return err
+		}
+
+		if err = check(tx, data.From, from, now, fromGuard); err != nil {
+			return err
+		}
+		if err = check(tx, data.To, to, now, toGuard); err != nil {
+			return err
+		}
+
+		// different currencies are exchanged through the fx account,
+		// so each currency of the posting stays balanced on its own.
+		entries := []storage.Entry{
+			{Account: storage.WalletAccount(data.From, data.DebitCurrency), Amount: -data.Debit},
+			{Account: storage.WalletAccount(data.To, data.CreditCurrency), Amount: data.Credit},
+		}
+		if data.DebitCurrency != data.CreditCurrency {
+			entries = append(entries,
+				storage.Entry{Account: storage.Account{Name: storage.AccountFX, Currency: data.DebitCurrency}, Amount: data.Debit},
+				storage.Entry{Account: storage.Account{Name: storage.AccountFX, Currency: data.CreditCurrency}, Amount: -data.Credit},
+			)
+		}
+
+		_, err = tx.Post(entries...)
+		if err != nil {
+			return err
+		}
+
+		out, err := appendTransaction(tx, storage.Transaction{
+			WalletID:     data.From,
+			Type:         storage.TxTransferOut,
+			Counterparty: data.To,
+			Amount:       data.Debit,
+			Currency:     data.DebitCurrency,
+			Rate:         data.Rate,
+		})
+		if err != nil {
+			return err
+		}
+
+		in, err := appendTransaction(tx, storage.Transaction{
+			WalletID:     data.To,
+			Type:         storage.TxTransferIn,
+			Counterparty: data.From,
+			Amount:       data.Credit,
+			Currency:     data.CreditCurrency,
+			Rate:         data.Rate,
+		})
+		if err != nil {
+			return err
+		}
+
+		// each wallet gets its own event, so the events
+		// of a wallet describe all its balance changes.
+		err = event.Record(ctx, tx, data.From, event.WalletTransferSent, event.Transfer{
+			WalletID:      data.From,
+			TransactionID: out.ID,
+			Counterparty:  data.To,
+			Amount:        data.Debit,
+			Currency:      data.DebitCurrency,
+			BalanceAfter:  out.BalanceAfter,
+			Rate:          data.Rate,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = event.Record(ctx, tx, data.To, event.WalletTransferReceived, event.Transfer{
+			WalletID:      data.To,
+			TransactionID: in.ID,
+			Counterparty:  data.From,
+			Amount:        data.Credit,
+			Currency:      data.CreditCurrency,
+			BalanceAfter:  in.BalanceAfter,
+			Rate:          data.Rate,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = chargeFee(ctx, tx, data.From, data.DebitCurrency, data.Fee.Amount, out.ID)
+		if err != nil {
+			return err
+		}
+
+		return overdraft(ctx, tx, ids, before)
+	})
+}
+
+// PlaceHold reserves the amount of the available balance.
+func (s *Storage) PlaceHold(ctx context.Context, id string, amount money.Amount, currency money.Currency, expiresAt time.Time) (operation.Hold, error) {
+	var hold storage.Hold
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		w, err := wallet(tx, id, currency)
+		if err != nil {
+			return err
+		}
+
+		w, balance, err := available(ctx, tx, id, w, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if balance < amount {
+			return oops.ErrNotEnoMon
+		}
+
+		hold, err = tx.AppendHold(storage.Hold{
+			WalletID:  id,
+			Status:    storage.HoldActive,
+			Amount:    amount,
+			Currency:  currency,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		if w.Held, err = w.Held.Add(amount); err != nil {
+			return err
+		}
+		if err = tx.PutWallet(id, w); err != nil {
+			return err
+		}
+
+		return event.Record(ctx, tx, id, event.WalletHoldPlaced, event.HoldPlaced{
+			WalletID:  id,
+			HoldID:    hold.ID,
+			Amount:    amount,
+			Currency:  currency,
+			ExpiresAt: expiresAt,
+		})
+	})
+
+	return toHold(hold), err
+}
+
+// CaptureHold takes the amount of an active hold from the balance,
+// the rest of the hold is released.
+func (s *Storage) CaptureHold(ctx context.Context, id, holdID string, amount money.Amount, guard operation.Guard) (operation.Hold, error) {
+	var hold storage.Hold
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		now := time.Now().UTC()
+
+		w, h, err := activeHold(tx, id, holdID, now)
+		if err != nil {
+			return err
+		}
+
+		if amount == 0 {
+			amount = h.Amount
+		}
+		if amount > h.Amount {
+			return oops.ErrInvalidAmount
+		}
+		if err = h.Currency.Check(amount); err != nil {
+			return err
+		}
+
+		if err = check(tx, id, w, now, guard); err != nil {
+			return err
+		}
+
+		before, err := balances(tx, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Post(
+			storage.Entry{Account: storage.WalletAccount(id, h.Currency), Amount: -amount},
+			storage.Entry{Account: storage.Account{Name: storage.AccountCashOut, Currency: h.Currency}, Amount: amount},
+		)
+		if err != nil {
+			return err
+		}
+
+		t, err := appendTransaction(tx, storage.Transaction{
+			WalletID: id,
+			Type:     storage.TxCapture,
+			Amount:   amount,
+			Currency: h.Currency,
+		})
+		if err != nil {
+			return err
+		}
+
+		h.Status, h.Captured, h.TransactionID, h.ClosedAt = storage.HoldCaptured, amount, t.ID, t.CreatedAt
+		if err = tx.PutHold(h); err != nil {
+			return err
+		}
+
+		w.Held -= h.Amount
+		if err = tx.PutWallet(id, w); err != nil {
+			return err
+		}
+
+		hold = h
+		err = event.Record(ctx, tx, id, event.WalletHoldCaptured, event.HoldCaptured{
+			WalletID:      id,
+			HoldID:        h.ID,
+			TransactionID: t.ID,
+			Amount:        amount,
+			Released:      h.Amount - amount,
+			Currency:      h.Currency,
+			BalanceAfter:  t.BalanceAfter,
+		})
+		if err != nil {
+			return err
+		}
+
+		return overdraft(ctx, tx, []string{id}, before)
+	})
+
+	return toHold(hold), err
+}
+
+// ReleaseHold gives an active hold back to the available balance.
+func (s *Storage) ReleaseHold(ctx context.Context, id, holdID string) (operation.Hold, error) {
+	var hold storage.Hold
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		now := time.Now().UTC()
+
+		w, h, err := activeHold(tx, id, holdID, now)
+		if err != nil {
+			return err
+		}
+
+		h.Status, h.ClosedAt = storage.HoldReleased, now
+		hold = h
+		_, err = release(ctx, tx, w, h)
+		return err
+	})
+
+	return toHold(hold), err
+}
+
+// Hold returns a wallet hold.
+func (s *Storage) Hold(ctx context.Context, id, holdID string) (operation.Hold, error) {
+	var hold storage.Hold
+
+	err := s.db.View(func(tx storage.Tx) error {
+		h, found, err := tx.Hold(holdID)
+		if err != nil {
+			return err
+		}
+		if !found || h.WalletID != id {
+			return oops.ErrHoldNotFound
+		}
+
+		hold = h
+		return nil
+	})
+
+	return toHold(hold), err
+}
+
+// Holds returns wallet holds in order.
+func (s *Storage) Holds(ctx context.Context, id string) ([]operation.Hold, error) {
+	var holds []operation.Hold
+
+	err := s.db.View(func(tx storage.Tx) error {
+		_, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return oops.ErrNotFound
+		}
+
+		stored, err := tx.Holds(id)
+		if err != nil {
+			return err
+		}
+
+		holds = make([]operation.Hold, 0, len(stored))
+		for _, h := range stored {
+			holds = append(holds, toHold(h))
+		}
+
+		return nil
+	})
+
+	return holds, err
+}
+
+// ExpireHolds releases the active holds of all wallets expired by now.
+func (s *Storage) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	n := 0
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		holds, err := tx.ActiveHolds()
+		if err != nil {
+			return err
+		}
+
+		for _, h := range holds {
+			if now.Before(h.ExpiresAt) {
+				continue
+			}
+
+			w, _, err := tx.Wallet(h.WalletID)
+			if err != nil {
+				return err
+			}
+
+			h.Status, h.ClosedAt = storage.HoldExpired, now
+			if _, err = release(ctx, tx, w, h); err != nil {
+				return err
+			}
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// Reverse posts the compensating entries of the amount of a transaction:
+// a deposit is taken back from the wallet, a withdrawal or a capture is
+// given back to it and a transfer is moved back from the receiver to the
+// sender. The wallet the money is taken from must have it available.
+func (s *Storage) Reverse(ctx context.Context, id string, amount money.Amount) (operation.Reversal, error) {
+	var reversal operation.Reversal
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		t, found, err := tx.Transaction(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return oops.ErrTransactionNotFound
+		}
+
+		switch t.Type {
+		case storage.TxDeposit, storage.TxWithdrawal, storage.TxCapture, storage.TxTransferOut, storage.TxTransferIn:
+		default:
+			return oops.ErrNotReversible
+		}
+
+		remaining, err := remainder(tx, t)
+		if err != nil {
+			return err
+		}
+		if remaining == 0 {
+			return oops.ErrAlreadyReversed
+		}
+
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount > remaining {
+			return oops.ErrInvalidAmount
+		}
+		if err = t.Currency.Check(amount); err != nil {
+			return err
+		}
+
+		// a transfer changes the balance of its counterparty too.
+		ids := []string{t.WalletID}
+		if t.Counterparty != "" {
+			ids = append(ids, t.Counterparty)
+		}
+		before, err := balances(tx, ids...)
+		if err != nil {
+			return err
+		}
+
+		var compensating []storage.Transaction
+		if t.Type == storage.TxTransferOut || t.Type == storage.TxTransferIn {
+			compensating, err = reverseTransfer(ctx, tx, t, amount, remaining)
+		} else {
+			compensating, err = reverseOperation(ctx, tx, t, amount)
+		}
+		if err != nil {
+			return err
+		}
+
+		reversal = operation.Reversal{
+			TransactionID: t.ID,
+			Amount:        amount,
+			Remaining:     remaining - amount,
+			Currency:      t.Currency,
+			Transactions:  make([]operation.ReversalTransaction, 0, len(compensating)),
+		}
+		for _, c := range compensating {
+			reversal.Transactions = append(reversal.Transactions, operation.ReversalTransaction{
+				ID:           c.ID,
+				WalletID:     c.WalletID,
+				Type:         c.Type,
+				Amount:       c.Amount,
+				Currency:     c.Currency,
+				BalanceAfter: c.BalanceAfter,
+			})
+		}
+
+		return overdraft(ctx, tx, ids, before)
+	})
+
+	return reversal, err
+}
+
+// reverseOperation takes the amount of a reversed deposit back from
+// the wallet or gives the amount of a withdrawal or a capture back.
+func reverseOperation(ctx context.Context, tx storage.Tx, t storage.Transaction, amount money.Amount) ([]storage.Transaction, error) {
+	w, err := wallet(tx, t.WalletID, t.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, cash, change := storage.TxReversalIn, storage.AccountCashOut, amount
+	if t.Type == storage.TxDeposit {
+		typ, cash, change = storage.TxReversalOut, storage.AccountCashIn, -amount
+
+		_, balance, err := available(ctx, tx, t.WalletID, w, time.Now().UTC())
+		if err != nil {
+			return nil, err
+		}
+		if balance < amount {
+			return nil, oops.ErrNotEnoMon
+		}
+	}
+
+	_, err = tx.Post(
+		storage.Entry{Account: storage.WalletAccount(t.WalletID, t.Currency), Amount: change},
+		storage.Entry{Account: storage.Account{Name: cash, Currency: t.Currency}, Amount: -change},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := appendTransaction(tx, storage.Transaction{
+		WalletID: t.WalletID,
+		Type:     typ,
+		Amount:   amount,
+		Currency: t.Currency,
+		Reverses: t.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []storage.Transaction{r}, event.Record(ctx, tx, r.WalletID, event.TransactionReversed, reversed(r))
+}
+
+// reverseTransfer moves the amount of a reversed transfer back from the
+// receiver to the sender. The amount is in the currency of the half t,
+// the other half is reversed by the amount converted with the transfer
+// rate or, when t is reversed completely, by all of its rest.
+func reverseTransfer(ctx context.Context, tx storage.Tx, t storage.Transaction, amount, remaining money.Amount) ([]storage.Transaction, error) {
+	out, in, err := transferHalves(tx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	other := in
+	if t.Type == storage.TxTransferIn {
+		other = out
+	}
+	rest, err := remainder(tx, other)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := rest
+	if amount != remaining {
+		if t.Type == storage.TxTransferOut {
+			converted = t.Rate.Convert(amount, in.Currency)
+		} else {
+			converted = t.Rate.Inverse().Convert(amount, out.Currency)
+		}
+		if converted > rest {
+			converted = rest
+		}
+	}
+	// the amount may be rounded to zero in the other currency.
+	if !converted.IsPositive() {
+		return nil, oops.ErrInvalidAmount
+	}
+
+	debit, credit := converted, amount
+	if t.Type == storage.TxTransferIn {
+		debit, credit = amount, converted
+	}
+
+	receiver, err := wallet(tx, in.WalletID, in.Currency)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = wallet(tx, out.WalletID, out.Currency); err != nil {
+		return nil, err
+	}
+
+	_, balance, err := available(ctx, tx, in.WalletID, receiver, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	if balance < debit {
+		return nil, oops.ErrNotEnoMon
+	}
+
+	entries := []storage.Entry{
+		{Account: storage.WalletAccount(in.WalletID, in.Currency), Amount: -debit},
+		{Account: storage.WalletAccount(out.WalletID, out.Currency), Amount: credit},
+	}
+	if in.Currency != out.Currency {
+		entries = append(entries,
+			storage.Entry{Account: storage.Account{Name: storage.AccountFX, Currency: in.Currency}, Amount: debit},
+			storage.Entry{Account: storage.Account{Name: storage.AccountFX, Currency: out.Currency}, Amount: -credit},
+		)
+	}
+
+	if _, err = tx.Post(entries...); err != nil {
+		return nil, err
+	}
+
+	rOut, err := appendTransaction(tx, storage.Transaction{
+		WalletID:     in.WalletID,
+		Type:         storage.TxReversalOut,
+		Counterparty: out.WalletID,
+		Amount:       debit,
+		Currency:     in.Currency,
+		Reverses:     in.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rIn, err := appendTransaction(tx, storage.Transaction{
+		WalletID:     out.WalletID,
+		Type:         storage.TxReversalIn,
+		Counterparty: in.WalletID,
+		Amount:       credit,
+		Currency:     out.Currency,
+		Reverses:     out.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// each wallet gets its own event like for the transfer itself.
+	if err = event.Record(ctx, tx, rOut.WalletID, event.TransactionReversed, reversed(rOut)); err != nil {
+		return nil, err
+	}
+
+	return []storage.Transaction{rOut, rIn}, event.Record(ctx, tx, rIn.WalletID, event.TransactionReversed, reversed(rIn))
+}
+
+// transferHalves returns the sent and the received halves of the transfer
+// of t, Transfer appends the received half right after the sent one.
+func transferHalves(tx storage.Tx, t storage.Transaction) (storage.Transaction, storage.Transaction, error) {
+	seq, err := strconv.ParseUint(t.ID, 10, 64)
+	if err != nil {
+		return storage.Transaction{}, storage.Transaction{}, fmt.Errorf("transaction %s id error: %w", t.ID, err)
+	}
+
+	out, in := t, t
+	found := false
+	if t.Type == storage.TxTransferOut {
+		in, found, err = tx.Transaction(strconv.FormatUint(seq+1, 10))
+	} else {
+		out, found, err = tx.Transaction(strconv.FormatUint(seq-1, 10))
+	}
+	if err != nil {
+		return storage.Transaction{}, storage.Transaction{}, err
+	}
+
+	if !found || out.Type != storage.TxTransferOut || in.Type != storage.TxTransferIn ||
+		out.Counterparty != in.WalletID || in.Counterparty != out.WalletID {
+		return storage.Transaction{}, storage.Transaction{}, fmt.Errorf("transaction %s: the other half of the transfer is not found", t.ID)
+	}
+
+	return out, in, nil
+}
+
+// remainder returns the amount of the transaction which is not reversed yet.
+func remainder(tx storage.Tx, t storage.Transaction) (money.Amount, error) {
+	reversals, err := tx.Reversals(t.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	rest := t.Amount
+	for _, r := range reversals {
+		rest -= r.Amount
+	}
+	return rest, nil
+}
+
+// reversed returns the event payload of a compensating transaction.
+func reversed(t storage.Transaction) event.Reversed {
+	return event.Reversed{
+		WalletID:      t.WalletID,
+		TransactionID: t.ID,
+		Reverses:      t.Reverses,
+		Type:          t.Type,
+		Counterparty:  t.Counterparty,
+		Amount:        t.Amount,
+		Currency:      t.Currency,
+		BalanceAfter:  t.BalanceAfter,
+	}
+}
+
+// Limits returns the limit settings and the usage of an active wallet at now.
+func (s *Storage) Limits(ctx context.Context, id string, now time.Time) (operation.LimitState, error) {
+	var state operation.LimitState
+
+	err := s.db.View(func(tx storage.Tx) error {
+		w, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found || w.Status == "inactive" {
+			return oops.ErrNotFound
+		}
+
+		state, err = limitState(tx, id, w, now)
+		return err
+	})
+
+	return state, err
+}
+
+// SetLimits replaces the tier and the limit overrides of an active wallet.
+func (s *Storage) SetLimits(ctx context.Context, id, tier string, overrides operation.Limits) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		w, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found || w.Status == "inactive" {
+			return oops.ErrNotFound
+		}
+
+		w.Tier = tier
+		w.Limits = storage.Limits{
+			MaxWithdrawal:   overrides.MaxWithdrawal,
+			DailyOutgoing:   overrides.DailyOutgoing,
+			MonthlyOutgoing: overrides.MonthlyOutgoing,
+			HourlyTransfers: overrides.HourlyTransfers,
+			MaxBalance:      overrides.MaxBalance,
+		}
+		if err = tx.PutWallet(id, w); err != nil {
+			return err
+		}
+
+		return event.Record(ctx, tx, id, event.WalletLimitsChanged, event.LimitsChanged{
+			WalletID:        id,
+			Tier:            tier,
+			MaxWithdrawal:   overrides.MaxWithdrawal,
+			DailyOutgoing:   overrides.DailyOutgoing,
+			MonthlyOutgoing: overrides.MonthlyOutgoing,
+			HourlyTransfers: overrides.HourlyTransfers,
+			MaxBalance:      overrides.MaxBalance,
+		})
+	})
+}
+
+// SetCredit replaces the credit limit of a wallet, the balance
+// with the active holds must stay within the new limit.
+func (s *Storage) SetCredit(ctx context.Context, id string, limit money.Amount) (operation.Credit, error) {
+	var credit operation.Credit
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		w, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found || w.Status == "inactive" {
+			return oops.ErrNotFound
+		}
+
+		if !limit.Fits(w.Currency.Precision()) {
+			return oops.ErrInvalidCredit
+		}
+
+		w, spendable, err := available(ctx, tx, id, w, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		if spendable-w.CreditLimit+limit < 0 {
+			return oops.ErrCreditInUse
+		}
+
+		w.CreditLimit = limit
+		if err = tx.PutWallet(id, w); err != nil {
+			return err
+		}
+
+		balance, err := tx.Balance(storage.WalletAccount(id, w.Currency))
+		if err != nil {
+			return err
+		}
+
+		credit = operation.Credit{
+			WalletID:        id,
+			Currency:        w.Currency,
+			Balance:         balance,
+			CreditLimit:     limit,
+			AvailableCredit: w.Credit(balance),
+			Overdrawn:       balance < 0,
+		}
+
+		return event.Record(ctx, tx, id, event.WalletCreditChanged, event.CreditChanged{
+			WalletID:    id,
+			CreditLimit: limit,
+			Currency:    w.Currency,
+		})
+	})
+
+	return credit, err
+}
+
+// AccrueOverdrafts takes the interest of the negative balances into the
+// house fee wallets for the days not run yet, no more than the credit a
+// wallet has left. A day is charged on the balance the wallet had at its
+// end with the interest of the days before it, the date of the last
+// charge is kept with the wallet, so a wallet is charged once a day
+// however often it runs.
+func (s *Storage) AccrueOverdrafts(ctx context.Context, rate fx.Rate, day time.Time) (int, error) {
+	n := 0
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		wallets, err := tx.Wallets()
+		if err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(wallets))
+		for id := range wallets {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		days, err := unrunDays(tx, storage.OverdraftRun, ids, day)
+		if err != nil || len(days) == 0 {
+			return err
+		}
+
+		for _, id := range ids {
+			w := wallets[id]
+			if w.Status == "inactive" {
+				continue
+			}
+
+			balance, err := tx.Balance(storage.WalletAccount(id, w.Currency))
+			if err != nil {
+				return err
+			}
+
+			balances, err := balancesAt(tx, id, days)
+			if err != nil {
+				return err
+			}
+
+			credit, charged := w.Credit(balance), money.Amount(0)
+			for i, d := range days {
+				date := d.Format(dateLayout)
+				if w.ChargedOn >= date {
+					continue
+				}
+
+				overdraft := charged - balances[i]
+				if overdraft <= 0 {
+					continue
+				}
+
+				// the interest of a small overdraft may be rounded to zero,
+				// a wallet at its credit limit isn't charged.
+				amount := (rate / 100).Convert(overdraft, w.Currency)
+				if amount > credit {
+					amount = credit
+				}
+				if amount == 0 {
+					continue
+				}
+
+				house, err := feeWallet(tx, w.Currency)
+				if err != nil {
+					return err
+				}
+
+				_, err = tx.Post(
+					storage.Entry{Account: storage.WalletAccount(id, w.Currency), Amount: -amount},
+					storage.Entry{Account: storage.WalletAccount(house, w.Currency), Amount: amount},
+				)
+				if err != nil {
+					return err
+				}
+
+				t, err := appendTransaction(tx, storage.Transaction{
+					WalletID: id,
+					Type:     storage.TxOverdraftInterest,
+					Amount:   amount,
+					Currency: w.Currency,
+				})
+				if err != nil {
+					return err
+				}
+
+				w.ChargedOn = date
+				if err = tx.PutWallet(id, w); err != nil {
+					return err
+				}
+
+				err = event.Record(ctx, tx, id, event.WalletOverdraftCharged, event.OverdraftCharged{
+					WalletID:      id,
+					TransactionID: t.ID,
+					Date:          date,
+					Overdraft:     overdraft,
+					Rate:          rate,
+					Amount:        amount,
+					Currency:      w.Currency,
+					BalanceAfter:  t.BalanceAfter,
+					FeeWallet:     house,
+				})
+				if err != nil {
+					return err
+				}
+				if err = collectFee(ctx, tx, house, id, w.Currency, amount, t.ID); err != nil {
+					return err
+				}
+
+				credit -= amount
+				charged += amount
+			}
+			if charged > 0 {
+				n++
+			}
+		}
+
+		return putRuns(tx, storage.OverdraftRun, days)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// SetInterest replaces the own interest rate of an active wallet.
+func (s *Storage) SetInterest(ctx context.Context, id string, rate fx.Rate) (string, error) {
+	var tier string
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		w, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found || w.Status == "inactive" {
+			return oops.ErrNotFound
+		}
+
+		w.InterestRate = rate
+		if err = tx.PutWallet(id, w); err != nil {
+			return err
+		}
+		tier = w.Tier
+
+		return event.Record(ctx, tx, id, event.WalletInterestChanged, event.InterestChanged{
+			WalletID: id,
+			Rate:     rate,
+		})
+	})
+
+	return tier, err
+}
+
+// PayInterest gives the interest of the positive balances from the house
+// interest account for the days not paid yet. A day is paid on the balance
+// the wallet had at its end with the interest of the days before it. The
+// runs of the dates are kept with the payments in one unit of work, so a
+// date is paid once even across restarts.
+func (s *Storage) PayInterest(ctx context.Context, rate operation.InterestRate, day time.Time) (int, error) {
+	n := 0
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		wallets, err := tx.Wallets()
+		if err != nil {
+			return err
+		}
+
+		ids := make([]string, 0, len(wallets))
+		for id := range wallets {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		days, err := unrunDays(tx, storage.InterestRun, ids, day)
+		if err != nil || len(days) == 0 {
+			return err
+		}
+
+		for _, id := range ids {
+			w := wallets[id]
+			if w.Status == "inactive" {
+				continue
+			}
+
+			r := rate(w.Tier, w.InterestRate)
+			if r <= 0 {
+				continue
+			}
+
+			balances, err := balancesAt(tx, id, days)
+			if err != nil {
+				return err
+			}
+
+			var paid money.Amount
+			for i, d := range days {
+				balance := balances[i] + paid
+				if balance <= 0 {
+					continue
+				}
+
+				// the interest of a small balance may be rounded to zero.
+				amount := (r / 100).Convert(balance, w.Currency)
+				if amount == 0 {
+					continue
+				}
+
+				_, err = tx.Post(
+					storage.Entry{Account: storage.WalletAccount(id, w.Currency), Amount: amount},
+					storage.Entry{Account: storage.Account{Name: storage.AccountInterest, Currency: w.Currency}, Amount: -amount},
+				)
+				if err != nil {
+					return err
+				}
+
+				t, err := appendTransaction(tx, storage.Transaction{
+					WalletID: id,
+					Type:     storage.TxInterest,
+					Amount:   amount,
+					Currency: w.Currency,
+				})
+				if err != nil {
+					return err
+				}
+
+				err = event.Record(ctx, tx, id, event.WalletInterestPaid, event.InterestPaid{
+					WalletID:      id,
+					TransactionID: t.ID,
+					Date:          d.Format(dateLayout),
+					Balance:       balance,
+					Rate:          r,
+					Amount:        amount,
+					Currency:      w.Currency,
+					BalanceAfter:  t.BalanceAfter,
+				})
+				if err != nil {
+					return err
+				}
+
+				paid += amount
+			}
+			if paid > 0 {
+				n++
+			}
+		}
+
+		return putRuns(tx, storage.InterestRun, days)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
+}
+
+// dateLayout is the layout of the dates the daily jobs run for.
+const dateLayout = "2006-01-02"
+
+// unrunDays returns the UTC days after the last run of a daily job up to
+// the day in order, run is the run key of a date. The first run of a job
+// starts from the day of the first transaction of the wallets.
+func unrunDays(tx storage.Tx, run func(date string) string, ids []string, day time.Time) ([]time.Time, error) {
+	var first time.Time
+	for _, id := range ids {
+		history, err := tx.HistoryAfter(id, "", 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(history) > 0 && (first.IsZero() || history[0].CreatedAt.Before(first)) {
+			first = history[0].CreatedAt
+		}
+	}
+	if first.IsZero() {
+		return nil, nil
+	}
+	first = first.UTC().Truncate(24 * time.Hour)
+
+	var days []time.Time
+	for d := day.UTC().Truncate(24 * time.Hour); !d.Before(first); d = d.AddDate(0, 0, -1) {
+		_, found, err := tx.Run(run(d.Format(dateLayout)))
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			break
+		}
+		days = append(days, d)
+	}
+
+	for i, j := 0, len(days)-1; i < j; i, j = i+1, j-1 {
+		days[i], days[j] = days[j], days[i]
+	}
+	return days, nil
+}
+
+// balancesAt returns the wallet balances at the ends of the UTC days,
+// they are read before the job appends to the history of the wallet.
+func balancesAt(tx storage.Tx, id string, days []time.Time) ([]money.Amount, error) {
+	balances := make([]money.Amount, len(days))
+	for i, d := range days {
+		t, found, err := tx.HistoryBefore(id, d.AddDate(0, 0, 1))
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			balances[i] = t.BalanceAfter
+		}
+	}
+
+	return balances, nil
+}
+
+// putRuns records the runs of a daily job for the days.
+func putRuns(tx storage.Tx, run func(date string) string, days []time.Time) error {
+	now := time.Now().UTC()
+	for _, d := range days {
+		if err := tx.PutRun(storage.Run{Key: run(d.Format(dateLayout)), At: now}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// check runs the guard with the limit state of the wallet at now,
+// an operation without a guard isn't limited.
+func check(tx storage.Tx, id string, w storage.Wallet, now time.Time, guard operation.Guard) error {
+	if guard == nil {
+		return nil
+	}
+
+	state, err := limitState(tx, id, w, now)
+	if err != nil {
+		return err
+	}
+
+	return guard(state)
+}
+
+// usageBatch is the number of transactions read at once for the usage.
+const usageBatch = 500
+
+// limitState returns the limit settings of the wallet with its usage at now:
+// withdrawals, sent transfers and captures of the current UTC day and month
+// are outgoing and the transfers sent after an hour ago are counted.
+func limitState(tx storage.Tx, id string, w storage.Wallet, now time.Time) (operation.LimitState, error) {
+	balance, err := tx.Balance(storage.WalletAccount(id, w.Currency))
+	if err != nil {
+		return operation.LimitState{}, err
+	}
+
+	year, month, day := now.Date()
+	dayStart := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	monthStart := time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	hourAgo := now.Add(-time.Hour)
+
+	usage := operation.Usage{Balance: balance}
+
+	// only the transactions after the oldest window start are read,
+	// the history is sought to it.
+	start := monthStart
+	if hourAgo.Before(start) {
+		start = hourAgo
+	}
+	last, found, err := tx.HistoryBefore(id, start)
+	if err != nil {
+		return operation.LimitState{}, err
+	}
+
+	after := ""
+	if found {
+		after = last.ID
+	}
+	for {
+		batch, err := tx.HistoryAfter(id, after, usageBatch)
+		if err != nil {
+			return operation.LimitState{}, err
+		}
+
+		for _, t := range batch {
+			switch t.Type {
+			case storage.TxWithdrawal, storage.TxTransferOut, storage.TxCapture:
+			default:
+				continue
+			}
+
+			if !t.CreatedAt.Before(monthStart) {
+				usage.MonthlyOutgoing += t.Amount
+			}
+			if !t.CreatedAt.Before(dayStart) {
+				usage.DailyOutgoing += t.Amount
+			}
+			if t.Type == storage.TxTransferOut && t.CreatedAt.After(hourAgo) {
+				usage.HourlyTransfers++
+			}
+		}
+
+		if len(batch) < usageBatch {
+			break
+		}
+		after = batch[len(batch)-1].ID
+	}
+
+	return operation.LimitState{
+		Tier:     w.Tier,
+		Currency: w.Currency,
+		Overrides: operation.Limits{
+			MaxWithdrawal:   w.Limits.MaxWithdrawal,
+			DailyOutgoing:   w.Limits.DailyOutgoing,
+			MonthlyOutgoing: w.Limits.MonthlyOutgoing,
+			HourlyTransfers: w.Limits.HourlyTransfers,
+			MaxBalance:      w.Limits.MaxBalance,
+		},
+		Usage: usage,
+	}, nil
+}
+
+// balances returns the balances of the wallets before an operation,
+// see overdraft.
+func balances(tx storage.Tx, ids ...string) ([]money.Amount, error) {
+	before := make([]money.Amount, len(ids))
+	for i, id := range ids {
+		balance, err := storage.WalletBalance(tx, id)
+		if err != nil {
+			return nil, err
+		}
+		before[i] = balance
+	}
+
+	return before, nil
+}
+
+// overdraft records the start or the end of an overdraft of the wallets
+// whose balance crossed zero since before, it follows the events of the
+// operation.
+func overdraft(ctx context.Context, tx storage.Tx, ids []string, before []money.Amount) error {
+	for i, id := range ids {
+		w, _, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+
+		balance, err := tx.Balance(storage.WalletAccount(id, w.Currency))
+		if err != nil {
+			return err
+		}
+		if (before[i] < 0) == (balance < 0) {
+			continue
+		}
+
+		typ := event.WalletOverdraftEnded
+		if balance < 0 {
+			typ = event.WalletOverdraftStarted
+		}
+
+		err = event.Record(ctx, tx, id, typ, event.Overdraft{
+			WalletID:    id,
+			Balance:     balance,
+			CreditLimit: w.CreditLimit,
+			Currency:    w.Currency,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// chargeFee takes the fee of the transaction chargedFor from the wallet
+// into the house fee wallet of the currency, a zero fee isn't recorded.
+func chargeFee(ctx context.Context, tx storage.Tx, id string, currency money.Currency, fee money.Amount, chargedFor string) error {
+	if fee == 0 {
+		return nil
+	}
+
+	house, err := feeWallet(tx, currency)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Post(
+		storage.Entry{Account: storage.WalletAccount(id, currency), Amount: -fee},
+		storage.Entry{Account: storage.WalletAccount(house, currency), Amount: fee},
+	)
+	if err != nil {
+		return err
+	}
+
+	charged, err := appendTransaction(tx, storage.Transaction{
+		WalletID: id,
+		Type:     storage.TxFee,
+		Amount:   fee,
+		Currency: currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = event.Record(ctx, tx, id, event.WalletFeeCharged, event.FeeCharged{
+		WalletID:      id,
+		TransactionID: charged.ID,
+		ChargedFor:    chargedFor,
+		Amount:        fee,
+		Currency:      currency,
+		BalanceAfter:  charged.BalanceAfter,
+		FeeWallet:     house,
+	})
+	if err != nil {
+		return err
+	}
+
+	return collectFee(ctx, tx, house, id, currency, fee, chargedFor)
+}
+
+// feeWallet returns the house fee wallet of the currency, a missing
+// one is a server error, not the one of the charged wallet.
+func feeWallet(tx storage.Tx, currency money.Currency) (string, error) {
+	house := storage.FeeWallet(currency)
+	if _, err := wallet(tx, house, currency); err != nil {
+		return "", fmt.Errorf("fee wallet %s error: %s", house, err.Error())
+	}
+
+	return house, nil
+}
+
+// collectFee records the fee posted from the wallet id into the house
+// wallet, it follows the event of the charge.
+func collectFee(ctx context.Context, tx storage.Tx, house, id string, currency money.Currency, fee money.Amount, chargedFor string) error {
+	collected, err := appendTransaction(tx, storage.Transaction{
+		WalletID:     house,
+		Type:         storage.TxFeeIncome,
+		Counterparty: id,
+		Amount:       fee,
+		Currency:     currency,
+	})
+	if err != nil {
+		return err
+	}
+
+	return event.Record(ctx, tx, house, event.WalletFeeCollected, event.FeeCollected{
+		WalletID:      house,
+		TransactionID: collected.ID,
+		ChargedTo:     id,
+		ChargedFor:    chargedFor,
+		Amount:        fee,
+		Currency:      currency,
+		BalanceAfter:  collected.BalanceAfter,
+	})
+}
+
+// wallet returns an active wallet in the currency.
+func wallet(tx storage.Tx, id string, currency money.Currency) (storage.Wallet, error) {
+	w, found, err := tx.Wallet(id)
+	if err != nil {
+		return storage.Wallet{}, err
+	}
+	if !found || w.Status == "inactive" {
+		return storage.Wallet{}, oops.ErrNotFound
+	}
+
+	if w.Currency != currency {
+		return storage.Wallet{}, oops.ErrCurrencyMismatch
+	}
+
+	return w, nil
+}
+
+// appendTransaction records the transaction with the wallet balance after it.
+func appendTransaction(tx storage.Tx, t storage.Transaction) (storage.Transaction, error) {
+	balance, err := storage.WalletBalance(tx, t.WalletID)
+	if err != nil {
+		return storage.Transaction{}, err
+	}
+
+	t.BalanceAfter = balance
+	return tx.AppendTransaction(t)
+}
+
+// activeHold returns an active wallet with its hold which is still active at now.
+func activeHold(tx storage.Tx, id, holdID string, now time.Time) (storage.Wallet, storage.Hold, error) {
+	w, found, err := tx.Wallet(id)
+	if err != nil {
+		return storage.Wallet{}, storage.Hold{}, err
+	}
+	if !found || w.Status == "inactive" {
+		return storage.Wallet{}, storage.Hold{}, oops.ErrNotFound
+	}
+
+	h, found, err := tx.Hold(holdID)
+	if err != nil {
+		return storage.Wallet{}, storage.Hold{}, err
+	}
+	if !found || h.WalletID != id {
+		return storage.Wallet{}, storage.Hold{}, oops.ErrHoldNotFound
+	}
+
+	// an expired hold is closed, it is released on the next expiry.
+	if h.Status != storage.HoldActive || !now.Before(h.ExpiresAt) {
+		return storage.Wallet{}, storage.Hold{}, oops.ErrHoldClosed
+	}
+
+	return w, h, nil
+}
+
+// available releases the wallet holds expired by now and returns the
+// wallet with its balance not reserved by the active holds, the credit
+// limit of the wallet can be spent below zero.
+func available(ctx context.Context, tx storage.Tx, id string, w storage.Wallet, now time.Time) (storage.Wallet, money.Amount, error) {
+	if w.Held != 0 {
+		holds, err := tx.Holds(id)
+		if err != nil {
+			return storage.Wallet{}, 0, err
+		}
+
+		for _, h := range holds {
+			if h.Status != storage.HoldActive || now.Before(h.ExpiresAt) {
+				continue
+			}
+
+			h.Status, h.ClosedAt = storage.HoldExpired, now
+			if w, err = release(ctx, tx, w, h); err != nil {
+				return storage.Wallet{}, 0, err
+			}
+		}
+	}
+
+	balance, err := tx.Balance(storage.WalletAccount(id, w.Currency))
+	if err != nil {
+		return storage.Wallet{}, 0, err
+	}
+
+	available, err := balance.Sub(w.Held)
+	if err != nil {
+		return storage.Wallet{}, 0, err
+	}
+	if available, err = available.Add(w.CreditLimit); err != nil {
+		return storage.Wallet{}, 0, err
+	}
+
+	return w, available, nil
+}
+
+// release stores a hold closed without a capture, gives its amount
+// back to the available balance and records the release.
+func release(ctx context.Context, tx storage.Tx, w storage.Wallet, h storage.Hold) (storage.Wallet, error) {
+	if err := tx.PutHold(h); err != nil {
+		return storage.Wallet{}, err
+	}
+
+	w.Held -= h.Amount
+	if err := tx.PutWallet(h.WalletID, w); err != nil {
+		return storage.Wallet{}, err
+	}
+
+	return w, event.Record(ctx, tx, h.WalletID, event.WalletHoldReleased, event.HoldReleased{
+		WalletID: h.WalletID,
+		HoldID:   h.ID,
+		Amount:   h.Amount,
+		Currency: h.Currency,
+		Reason:   h.Status,
+	})
+}
+
+func toHold(h storage.Hold) operation.Hold {
+	hold := operation.Hold{
+		ID:            h.ID,
+		WalletID:      h.WalletID,
+		Status:        h.Status,
+		Amount:        h.Amount,
+		Captured:      h.Captured,
+		Currency:      h.Currency,
+		TransactionID: h.TransactionID,
+		CreatedAt:     h.CreatedAt,
+		ExpiresAt:     h.ExpiresAt,
+	}
+	if !h.ClosedAt.IsZero() {
+		closedAt := h.ClosedAt
+		hold.ClosedAt = &closedAt
+	}
+	return hold
+}
@@ -0,0 +1,158 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/operation"
+	"wallet/app/operation/store"
+	"wallet/app/storage"
+	"wallet/app/wallet"
+	walletStorage "wallet/app/wallet/store"
+)
+
+// newWallets creates n USD wallets holding balance each.
+func newWallets(t *testing.T, db storage.DB, n int, balance money.Amount) []string {
+	t.Helper()
+	ctx := context.Background()
+	wallets := walletStorage.NewStorage(db)
+	operations := store.NewStorage(db)
+
+	ids := make([]string, n)
+	for i := range ids {
+		w, err := wallets.CreateWallet(ctx, wallet.Request{Name: "test", Currency: "USD"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance != 0 {
+			if err = operations.Deposit(ctx, w.ID, balance, "USD", nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ids[i] = w.ID
+	}
+
+	return ids
+}
+
+func total(t *testing.T, db storage.DB, ids []string) money.Amount {
+	t.Helper()
+	var sum money.Amount
+	err := db.View(func(tx storage.Tx) error {
+		for _, id := range ids {
+			b, err := tx.Balance(storage.WalletAccount(id, "USD"))
+			if err != nil {
+				return err
+			}
+			if b < 0 {
+				t.Errorf("wallet %s balance %s is negative", id, b)
+			}
+			sum += b
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sum
+}
+
+func TestTransferConservesMoney(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ids := newWallets(t, db, 5, 100_0000)
+		s := store.NewStorage(db)
+
+		var wg sync.WaitGroup
+		for g := 0; g < 8; g++ {
+			wg.Add(1)
+			go func(seed int64) {
+				defer wg.Done()
+				rnd := rand.New(rand.NewSource(seed))
+				for i := 0; i < 100; i++ {
+					from, to := ids[rnd.Intn(len(ids))], ids[rnd.Intn(len(ids))]
+					amount := money.Amount(rnd.Intn(40)+1) * 1_0000
+					err := s.Transfer(context.Background(), operation.Transfer{
+						From: from, To: to,
+						Debit: amount, DebitCurrency: "USD",
+						Credit: amount, CreditCurrency: "USD",
+					}, nil, nil)
+					switch {
+					case err == nil, errors.Is(err, oops.ErrNotEnoMon), errors.Is(err, oops.ErrSelfTransfer):
+					default:
+						t.Error(err)
+					}
+				}
+			}(int64(g))
+		}
+		wg.Wait()
+
+		if got := total(t, db, ids); got != 500_0000 {
+			t.Fatalf("total = %s, want 500", got)
+		}
+	})
+}
+
+func TestTransferIsAtomic(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ids := newWallets(t, db, 1, 10_0000)
+		s := store.NewStorage(db)
+		ctx := context.Background()
+
+		tests := []struct {
+			name string
+			to   string
+			want error
+		}{
+			{"missing destination", "missing", oops.ErrNotFound},
+			{"self transfer", ids[0], oops.ErrSelfTransfer},
+		}
+		for _, tt := range tests {
+			err := s.Transfer(ctx, operation.Transfer{
+				From: ids[0], To: tt.to,
+				Debit: 5_0000, DebitCurrency: "USD",
+				Credit: 5_0000, CreditCurrency: "USD",
+			}, nil, nil)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+
+		if got := total(t, db, ids); got != 10_0000 {
+			t.Fatalf("balance = %s, want 10", got)
+		}
+	})
+}
+
+func TestDepositOverflow(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		const big = money.Amount(6e17)
+		id := newWallets(t, db, 1, big)[0]
+		operations := store.NewStorage(db)
+
+		// the balance of the wallet and the one of the cash-in
+		// account would both go out of range.
+		if err := operations.Deposit(ctx, id, big+2, "USD", nil); !errors.Is(err, oops.ErrAmountOverflow) {
+			t.Fatalf("deposit error = %v, want %v", err, oops.ErrAmountOverflow)
+		}
+
+		err := db.View(func(tx storage.Tx) error {
+			balance, err := tx.Balance(storage.WalletAccount(id, "USD"))
+			if err != nil {
+				return err
+			}
+			if balance != big {
+				t.Errorf("balance = %d, want %d", balance, big)
+			}
+			return storage.CheckJournal(tx)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -1,28 +1,293 @@
 package operation
 
-import "context"
+import (
+	"context"
+	"time"
+
+	"wallet/app/fee"
+	"wallet/app/fx"
+	"wallet/app/money"
+)
 
 // Request contains fields for client request.
 type Request struct {
-	Amount float64 `json:"amount"`
+	Amount   money.Amount   `json:"amount"`
+	Currency money.Currency `json:"currency"`
 }
 
 // TransferRequest contains fields for client request.
+// Currency is the currency of the source wallet, QuoteID
+// optionally refers to a locked fx quote.
 type TransferRequest struct {
-	Amount     float64 `json:"amount"`
-	TransferTo string  `json:"transfer_to"`
+	Amount     money.Amount   `json:"amount"`
+	Currency   money.Currency `json:"currency"`
+	TransferTo string         `json:"transfer_to"`
+	QuoteID    string         `json:"quote_id,omitempty"`
+}
+
+// Transfer is an applied transfer: Debit is taken from the source
+// wallet and Credit converted with Rate is added to the destination.
+// Fee is taken from the source wallet on top of Debit.
+type Transfer struct {
+	From           string         `json:"from"`
+	To             string         `json:"to"`
+	Debit          money.Amount   `json:"debit"`
+	DebitCurrency  money.Currency `json:"debit_currency"`
+	Credit         money.Amount   `json:"credit"`
+	CreditCurrency money.Currency `json:"credit_currency"`
+	Rate           fx.Rate        `json:"rate"`
+	Fee            fee.Fee        `json:"fee"`
+}
+
+// Hold reserves Amount of the wallet balance: it reduces the available
+// balance until it is captured, released or expires. Captured is the
+// amount taken from the balance by TransactionID.
+type Hold struct {
+	ID            string         `json:"id"`
+	WalletID      string         `json:"wallet_id"`
+	Status        string         `json:"status"`
+	Amount        money.Amount   `json:"amount"`
+	Captured      money.Amount   `json:"captured,omitempty"`
+	Currency      money.Currency `json:"currency"`
+	TransactionID string         `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+	ClosedAt      *time.Time     `json:"closed_at,omitempty"`
+}
+
+// HoldRequest contains fields for client request, ExpiresIn is
+// the hold lifetime in seconds, zero means the default one.
+type HoldRequest struct {
+	Amount    money.Amount   `json:"amount"`
+	Currency  money.Currency `json:"currency"`
+	ExpiresIn int64          `json:"expires_in,omitempty"`
+}
+
+// CaptureRequest contains fields for client request, zero Amount
+// captures the whole hold. The rest of a partly captured hold
+// is released.
+type CaptureRequest struct {
+	Amount money.Amount `json:"amount,omitempty"`
+}
+
+// ReverseRequest contains fields for client request, Amount is in
+// the currency of the reversed transaction and zero reverses all of it
+// which is not reversed yet.
+type ReverseRequest struct {
+	Amount money.Amount `json:"amount,omitempty"`
+}
+
+// Reversal is an applied reversal of TransactionID: Amount is reversed
+// now and Remaining can still be reversed, both in Currency of the
+// reversed transaction. Transactions are the compensating transactions
+// of the wallets, two for a reversed transfer.
+type Reversal struct {
+	TransactionID string                `json:"transaction_id"`
+	Amount        money.Amount          `json:"amount"`
+	Remaining     money.Amount          `json:"remaining"`
+	Currency      money.Currency        `json:"currency"`
+	Transactions  []ReversalTransaction `json:"transactions"`
+}
+
+// ReversalTransaction is a compensating transaction of a wallet, Type
+// is reversal_out when Amount is taken from the wallet and reversal_in
+// when it is given back.
+type ReversalTransaction struct {
+	ID           string         `json:"id"`
+	WalletID     string         `json:"wallet_id"`
+	Type         string         `json:"type"`
+	Amount       money.Amount   `json:"amount"`
+	Currency     money.Currency `json:"currency"`
+	BalanceAfter money.Amount   `json:"balance_after"`
+}
+
+// HoldOptions contains the default and the longest hold lifetime.
+type HoldOptions struct {
+	TTL, MaxTTL time.Duration
+}
+
+// Limits restricts the operations of a wallet, amounts are in the wallet
+// currency and a zero limit is not set. Withdrawals, sent transfers and
+// captures are outgoing, HourlyTransfers is the number of transfers
+// a wallet can send within an hour.
+type Limits struct {
+	MaxWithdrawal   money.Amount `json:"max_withdrawal,omitempty"`
+	DailyOutgoing   money.Amount `json:"daily_outgoing,omitempty"`
+	MonthlyOutgoing money.Amount `json:"monthly_outgoing,omitempty"`
+	HourlyTransfers int          `json:"hourly_transfers,omitempty"`
+	MaxBalance      money.Amount `json:"max_balance,omitempty"`
+}
+
+// Tier is a set of default limits by wallet currency, a wallet
+// can't be in a tier without the limits of its currency.
+type Tier map[money.Currency]Limits
+
+// Tiers are named sets of default limits, a wallet
+// without a tier gets the Default one.
+type Tiers struct {
+	Default string          `json:"default"`
+	Tiers   map[string]Tier `json:"tiers"`
+}
+
+// Usage is what the limits of a wallet are checked against: the balance,
+// the outgoing totals of the current UTC day and month and the number of
+// transfers sent within the last hour.
+type Usage struct {
+	Balance         money.Amount `json:"balance"`
+	DailyOutgoing   money.Amount `json:"daily_outgoing"`
+	MonthlyOutgoing money.Amount `json:"monthly_outgoing"`
+	HourlyTransfers int          `json:"hourly_transfers"`
+}
+
+// LimitState is the limit settings of a wallet, empty Tier is the default
+// one and Overrides replace its non-zero limits for the wallet Currency,
+// with the wallet Usage.
+type LimitState struct {
+	Tier      string
+	Currency  money.Currency
+	Overrides Limits
+	Usage     Usage
+}
+
+// Guard checks an operation against the limits of a wallet. The Store runs
+// it inside the unit of work applying the operation, so concurrent
+// operations can't exceed a limit, and an error cancels the operation.
+type Guard func(LimitState) error
+
+// WalletLimits are the limits of a wallet: the Tier limits with
+// the wallet Overrides applied, and the current Usage.
+type WalletLimits struct {
+	WalletID  string `json:"wallet_id"`
+	Tier      string `json:"tier"`
+	Limits    Limits `json:"limits"`
+	Overrides Limits `json:"overrides"`
+	Usage     Usage  `json:"usage"`
+}
+
+// LimitsRequest contains fields for client request, empty Tier is the
+// default one and Overrides replace the whole set of wallet overrides.
+type LimitsRequest struct {
+	Tier      string `json:"tier,omitempty"`
+	Overrides Limits `json:"overrides"`
+}
+
+// CreditRequest contains fields for client request, zero
+// CreditLimit keeps the balance of the wallet at zero or above.
+type CreditRequest struct {
+	CreditLimit money.Amount `json:"credit_limit"`
+}
+
+// Credit is the credit line of a wallet: the balance may go down to
+// minus CreditLimit and AvailableCredit is the part of it not used by
+// the balance and the holds. Overdrawn is set while the balance is
+// below zero.
+type Credit struct {
+	WalletID        string         `json:"wallet_id"`
+	Currency        money.Currency `json:"currency"`
+	Balance         money.Amount   `json:"balance"`
+	CreditLimit     money.Amount   `json:"credit_limit"`
+	AvailableCredit money.Amount   `json:"available_credit"`
+	Overdrawn       bool           `json:"overdrawn"`
+}
+
+// InterestRates are the daily interest rates of positive balances in
+// percent: Default or the one of the limit tier of a wallet in Products.
+type InterestRates struct {
+	Default  fx.Rate            `json:"default,omitempty"`
+	Products map[string]fx.Rate `json:"products,omitempty"`
+}
+
+// InterestRate returns the daily interest rate in percent of a wallet
+// of the limit tier with its own rate, zero own rate isn't set.
+type InterestRate func(tier string, own fx.Rate) fx.Rate
+
+// InterestRequest contains fields for client request, zero
+// Rate takes the rate of the product of the wallet.
+type InterestRequest struct {
+	Rate fx.Rate `json:"rate"`
+}
+
+// Interest is the daily interest rate in percent of a wallet: its own
+// Rate if set, or the one of its Product, the limit tier, and Effective
+// is the rate paid.
+type Interest struct {
+	WalletID  string  `json:"wallet_id"`
+	Product   string  `json:"product"`
+	Rate      fx.Rate `json:"rate"`
+	Effective fx.Rate `json:"effective"`
+}
+
+// RateSource returns exchange rates for transfers.
+type RateSource interface {
+	Rate(context.Context, string, money.Currency, money.Currency) (fx.Rate, error)
+}
+
+// FeeSource computes the fees of withdrawals and transfers.
+type FeeSource interface {
+	Fee(string, money.Amount, money.Currency) (fee.Fee, error)
 }
 
 // Store contains all methods to store data into the storage.
 type Store interface {
-	Deposit(context.Context, string, float64) error
-	Withdraw(context.Context, string, float64) error
-	Transfer(context.Context, string, TransferRequest) error
+	Deposit(context.Context, string, money.Amount, money.Currency, Guard) error
+	// Withdraw takes the amount and the fee on top of it from the balance,
+	// the fee goes to the house fee wallet of the currency.
+	Withdraw(ctx context.Context, id string, amount money.Amount, currency money.Currency, fee money.Amount, guard Guard) error
+	Currency(context.Context, string) (money.Currency, error)
+	// Transfer applies the transfer and charges its fee to the sender
+	// if the sender passes the from guard and the receiver passes the to one.
+	Transfer(ctx context.Context, data Transfer, from, to Guard) error
+
+	// PlaceHold reserves the amount of the available balance until expiresAt.
+	PlaceHold(ctx context.Context, id string, amount money.Amount, currency money.Currency, expiresAt time.Time) (Hold, error)
+	// CaptureHold takes the amount of an active hold from the balance
+	// and releases the rest of it, zero amount captures the whole hold.
+	CaptureHold(ctx context.Context, id, holdID string, amount money.Amount, guard Guard) (Hold, error)
+	ReleaseHold(ctx context.Context, id, holdID string) (Hold, error)
+	Hold(ctx context.Context, id, holdID string) (Hold, error)
+	Holds(ctx context.Context, id string) ([]Hold, error)
+	// ExpireHolds releases active holds expired by now and returns their number.
+	ExpireHolds(ctx context.Context, now time.Time) (int, error)
+
+	// Reverse posts the compensating entries of the amount of a transaction,
+	// zero amount reverses all of it which is not reversed yet.
+	Reverse(ctx context.Context, id string, amount money.Amount) (Reversal, error)
+
+	// Limits returns the limit settings and the usage of a wallet at now.
+	Limits(ctx context.Context, id string, now time.Time) (LimitState, error)
+	// SetLimits replaces the tier and the limit overrides of a wallet.
+	SetLimits(ctx context.Context, id, tier string, overrides Limits) error
+
+	// SetCredit replaces the credit limit of a wallet.
+	SetCredit(ctx context.Context, id string, limit money.Amount) (Credit, error)
+	// AccrueOverdrafts charges the interest of the UTC days up to the day
+	// not run yet at the daily rate in percent to the wallets overdrawn at
+	// their ends and returns the number of wallets charged. The charge is
+	// capped at the credit the wallet has left.
+	AccrueOverdrafts(ctx context.Context, rate fx.Rate, day time.Time) (int, error)
+
+	// SetInterest replaces the own interest rate of a wallet and returns its tier.
+	SetInterest(ctx context.Context, id string, rate fx.Rate) (string, error)
+	// PayInterest pays the interest of the UTC days up to the day not paid
+	// yet at the daily rate in percent to the wallets with a positive balance
+	// at their ends and returns the number of wallets paid. A day is paid
+	// once, a paid day returns zero.
+	PayInterest(ctx context.Context, rate InterestRate, day time.Time) (int, error)
 }
 
 // Service contains all methods from operation service.
 type Service interface {
 	Deposit(context.Context, string, Request) error
-	Withdraw(context.Context, string, Request) error
-	Transfer(context.Context, string, TransferRequest) error
+	Withdraw(context.Context, string, Request) (fee.Fee, error)
+	Transfer(context.Context, string, TransferRequest) (Transfer, error)
+	PlaceHold(context.Context, string, HoldRequest) (Hold, error)
+	CaptureHold(context.Context, string, string, CaptureRequest) (Hold, error)
+	ReleaseHold(context.Context, string, string) (Hold, error)
+	Hold(context.Context, string, string) (Hold, error)
+	Holds(context.Context, string) ([]Hold, error)
+	Reverse(context.Context, string, ReverseRequest) (Reversal, error)
+	Limits(context.Context, string) (WalletLimits, error)
+	SetLimits(context.Context, string, LimitsRequest) (WalletLimits, error)
+	SetCredit(context.Context, string, CreditRequest) (Credit, error)
+	SetInterest(context.Context, string, InterestRequest) (Interest, error)
 }
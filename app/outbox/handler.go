@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"errors"
+	"net/http"
+
+	"wallet/app/oops"
+	"wallet/app/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler contains DeadLetterService, Relay and a router.
+type Handler struct {
+	router *chi.Mux
+	dead   DeadLetterService
+	relay  *Relay
+}
+
+// NewHandler is a constructor which accepts DeadLetterService and
+// Relay and returns a pointer to the Handler.
+func NewHandler(router *chi.Mux, service DeadLetterService, relay *Relay) *Handler {
+	return &Handler{
+		router: router,
+		dead:   service,
+		relay:  relay,
+	}
+}
+
+// Register dead-letter admin routes.
+func (h *Handler) Register() {
+	h.router.Group(func(r chi.Router) {
+		r.Get("/admin/dead-letters", h.list)
+		r.Get("/admin/dead-letters/{id}", h.item)
+		r.Post("/admin/dead-letters/{id}/replay", h.replay)
+		r.Delete("/admin/dead-letters/{id}", h.discard)
+		r.Get("/admin/outbox/stats", h.stats)
+	})
+}
+
+// stats reports the publishing backpressure, Queued close to
+// Capacity and growing Blocked mean the queue can't keep up.
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	response.Data(w, http.StatusOK, h.relay.Stats())
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	letters, err := h.dead.List(r.Context())
+	if err != nil {
+		response.Error(w, http.StatusInternalServerError, oops.ErrIntServMessage)
+		return
+	}
+
+	response.Data(w, http.StatusOK, letters)
+}
+
+func (h *Handler) item(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	letter, err := h.dead.Item(r.Context(), id)
+	if err != nil {
+		errorResponse(w, err, id)
+		return
+	}
+
+	response.Data(w, http.StatusOK, letter)
+}
+
+// replay responds with 202 as the event is published by the relay later.
+func (h *Handler) replay(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.dead.Replay(r.Context(), id); err != nil {
+		errorResponse(w, err, id)
+		return
+	}
+
+	response.WalletSuccess(w, http.StatusAccepted, id)
+}
+
+func (h *Handler) discard(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := h.dead.Discard(r.Context(), id); err != nil {
+		errorResponse(w, err, id)
+		return
+	}
+
+	response.WalletSuccess(w, http.StatusOK, id)
+}
+
+func errorResponse(w http.ResponseWriter, err error, id string) {
+	if errors.Is(err, oops.ErrNotFound) {
+		response.WalletError(w, http.StatusNotFound, oops.ErrNotFoundMessage, id)
+		return
+	}
+	response.WalletError(w, http.StatusInternalServerError, oops.ErrIntServMessage, id)
+}
@@ -0,0 +1,333 @@
+// Package outbox publishes events recorded by the stores
+// in the same unit of work as the state change.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/oops"
+	"wallet/app/queue"
+)
+
+// Options contains the relay polling, publishing and retry settings.
+type Options struct {
+	// PollInterval is how often the outbox is checked for new events.
+	PollInterval time.Duration
+	// BatchSize is the number of pending events read at once.
+	BatchSize int
+	// Pipeline configures the publishing workers.
+	Pipeline queue.PipelineOptions
+	// MinBackoff and MaxBackoff bound the delay before
+	// the next attempt to publish a failed event.
+	MinBackoff, MaxBackoff time.Duration
+	// Jitter is the random fraction from 0 to 1 taken off a delay,
+	// so failed events don't all retry at the same time.
+	Jitter float64
+	// MaxAttempts is the number of attempts after which an event
+	// is dead-lettered, zero means retry forever.
+	MaxAttempts int
+	// DeadLetters is the capacity of the dead-letter store, an event
+	// which can't be dead-lettered keeps retrying at MaxBackoff.
+	DeadLetters int
+}
+
+// errDrained is returned for the store calls of the workers
+// which were still publishing when Drain gave up.
+var errDrained = errors.New("outbox relay is drained")
+
+// retry is the state of a failed event.
+type retry struct {
+	attempts int
+	next     time.Time
+}
+
+// Stats are the relay and publishing pipeline counters.
+type Stats struct {
+	queue.Stats
+	InFlight int `json:"in_flight"`
+	Retrying int `json:"retrying"`
+}
+
+// Relay publishes pending events to the queue through a pipeline and
+// marks them delivered only after the producer acknowledged them.
+// Events of one wallet are published in order: a failed or dead-lettered
+// event blocks the later events of its wallet until it is published
+// or discarded.
+//
+// The lock guards the relay state only, the store is used without it.
+// done writes the store before it takes an event out of flight, so an
+// event read as pending is always in flight in the state read before.
+type Relay struct {
+	store    Store
+	opts     Options
+	pipeline *queue.Pipeline
+
+	// retries holds failed events, inflight holds the epochs
+	// of submitted events and epochs the current epoch by
+	// wallet, see queue.Pipeline.
+	retries  map[string]retry
+	inflight map[string]uint64
+	epochs   map[string]uint64
+	sync.Mutex
+
+	// drained is set by Drain, io makes it wait
+	// for the store calls of done in progress.
+	io      sync.RWMutex
+	drained bool
+}
+
+// NewRelay is a Relay constructor, it starts the publishing pipeline.
+func NewRelay(store Store, producer queue.Service, opts Options) *Relay {
+	r := &Relay{
+		store:    store,
+		opts:     opts,
+		retries:  make(map[string]retry),
+		inflight: make(map[string]uint64),
+		epochs:   make(map[string]uint64),
+	}
+	r.pipeline = queue.NewPipeline(producer, opts.Pipeline, r.done)
+
+	return r
+}
+
+// Run submits pending events to the pipeline until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.relay(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("outbox relay error: %s", err.Error())
+			}
+		}
+	}
+}
+
+// Drain publishes the submitted events, it is called after Run returned.
+// The relay doesn't use the store once Drain returned, so the storage can
+// be closed even if ctx is done first: the events still being published
+// stay pending and are published again after a restart.
+func (r *Relay) Drain(ctx context.Context) error {
+	err := r.pipeline.Drain(ctx)
+
+	r.io.Lock()
+	r.drained = true
+	r.io.Unlock()
+
+	return err
+}
+
+// use calls f with the store unless the relay is drained.
+func (r *Relay) use(f func() error) error {
+	r.io.RLock()
+	defer r.io.RUnlock()
+
+	if r.drained {
+		return errDrained
+	}
+	return f()
+}
+
+// Stats returns the current counters.
+func (r *Relay) Stats() Stats {
+	r.Lock()
+	defer r.Unlock()
+
+	return Stats{
+		Stats:    r.pipeline.Stats(),
+		InFlight: len(r.inflight),
+		Retrying: len(r.retries),
+	}
+}
+
+// relay makes one pass over the pending events.
+func (r *Relay) relay(ctx context.Context) error {
+	events, epochs, err := r.next(ctx)
+	if err != nil {
+		return err
+	}
+
+	for i, e := range events {
+		if err = r.pipeline.Submit(ctx, e, epochs[i]); err != nil {
+			// the rest is submitted again on the next pass.
+			r.Lock()
+			for _, e := range events[i:] {
+				delete(r.inflight, e.ID)
+			}
+			r.Unlock()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// next selects the pending events to submit and marks them in flight.
+// The events of blocked wallets and the ones in flight are skipped by
+// the store, so they don't fill the batch and hold back the events of
+// other wallets. The store is read with a copy of the relay state.
+func (r *Relay) next(ctx context.Context) ([]event.Event, []uint64, error) {
+	r.Lock()
+	inflight := make(map[string]uint64, len(r.inflight))
+	for id, epoch := range r.inflight {
+		inflight[id] = epoch
+	}
+	retries := make(map[string]retry, len(r.retries))
+	for id, rt := range r.retries {
+		retries[id] = rt
+	}
+	epochs := make(map[string]uint64, len(r.epochs))
+	for id, epoch := range r.epochs {
+		epochs[id] = epoch
+	}
+	r.Unlock()
+
+	dead, err := r.store.DeadLetters(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	blocked := make(map[string]bool, len(dead))
+	for _, d := range dead {
+		blocked[d.AggregateID] = true
+	}
+
+	events, err := r.store.Pending(ctx, r.opts.BatchSize, func(e event.Event) bool {
+		if blocked[e.AggregateID] {
+			return true
+		}
+
+		if submitted, found := inflight[e.ID]; found {
+			// an event submitted before a failure of its wallet fails
+			// in the pipeline, the later ones must wait for it.
+			if submitted < epochs[e.AggregateID] {
+				blocked[e.AggregateID] = true
+			}
+			return true
+		}
+
+		if rt, found := retries[e.ID]; found && now.Before(rt.next) {
+			blocked[e.AggregateID] = true
+			return true
+		}
+
+		return false
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the events keep the epochs they were selected with, the ones of
+	// a wallet which failed meanwhile fail in the pipeline.
+	submitted := make([]uint64, 0, len(events))
+	r.Lock()
+	for _, e := range events {
+		epoch := epochs[e.AggregateID]
+		r.inflight[e.ID] = epoch
+		submitted = append(submitted, epoch)
+	}
+	r.Unlock()
+
+	return events, submitted, nil
+}
+
+// done handles the result of a published batch, it is called by the pipeline.
+func (r *Relay) done(events []event.Event, err error) {
+	ctx := context.Background()
+
+	if err == nil {
+		ids := make([]string, 0, len(events))
+		for _, e := range events {
+			ids = append(ids, e.ID)
+		}
+
+		err = r.use(func() error { return r.store.MarkDelivered(ctx, ids...) })
+		if err != nil {
+			// the events stay pending and are published again.
+			log.Printf("outbox mark delivered error: %s", err.Error())
+		}
+
+		r.Lock()
+		for _, e := range events {
+			delete(r.retries, e.ID)
+			delete(r.inflight, e.ID)
+		}
+		r.Unlock()
+		return
+	}
+
+	now := time.Now()
+	for _, e := range events {
+		if errors.Is(err, queue.ErrPredecessorFailed) {
+			r.Lock()
+			delete(r.inflight, e.ID)
+			r.Unlock()
+			continue
+		}
+
+		r.fail(ctx, e, err, now)
+	}
+}
+
+// fail schedules the next attempt to publish the event
+// or dead-letters it if it has no attempts left.
+func (r *Relay) fail(ctx context.Context, e event.Event, cause error, now time.Time) {
+	r.Lock()
+	rt, retried := r.retries[e.ID], true
+	r.Unlock()
+
+	rt.attempts++
+	log.Printf("queue.Publish error: event %s attempt %d: %s", e.ID, rt.attempts, cause.Error())
+
+	if r.opts.MaxAttempts > 0 && rt.attempts >= r.opts.MaxAttempts {
+		err := r.use(func() error { return r.store.MarkDead(ctx, e.ID, cause.Error(), r.opts.DeadLetters) })
+		switch {
+		case err == nil:
+			log.Printf("event %s is dead-lettered after %d attempts", e.ID, rt.attempts)
+			retried = false
+		case errors.Is(err, oops.ErrDeadLetterFull):
+			log.Printf("event %s can't be dead-lettered: %s", e.ID, err.Error())
+			rt.next = now.Add(r.opts.MaxBackoff)
+		default:
+			// the event is attempted again on the next pass.
+			log.Printf("outbox relay error: %s", err.Error())
+		}
+	} else {
+		rt.next = now.Add(r.backoff(rt.attempts))
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	r.epochs[e.AggregateID]++
+	delete(r.inflight, e.ID)
+	if retried {
+		r.retries[e.ID] = rt
+	} else {
+		delete(r.retries, e.ID)
+	}
+}
+
+// backoff doubles the delay for every attempt up to MaxBackoff
+// and takes a random part of up to Jitter off it.
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := r.opts.MinBackoff
+	for i := 1; i < attempts && d < r.opts.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.opts.MaxBackoff {
+		d = r.opts.MaxBackoff
+	}
+	return d - time.Duration(rand.Float64()*r.opts.Jitter*float64(d))
+}
@@ -0,0 +1,290 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/outbox"
+	outboxStorage "wallet/app/outbox/store"
+	"wallet/app/queue"
+	"wallet/app/storage"
+)
+
+// publisher records the published events, fail decides
+// whether an attempt to publish an event fails.
+type publisher struct {
+	fail      func(e event.Event, attempt int) bool
+	attempts  map[string][]time.Time
+	published []string
+	sync.Mutex
+}
+
+func (p *publisher) Wallet(e event.Event, ch chan error)    { ch <- p.publish(e) }
+func (p *publisher) Operation(e event.Event, ch chan error) { ch <- p.publish(e) }
+
+func (p *publisher) publish(e event.Event) error {
+	p.Lock()
+	defer p.Unlock()
+
+	p.attempts[e.ID] = append(p.attempts[e.ID], time.Now())
+	if p.fail(e, len(p.attempts[e.ID])) {
+		return errors.New("publish failed")
+	}
+	p.published = append(p.published, e.AggregateID+"/"+e.ID)
+	return nil
+}
+
+func (p *publisher) snapshot() []string {
+	p.Lock()
+	defer p.Unlock()
+	return append([]string(nil), p.published...)
+}
+
+// record appends an event of each aggregate id in order and returns their ids.
+func record(t *testing.T, db storage.DB, aggregateIDs ...string) []string {
+	t.Helper()
+	var ids []string
+	for _, id := range aggregateIDs {
+		err := db.Update(func(tx storage.Tx) error {
+			e, err := tx.AppendEvent(storage.Event{AggregateID: id, Name: event.WalletDeposited, Version: event.Version, Payload: []byte("{}")})
+			ids = append(ids, id+"/"+e.ID)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return ids
+}
+
+// run runs the relay until cond holds or the test times out.
+func run(t *testing.T, db storage.DB, p *publisher, opts outbox.Options, cond func() bool) {
+	t.Helper()
+	opts.PollInterval = time.Millisecond
+	opts.Pipeline = queue.PipelineOptions{Workers: 2, Buffer: 16, BatchSize: 4, FlushInterval: time.Millisecond}
+
+	r := outbox.NewRelay(outboxStorage.NewStorage(db), p, opts)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+		r.Drain(context.Background())
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out, published %q", p.snapshot())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRelayDeadLetterDoesNotBlockOtherWallets(t *testing.T) {
+	db := storage.NewMemory()
+	record(t, db, "a", "a", "a")
+	want := record(t, db, "b", "b", "b")
+
+	p := &publisher{
+		fail:     func(e event.Event, _ int) bool { return e.AggregateID == "a" },
+		attempts: make(map[string][]time.Time),
+	}
+
+	// the pending batch is smaller than the blocked events of a.
+	run(t, db, p, outbox.Options{BatchSize: 2, MaxAttempts: 1, DeadLetters: 10, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, func() bool {
+		return len(p.snapshot()) == len(want)
+	})
+
+	if got := p.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("published %q, want %q", got, want)
+	}
+	// the events of a after the dead letter wait for it.
+	for _, id := range []string{"2", "3"} {
+		if n := len(p.attempts[id]); n != 0 {
+			t.Errorf("event %s attempted %d times behind the dead letter", id, n)
+		}
+	}
+}
+
+func TestRelayRetryDoesNotBlockOtherWallets(t *testing.T) {
+	db := storage.NewMemory()
+	record(t, db, "a", "a", "a")
+	want := record(t, db, "b", "b", "b")
+
+	p := &publisher{
+		fail:     func(e event.Event, _ int) bool { return e.AggregateID == "a" },
+		attempts: make(map[string][]time.Time),
+	}
+
+	// the pending batch is smaller than the waiting events of a.
+	run(t, db, p, outbox.Options{BatchSize: 2, MinBackoff: time.Hour, MaxBackoff: time.Hour}, func() bool {
+		return len(p.snapshot()) == len(want)
+	})
+
+	if got := p.snapshot(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("published %q, want %q", got, want)
+	}
+	// the events of a after the failed one wait for it.
+	for _, id := range []string{"2", "3"} {
+		if n := len(p.attempts[id]); n != 0 {
+			t.Errorf("event %s attempted %d times behind the failed one", id, n)
+		}
+	}
+}
+
+func TestRelayRetriesInOrder(t *testing.T) {
+	db := storage.NewMemory()
+	want := record(t, db, "a", "b", "a", "a")
+
+	p := &publisher{
+		fail:     func(e event.Event, attempt int) bool { return e.ID == "1" && attempt < 3 },
+		attempts: make(map[string][]time.Time),
+	}
+
+	opts := outbox.Options{BatchSize: 10, MinBackoff: 20 * time.Millisecond, MaxBackoff: time.Second}
+	run(t, db, p, opts, func() bool {
+		return len(p.snapshot()) == len(want)
+	})
+
+	got := p.snapshot()
+	var a []string
+	for _, id := range got {
+		if id[0] == 'a' {
+			a = append(a, id)
+		}
+	}
+	if wantA := []string{want[0], want[2], want[3]}; !reflect.DeepEqual(a, wantA) {
+		t.Fatalf("published events of a %q, want %q", a, wantA)
+	}
+	// b isn't held back by the retries of a.
+	if got[0] != want[1] {
+		t.Errorf("first published %q, want %q", got[0], want[1])
+	}
+
+	// without jitter the delay doubles for every attempt.
+	attempts := p.attempts["1"]
+	if len(attempts) != 3 {
+		t.Fatalf("event 1 attempted %d times, want 3", len(attempts))
+	}
+	for i, min := range []time.Duration{opts.MinBackoff, 2 * opts.MinBackoff} {
+		if d := attempts[i+1].Sub(attempts[i]); d < min {
+			t.Errorf("retry %d after %v, want at least %v", i+1, d, min)
+		}
+	}
+}
+
+// slowStore lets a test hold the store calls of the relay.
+type slowStore struct {
+	outbox.Store
+	pending chan struct{}
+	closed  bool
+	late    []string
+	sync.Mutex
+}
+
+func (s *slowStore) Pending(ctx context.Context, limit int, skip func(event.Event) bool) ([]event.Event, error) {
+	<-s.pending
+	return s.Store.Pending(ctx, limit, skip)
+}
+
+func (s *slowStore) MarkDelivered(ctx context.Context, ids ...string) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		s.late = append(s.late, ids...)
+	}
+	return s.Store.MarkDelivered(ctx, ids...)
+}
+
+func TestRelayStatsWhileReading(t *testing.T) {
+	store := &slowStore{Store: outboxStorage.NewStorage(storage.NewMemory()), pending: make(chan struct{})}
+	r := outbox.NewRelay(store, &publisher{fail: func(event.Event, int) bool { return false }, attempts: make(map[string][]time.Time)},
+		outbox.Options{PollInterval: time.Millisecond, BatchSize: 10, Pipeline: queue.PipelineOptions{Workers: 1, Buffer: 1, BatchSize: 1}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		close(store.pending)
+		<-done
+		r.Drain(context.Background())
+	}()
+
+	// the relay waits in Pending now or soon, Stats doesn't wait for it.
+	time.Sleep(10 * time.Millisecond)
+	stats := make(chan struct{})
+	go func() {
+		r.Stats()
+		close(stats)
+	}()
+	select {
+	case <-stats:
+	case <-time.After(time.Second):
+		t.Fatal("Stats blocked by the store")
+	}
+}
+
+func TestRelayDrainTimeout(t *testing.T) {
+	db := storage.NewMemory()
+	record(t, db, "a")
+
+	release := make(chan struct{})
+	attempted := make(chan struct{}, 1)
+	p := &publisher{
+		fail: func(event.Event, int) bool {
+			attempted <- struct{}{}
+			<-release
+			return false
+		},
+		attempts: make(map[string][]time.Time),
+	}
+
+	pending := make(chan struct{})
+	close(pending)
+	store := &slowStore{Store: outboxStorage.NewStorage(db), pending: pending}
+	r := outbox.NewRelay(store, p, outbox.Options{PollInterval: time.Millisecond, BatchSize: 10,
+		Pipeline: queue.PipelineOptions{Workers: 1, Buffer: 1, BatchSize: 1}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	<-attempted
+	cancel()
+	<-done
+
+	timeout, stop := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer stop()
+	if err := r.Drain(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Drain() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// the storage is closed now, the worker finishes later.
+	store.Lock()
+	store.closed = true
+	store.Unlock()
+	close(release)
+	if err := r.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(store.late) != 0 {
+		t.Errorf("events %q marked delivered after the drain", store.late)
+	}
+}
@@ -0,0 +1,38 @@
+package outbox
+
+import "context"
+
+// DeadLetterService contains Store interface.
+type DeadLetterService struct {
+	store Store
+}
+
+// NewDeadLetterService is a DeadLetterService constructor.
+func NewDeadLetterService(store Store) *DeadLetterService {
+	return &DeadLetterService{
+		store: store,
+	}
+}
+
+// List returns dead-lettered events in order.
+func (s *DeadLetterService) List(ctx context.Context) ([]DeadLetter, error) {
+	return s.store.DeadLetters(ctx)
+}
+
+// Item returns a dead-lettered event by id.
+func (s *DeadLetterService) Item(ctx context.Context, id string) (DeadLetter, error) {
+	return s.store.DeadLetter(ctx, id)
+}
+
+// Replay returns a dead-lettered event to the outbox,
+// the relay publishes it on its next pass.
+func (s *DeadLetterService) Replay(ctx context.Context, id string) error {
+	return s.store.Requeue(ctx, id)
+}
+
+// Discard drops a dead-lettered event, its tombstone is published
+// in its place, see event.Tombstone, and the later events of its
+// wallet follow it.
+func (s *DeadLetterService) Discard(ctx context.Context, id string) error {
+	return s.store.Discard(ctx, id)
+}
@@ -0,0 +1,161 @@
+// Package store contains all implementation to work
+// with the outbox in storage.DB.
+package store
+
+import (
+	"context"
+
+	"wallet/app/event"
+	"wallet/app/oops"
+	"wallet/app/outbox"
+	"wallet/app/storage"
+)
+
+// Storage works with the outbox in storage.DB.
+type Storage struct {
+	db storage.DB
+}
+
+// NewStorage is a constructor for storage.
+func NewStorage(db storage.DB) *Storage {
+	return &Storage{
+		db: db,
+	}
+}
+
+// Pending returns up to limit undelivered events in order without the
+// ones skip returns true for, a discarded event is returned as its tombstone.
+func (s *Storage) Pending(ctx context.Context, limit int, skip func(event.Event) bool) ([]event.Event, error) {
+	var events []event.Event
+
+	err := s.db.View(func(tx storage.Tx) error {
+		var filter func(storage.Event) bool
+		if skip != nil {
+			filter = func(e storage.Event) bool {
+				return skip(outgoing(e))
+			}
+		}
+
+		pending, err := tx.PendingEvents(limit, filter)
+		if err != nil {
+			return err
+		}
+
+		events = make([]event.Event, 0, len(pending))
+		for _, e := range pending {
+			events = append(events, outgoing(e))
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+// MarkDelivered marks the events as published.
+func (s *Storage) MarkDelivered(ctx context.Context, ids ...string) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		for _, id := range ids {
+			if err := tx.MarkDelivered(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// MarkDead moves a pending event to the dead letters.
+func (s *Storage) MarkDead(ctx context.Context, id, reason string, capacity int) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		dead, err := tx.DeadLetters()
+		if err != nil {
+			return err
+		}
+		if len(dead) >= capacity {
+			return oops.ErrDeadLetterFull
+		}
+
+		return tx.DeadLetter(id, reason)
+	})
+}
+
+// DeadLetters returns dead-lettered events in order.
+func (s *Storage) DeadLetters(ctx context.Context) ([]outbox.DeadLetter, error) {
+	var letters []outbox.DeadLetter
+
+	err := s.db.View(func(tx storage.Tx) error {
+		dead, err := tx.DeadLetters()
+		if err != nil {
+			return err
+		}
+
+		letters = make([]outbox.DeadLetter, 0, len(dead))
+		for _, e := range dead {
+			letters = append(letters, deadLetter(e))
+		}
+
+		return nil
+	})
+
+	return letters, err
+}
+
+// DeadLetter returns a dead-lettered event by id.
+func (s *Storage) DeadLetter(ctx context.Context, id string) (outbox.DeadLetter, error) {
+	var letter outbox.DeadLetter
+
+	err := s.db.View(func(tx storage.Tx) error {
+		dead, err := tx.DeadLetters()
+		if err != nil {
+			return err
+		}
+
+		for _, e := range dead {
+			if e.ID == id {
+				letter = deadLetter(e)
+				return nil
+			}
+		}
+
+		return oops.ErrNotFound
+	})
+
+	return letter, err
+}
+
+// Requeue moves a dead-lettered event back to the pending events.
+func (s *Storage) Requeue(ctx context.Context, id string) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		return tx.Requeue(id)
+	})
+}
+
+// Discard drops a dead-lettered event, it is published as a tombstone.
+func (s *Storage) Discard(ctx context.Context, id string) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		return tx.Discard(id)
+	})
+}
+
+func deadLetter(e storage.Event) outbox.DeadLetter {
+	return outbox.DeadLetter{
+		ID:            e.ID,
+		Type:          e.Name,
+		Version:       e.Version,
+		AggregateID:   e.AggregateID,
+		Sequence:      e.Seq,
+		CorrelationID: e.CorrelationID,
+		Time:          e.CreatedAt,
+		Payload:       e.Payload,
+		Reason:        e.Reason,
+		DeadAt:        e.DeadAt,
+	}
+}
+
+// outgoing returns the event to publish for an outbox record.
+func outgoing(e storage.Event) event.Event {
+	if e.Discarded {
+		return event.Tombstone(event.FromStorage(e))
+	}
+	return event.FromStorage(e)
+}
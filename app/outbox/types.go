@@ -0,0 +1,47 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"wallet/app/event"
+)
+
+// DeadLetter is an event which exhausted its publish attempts.
+type DeadLetter struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Version       int             `json:"schema_version"`
+	AggregateID   string          `json:"aggregate_id"`
+	Sequence      uint64          `json:"sequence"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Time          time.Time       `json:"time"`
+	Payload       json.RawMessage `json:"payload"`
+	Reason        string          `json:"reason"`
+	DeadAt        time.Time       `json:"dead_at"`
+}
+
+// Store contains all methods to work with the outbox in the storage.
+type Store interface {
+	// Pending returns up to limit undelivered events in order without
+	// the ones skip returns true for, skip may be nil.
+	Pending(ctx context.Context, limit int, skip func(event.Event) bool) ([]event.Event, error)
+	MarkDelivered(ctx context.Context, ids ...string) error
+
+	// MarkDead moves a pending event to the dead letters unless
+	// there are capacity of them already, see oops.ErrDeadLetterFull.
+	MarkDead(ctx context.Context, id, reason string, capacity int) error
+	DeadLetters(context.Context) ([]DeadLetter, error)
+	DeadLetter(context.Context, string) (DeadLetter, error)
+	Requeue(context.Context, string) error
+	Discard(context.Context, string) error
+}
+
+// Service contains all methods from dead-letter service.
+type Service interface {
+	List(context.Context) ([]DeadLetter, error)
+	Item(context.Context, string) (DeadLetter, error)
+	Replay(context.Context, string) error
+	Discard(context.Context, string) error
+}
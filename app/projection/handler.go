@@ -0,0 +1,89 @@
+package projection
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"wallet/app/oops"
+	"wallet/app/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler contains ReadService and a router.
+type Handler struct {
+	router *chi.Mux
+	read   ReadService
+}
+
+// NewHandler is a constructor which accepts ReadService and
+// returns a pointer to the Handler.
+func NewHandler(router *chi.Mux, service ReadService) *Handler {
+	return &Handler{
+		router: router,
+		read:   service,
+	}
+}
+
+// Register projection routes.
+func (h *Handler) Register() {
+	h.router.Group(func(r chi.Router) {
+		r.Get("/wallets/{id}/daily-totals", h.dailyTotals)
+		r.Get("/activity", h.feed)
+	})
+}
+
+// dailyTotals reads from and to query parameters in YYYY-MM-DD format.
+func (h *Handler) dailyTotals(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, "")
+		return
+	}
+
+	q := r.URL.Query()
+	totals, err := h.read.DailyTotals(r.Context(), id, q.Get("from"), q.Get("to"))
+	if err != nil {
+		switch {
+		case errors.Is(err, oops.ErrNotFound):
+			response.WalletError(w, http.StatusNotFound, oops.ErrNotFoundMessage, id)
+		case errors.Is(err, oops.ErrBadFilter):
+			response.WalletError(w, http.StatusBadRequest, oops.ErrBadFilterMessage, id)
+		default:
+			response.WalletError(w, http.StatusInternalServerError, oops.ErrIntServMessage, id)
+		}
+		return
+	}
+
+	response.Data(w, http.StatusOK, totals)
+}
+
+// feed reads cursor and limit query parameters.
+func (h *Handler) feed(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := FeedFilter{
+		Cursor: q.Get("cursor"),
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			response.Error(w, http.StatusBadRequest, oops.ErrBadFilterMessage)
+			return
+		}
+		f.Limit = limit
+	}
+
+	feed, err := h.read.Feed(r.Context(), f)
+	if err != nil {
+		if errors.Is(err, oops.ErrBadFilter) {
+			response.Error(w, http.StatusBadRequest, oops.ErrBadFilterMessage)
+			return
+		}
+		response.Error(w, http.StatusInternalServerError, oops.ErrIntServMessage)
+		return
+	}
+
+	response.Data(w, http.StatusOK, feed)
+}
@@ -0,0 +1,275 @@
+// Package projection maintains read models built from consumed
+// wallet events: per-wallet daily totals and a global activity feed.
+package projection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/oops"
+	"wallet/app/storage"
+)
+
+const (
+	// DefaultLimit is a feed page size when the client doesn't set one.
+	DefaultLimit = 50
+	// MaxLimit is the largest allowed feed page size.
+	MaxLimit = 500
+
+	// rebuildBatch is the number of events read at once on rebuild.
+	rebuildBatch = 500
+)
+
+// ReadService contains Store interface.
+type ReadService struct {
+	store Store
+}
+
+// NewReadService is a ReadService constructor.
+func NewReadService(store Store) *ReadService {
+	return &ReadService{
+		store: store,
+	}
+}
+
+// DailyTotals returns wallet totals by day between from and to
+// inclusive, empty from or to means an open date range.
+func (s *ReadService) DailyTotals(ctx context.Context, id, from, to string) ([]DailyTotal, error) {
+	for _, d := range []string{from, to} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(DateLayout, d); err != nil {
+			return nil, oops.ErrBadFilter
+		}
+	}
+	if from != "" && to != "" && to < from {
+		return nil, oops.ErrBadFilter
+	}
+
+	return s.store.DailyTotals(ctx, id, from, to)
+}
+
+// Feed returns a page of the activity feed.
+func (s *ReadService) Feed(ctx context.Context, f FeedFilter) (Feed, error) {
+	if f.Limit == 0 {
+		f.Limit = DefaultLimit
+	}
+	if f.Limit < 0 || f.Limit > MaxLimit {
+		return Feed{}, oops.ErrBadFilter
+	}
+
+	return s.store.Feed(ctx, f)
+}
+
+// Projector applies consumed events to the read models.
+type Projector struct {
+	store Store
+}
+
+// NewProjector is a Projector constructor.
+func NewProjector(store Store) *Projector {
+	return &Projector{
+		store: store,
+	}
+}
+
+// Handle applies an event, it is safe to deliver an event more than once.
+func (p *Projector) Handle(ctx context.Context, e event.Event) error {
+	change, err := project(e)
+	if err != nil {
+		return err
+	}
+
+	return p.store.Apply(ctx, e, change)
+}
+
+// Rebuild applies all recorded events, so the read models are
+// up to date before the consumer starts and skips what they contain.
+func (p *Projector) Rebuild(ctx context.Context, events EventLog) error {
+	after := ""
+	n := 0
+
+	for {
+		batch, err := events.Events(ctx, after, rebuildBatch)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, e := range batch {
+			if err = p.Handle(ctx, e); err != nil {
+				return fmt.Errorf("rebuild event %s error: %w", e.ID, err)
+			}
+		}
+
+		n += len(batch)
+		after = batch[len(batch)-1].ID
+	}
+
+	log.Printf("projections rebuilt from %d events", n)
+	return nil
+}
+
+// project decodes the event payload into the read model change.
+func project(e event.Event) (Change, error) {
+	c := Change{
+		Activity: Activity{
+			EventID:  e.ID,
+			Type:     e.Type,
+			WalletID: e.AggregateID,
+			Time:     e.Time,
+		},
+	}
+
+	total := &DailyTotal{
+		WalletID: e.AggregateID,
+		Date:     e.Time.UTC().Format(DateLayout),
+		Count:    1,
+	}
+
+	switch e.Type {
+	case event.EventDiscarded:
+		// the wallet continues after the event which was never published.
+		c.Skip = true
+	case event.WalletCreated:
+		var p event.Created
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+		c.Activity.Currency = p.Currency
+	case event.WalletRenamed, event.WalletDeleted, event.WalletLimitsChanged,
+		event.WalletInterestChanged:
+	case event.WalletCreditChanged:
+		var p event.CreditChanged
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+		c.Activity.Amount, c.Activity.Currency = p.CreditLimit, p.Currency
+	case event.WalletOverdraftStarted, event.WalletOverdraftEnded:
+		// an overdraft follows a balance change counted by its own event.
+		var p event.Overdraft
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+		c.Activity.Amount, c.Activity.Currency = p.Balance, p.Currency
+	case event.WalletDeposited, event.WalletWithdrawn:
+		var p event.Operation
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+		total.Currency = p.Currency
+		if e.Type == event.WalletDeposited {
+			total.Deposited = p.Amount
+		} else {
+			total.Withdrawn = p.Amount
+		}
+		c.Total = total
+	case event.WalletTransferSent, event.WalletTransferReceived:
+		var p event.Transfer
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+		c.Activity.Counterparty = p.Counterparty
+		total.Currency = p.Currency
+		if e.Type == event.WalletTransferReceived {
+			total.TransferredIn = p.Amount
+		} else {
+			total.TransferredOut = p.Amount
+		}
+		c.Total = total
+	case event.WalletHoldPlaced:
+		// a hold changes only the available balance, not the totals.
+		var p event.HoldPlaced
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+	case event.WalletHoldReleased:
+		var p event.HoldReleased
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+	case event.WalletHoldCaptured:
+		var p event.HoldCaptured
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+		total.Currency = p.Currency
+		total.Withdrawn = p.Amount
+		c.Total = total
+	case event.TransactionReversed:
+		var p event.Reversed
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+		c.Activity.Counterparty = p.Counterparty
+		total.Currency = p.Currency
+		if p.Type == storage.TxReversalIn {
+			total.ReversedIn = p.Amount
+		} else {
+			total.ReversedOut = p.Amount
+		}
+		c.Total = total
+	case event.WalletFeeCharged:
+		var p event.FeeCharged
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+		total.Currency = p.Currency
+		total.Fees = p.Amount
+		c.Total = total
+	case event.WalletFeeCollected:
+		var p event.FeeCollected
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+		c.Activity.Counterparty = p.ChargedTo
+		total.Currency = p.Currency
+		total.FeesCollected = p.Amount
+		c.Total = total
+	case event.WalletOverdraftCharged:
+		var p event.OverdraftCharged
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+		total.Currency = p.Currency
+		total.OverdraftInterest = p.Amount
+		c.Total = total
+	case event.WalletInterestPaid:
+		var p event.InterestPaid
+		if err := json.Unmarshal(e.Payload, &p); err != nil {
+			return Change{}, fmt.Errorf("event %s decode error: %w", e.ID, err)
+		}
+
+		c.Activity.Amount, c.Activity.Currency = p.Amount, p.Currency
+		total.Currency = p.Currency
+		total.Interest = p.Amount
+		c.Total = total
+	default:
+		return Change{}, fmt.Errorf("unknown event type %s", e.Type)
+	}
+
+	return c, nil
+}
@@ -0,0 +1,147 @@
+// Package store contains an in-memory implementation
+// of the projection read models.
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"wallet/app/event"
+	"wallet/app/oops"
+	"wallet/app/projection"
+)
+
+// Storage keeps the read models in memory, they are rebuilt
+// from the recorded events on start.
+type Storage struct {
+	// seen holds applied event ids and checkpoints holds
+	// the sequence of the last applied event by wallet id.
+	seen        map[string]struct{}
+	checkpoints map[string]uint64
+	// totals holds daily totals by wallet id and date.
+	totals map[string]map[string]projection.DailyTotal
+	// feed is the activity feed in order of application,
+	// positions indexes it by event id.
+	feed      []projection.Activity
+	positions map[string]int
+	sync.RWMutex
+}
+
+// NewStorage is a constructor for storage.
+func NewStorage() *Storage {
+	return &Storage{
+		seen:        make(map[string]struct{}),
+		checkpoints: make(map[string]uint64),
+		totals:      make(map[string]map[string]projection.DailyTotal),
+		positions:   make(map[string]int),
+	}
+}
+
+// Apply adds the change of the event to the read models once.
+func (s *Storage) Apply(ctx context.Context, e event.Event, c projection.Change) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, found := s.seen[e.ID]; found {
+		return nil
+	}
+
+	checkpoint := s.checkpoints[e.AggregateID]
+	if e.Sequence <= checkpoint {
+		return nil
+	}
+	if e.Sequence != checkpoint+1 {
+		return oops.ErrOutOfOrder
+	}
+
+	s.seen[e.ID] = struct{}{}
+	s.checkpoints[e.AggregateID] = e.Sequence
+	if c.Skip {
+		return nil
+	}
+
+	if t := c.Total; t != nil {
+		days, found := s.totals[t.WalletID]
+		if !found {
+			days = make(map[string]projection.DailyTotal)
+			s.totals[t.WalletID] = days
+		}
+
+		day := days[t.Date]
+		day.WalletID, day.Date, day.Currency = t.WalletID, t.Date, t.Currency
+		day.Deposited += t.Deposited
+		day.Withdrawn += t.Withdrawn
+		day.TransferredIn += t.TransferredIn
+		day.TransferredOut += t.TransferredOut
+		day.ReversedIn += t.ReversedIn
+		day.ReversedOut += t.ReversedOut
+		day.Fees += t.Fees
+		day.FeesCollected += t.FeesCollected
+		day.OverdraftInterest += t.OverdraftInterest
+		day.Interest += t.Interest
+		day.Count += t.Count
+		days[t.Date] = day
+	}
+
+	s.positions[e.ID] = len(s.feed)
+	s.feed = append(s.feed, c.Activity)
+
+	return nil
+}
+
+// DailyTotals returns wallet totals by day between from and to inclusive.
+func (s *Storage) DailyTotals(ctx context.Context, id, from, to string) ([]projection.DailyTotal, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	if _, found := s.checkpoints[id]; !found {
+		return nil, oops.ErrNotFound
+	}
+
+	totals := make([]projection.DailyTotal, 0, len(s.totals[id]))
+	for date, t := range s.totals[id] {
+		if (from != "" && date < from) || (to != "" && date > to) {
+			continue
+		}
+		totals = append(totals, t)
+	}
+
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Date < totals[j].Date
+	})
+
+	return totals, nil
+}
+
+// Feed returns a page of the activity feed, newest first.
+func (s *Storage) Feed(ctx context.Context, f projection.FeedFilter) (projection.Feed, error) {
+	s.RLock()
+	defer s.RUnlock()
+
+	end := len(s.feed)
+	if f.Cursor != "" {
+		pos, found := s.positions[f.Cursor]
+		if !found {
+			return projection.Feed{}, oops.ErrBadFilter
+		}
+		end = pos
+	}
+
+	start := end - f.Limit
+	if start < 0 {
+		start = 0
+	}
+
+	feed := projection.Feed{
+		Activities: make([]projection.Activity, 0, end-start),
+	}
+	for i := end - 1; i >= start; i-- {
+		feed.Activities = append(feed.Activities, s.feed[i])
+	}
+	if start > 0 {
+		feed.NextCursor = s.feed[start].EventID
+	}
+
+	return feed, nil
+}
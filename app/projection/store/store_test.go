@@ -0,0 +1,127 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"wallet/app/event"
+	"wallet/app/oops"
+	outboxStorage "wallet/app/outbox/store"
+	"wallet/app/projection"
+	"wallet/app/projection/store"
+	"wallet/app/queue"
+	"wallet/app/storage"
+	"wallet/app/storage/bolt"
+)
+
+// deliver sends the event through the queue encoding to the projector.
+func deliver(t *testing.T, p *projection.Projector, e event.Event) error {
+	t.Helper()
+	enc, err := queue.NewEncoder(queue.FormatEnvelope, "/wallet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := enc.Encode(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := queue.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.Handle(context.Background(), decoded)
+}
+
+func TestDiscardedEventDoesNotBlockWallet(t *testing.T) {
+	dbs := map[string]func(t *testing.T) storage.DB{
+		"memory": func(*testing.T) storage.DB { return storage.NewMemory() },
+		"bolt": func(t *testing.T) storage.DB {
+			db, err := bolt.Open(filepath.Join(t.TempDir(), "wallet.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return db
+		},
+	}
+
+	for name, open := range dbs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			db := open(t)
+			outbox := outboxStorage.NewStorage(db)
+
+			err := db.Update(func(tx storage.Tx) error {
+				if err := event.Record(ctx, tx, "w", event.WalletCreated, event.Created{WalletID: "w", Name: "w", Currency: "USD"}); err != nil {
+					return err
+				}
+				deposits := []event.Operation{
+					{WalletID: "w", TransactionID: "t1", Amount: 10000, Currency: "USD", BalanceAfter: 10000},
+					{WalletID: "w", TransactionID: "t2", Amount: 20000, Currency: "USD", BalanceAfter: 30000},
+				}
+				for _, d := range deposits {
+					if err := event.Record(ctx, tx, "w", event.WalletDeposited, d); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pending, err := outbox.Pending(ctx, 10, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pending) != 3 {
+				t.Fatalf("pending %d events, want 3", len(pending))
+			}
+
+			projections := store.NewStorage()
+			p := projection.NewProjector(projections)
+			if err = deliver(t, p, pending[0]); err != nil {
+				t.Fatal(err)
+			}
+			if err = outbox.MarkDelivered(ctx, pending[0].ID); err != nil {
+				t.Fatal(err)
+			}
+
+			// the second event is dead-lettered, the third can't be applied.
+			if err = outbox.MarkDead(ctx, pending[1].ID, "publish failed", 10); err != nil {
+				t.Fatal(err)
+			}
+			if err = deliver(t, p, pending[2]); !errors.Is(err, oops.ErrOutOfOrder) {
+				t.Fatalf("event after the gap error = %v, want %v", err, oops.ErrOutOfOrder)
+			}
+
+			if err = outbox.Discard(ctx, pending[1].ID); err != nil {
+				t.Fatal(err)
+			}
+
+			pending, err = outbox.Pending(ctx, 10, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(pending) != 2 || pending[0].Type != event.EventDiscarded || pending[0].Sequence != 2 {
+				t.Fatalf("pending after discard = %+v, want the tombstone of sequence 2 first", pending)
+			}
+
+			for _, e := range pending {
+				if err = deliver(t, p, e); err != nil {
+					t.Fatalf("event %s error: %s", e.ID, err.Error())
+				}
+			}
+
+			totals, err := projections.DailyTotals(ctx, "w", "", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(totals) != 1 || totals[0].Deposited != 20000 {
+				t.Fatalf("totals = %+v, want only the deposit after the discarded one", totals)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package projection
+
+import (
+	"context"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/money"
+)
+
+// DateLayout is the format of daily totals dates.
+const DateLayout = "2006-01-02"
+
+// DailyTotal sums the balance changes of a wallet in a UTC day.
+type DailyTotal struct {
+	WalletID          string         `json:"wallet_id"`
+	Date              string         `json:"date"`
+	Currency          money.Currency `json:"currency"`
+	Deposited         money.Amount   `json:"deposited"`
+	Withdrawn         money.Amount   `json:"withdrawn"`
+	TransferredIn     money.Amount   `json:"transferred_in"`
+	TransferredOut    money.Amount   `json:"transferred_out"`
+	ReversedIn        money.Amount   `json:"reversed_in"`
+	ReversedOut       money.Amount   `json:"reversed_out"`
+	Fees              money.Amount   `json:"fees"`
+	FeesCollected     money.Amount   `json:"fees_collected"`
+	OverdraftInterest money.Amount   `json:"overdraft_interest"`
+	Interest          money.Amount   `json:"interest"`
+	Count             int            `json:"count"`
+}
+
+// Activity is an entry of the global activity feed.
+type Activity struct {
+	EventID      string         `json:"event_id"`
+	Type         string         `json:"type"`
+	WalletID     string         `json:"wallet_id"`
+	Counterparty string         `json:"counterparty,omitempty"`
+	Amount       money.Amount   `json:"amount,omitempty"`
+	Currency     money.Currency `json:"currency,omitempty"`
+	Time         time.Time      `json:"time"`
+}
+
+// Change is what an event adds to the read models, Total is
+// nil for events which don't change a balance. Skip is set for
+// the tombstone of a discarded event, which adds nothing.
+type Change struct {
+	Activity Activity
+	Total    *DailyTotal
+	Skip     bool
+}
+
+// FeedFilter selects a page of the activity feed, newest first.
+// Cursor is the event id of the last entry of the previous page.
+type FeedFilter struct {
+	Cursor string
+	Limit  int
+}
+
+// Feed contains activities and a cursor for the next page,
+// NextCursor is empty on the last page.
+type Feed struct {
+	Activities []Activity `json:"activities"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// Store contains all methods to keep the read models.
+type Store interface {
+	// Apply adds the change of the event to the read models once:
+	// an already applied event is skipped and an event following
+	// a missing one of its wallet returns oops.ErrOutOfOrder.
+	Apply(context.Context, event.Event, Change) error
+	DailyTotals(ctx context.Context, id string, from, to string) ([]DailyTotal, error)
+	Feed(context.Context, FeedFilter) (Feed, error)
+}
+
+// EventLog is the source of the recorded events to rebuild the read models.
+type EventLog interface {
+	Events(ctx context.Context, after string, limit int) ([]event.Event, error)
+}
+
+// Service contains all methods from projection service.
+type Service interface {
+	DailyTotals(ctx context.Context, id string, from, to string) ([]DailyTotal, error)
+	Feed(context.Context, FeedFilter) (Feed, error)
+}
@@ -0,0 +1,114 @@
+package queue
+
+import (
+	"errors"
+	"sync"
+
+	"wallet/app/event"
+)
+
+// ErrClosed is returned by a publisher after Stop.
+var ErrClosed = errors.New("publisher is stopped")
+
+// Bus is an in-process publisher delivering events to subscribers
+// over channels. Publishing blocks until every subscriber channel
+// accepted the event, so subscribers must keep reading until Stop
+// or cancel their subscription.
+type Bus struct {
+	buffer      int
+	subscribers []*subscription
+	closed      bool
+	sync.RWMutex
+}
+
+// subscription is a subscriber channel, done is closed
+// when the subscriber stops reading.
+type subscription struct {
+	events chan event.Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+func (s *subscription) cancel() {
+	s.once.Do(func() { close(s.done) })
+}
+
+// NewBus is a Bus constructor, buffer is the capacity of subscriber channels.
+func NewBus(buffer int) *Bus {
+	return &Bus{
+		buffer: buffer,
+	}
+}
+
+// Subscribe returns a channel receiving every event published
+// after the call, the channel is closed by Stop.
+func (b *Bus) Subscribe() <-chan event.Event {
+	return b.subscribe().events
+}
+
+func (b *Bus) subscribe() *subscription {
+	b.Lock()
+	defer b.Unlock()
+
+	s := &subscription{
+		events: make(chan event.Event, b.buffer),
+		done:   make(chan struct{}),
+	}
+	if b.closed {
+		close(s.events)
+		return s
+	}
+
+	b.subscribers = append(b.subscribers, s)
+	return s
+}
+
+// Wallet delivers a wallet lifecycle event to the subscribers.
+func (b *Bus) Wallet(e event.Event, ch chan error) {
+	ch <- b.publish(e)
+}
+
+// Operation delivers a balance change event to the subscribers.
+func (b *Bus) Operation(e event.Event, ch chan error) {
+	ch <- b.publish(e)
+}
+
+// Publish delivers the events to the subscribers in order.
+func (b *Bus) Publish(events []event.Event) error {
+	b.RLock()
+	defer b.RUnlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	for _, e := range events {
+		for _, s := range b.subscribers {
+			select {
+			case s.events <- e:
+			case <-s.done:
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *Bus) publish(e event.Event) error {
+	return b.Publish([]event.Event{e})
+}
+
+// Stop closes the subscriber channels.
+func (b *Bus) Stop() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.closed = true
+	for _, s := range b.subscribers {
+		close(s.events)
+	}
+}
@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"wallet/app/event"
+
+	"github.com/nsqio/go-nsq"
+)
+
+// Handler processes a consumed event, an error asks to deliver it again.
+type Handler func(context.Context, event.Event) error
+
+// Consumer delivers events to a handler at least once until ctx is done.
+type Consumer interface {
+	Consume(ctx context.Context, h Handler) error
+}
+
+// Source is a publisher which can consume the events it publishes.
+type Source interface {
+	// Consumer subscribes to the events published after the call.
+	Consumer() (Consumer, error)
+}
+
+// redeliveryDelay is the pause before an event is handled again.
+const redeliveryDelay = time.Second
+
+// busConsumer consumes a Bus subscription.
+type busConsumer struct {
+	s *subscription
+}
+
+// Consumer subscribes to the bus, the subscription is made now so
+// no event published before Consume is called is missed.
+func (b *Bus) Consumer() (Consumer, error) {
+	return &busConsumer{
+		s: b.subscribe(),
+	}, nil
+}
+
+// Consume delivers the events published to the bus to h,
+// a failed event is handled again until it succeeds. The subscription
+// is cancelled on return, so the bus doesn't wait for it any more.
+func (c *busConsumer) Consume(ctx context.Context, h Handler) error {
+	defer c.s.cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-c.s.events:
+			if !ok {
+				return nil
+			}
+
+			for {
+				err := h(ctx, e)
+				if err == nil {
+					break
+				}
+				log.Printf("consume event %s error: %s", e.ID, err.Error())
+
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-time.After(redeliveryDelay):
+				}
+			}
+		}
+	}
+}
+
+// Consumer subscribes to the first publisher which is a Source.
+func (q *FanOut) Consumer() (Consumer, error) {
+	for _, p := range q.publishers {
+		if s, ok := p.(Source); ok {
+			return s.Consumer()
+		}
+	}
+	return nil, fmt.Errorf("no consumer source among publishers")
+}
+
+// NSQConsumer consumes the wallet topic from nsqd on a channel.
+type NSQConsumer struct {
+	addr, channel string
+}
+
+// NewNSQConsumer is a NSQConsumer constructor.
+func NewNSQConsumer(addr, channel string) *NSQConsumer {
+	return &NSQConsumer{
+		addr:    addr,
+		channel: channel,
+	}
+}
+
+// Consume delivers messages to h, a message is requeued if it
+// can't be decoded or h fails and finished only after h succeeded.
+func (c *NSQConsumer) Consume(ctx context.Context, h Handler) error {
+	consumer, err := nsq.NewConsumer(topic, c.channel, nsq.NewConfig())
+	if err != nil {
+		return fmt.Errorf("NewConsumer error: %w", err)
+	}
+
+	consumer.AddHandler(nsq.HandlerFunc(func(m *nsq.Message) error {
+		e, err := Decode(m.Body)
+		if err != nil {
+			return err
+		}
+		return h(ctx, e)
+	}))
+
+	log.Printf("starting nsq consumer at %s on channel %s", c.addr, c.channel)
+	if err = consumer.ConnectToNSQD(c.addr); err != nil {
+		return fmt.Errorf("cannot connect to queue: %w", err)
+	}
+
+	<-ctx.Done()
+	consumer.Stop()
+	<-consumer.StopChan
+
+	return nil
+}
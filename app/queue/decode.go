@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"wallet/app/event"
+)
+
+// Decode reads an event from a message in either format.
+func Decode(data []byte) (event.Event, error) {
+	var probe struct {
+		SpecVersion string `json:"specversion"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return event.Event{}, fmt.Errorf("message decode error: %w", err)
+	}
+
+	if probe.SpecVersion != "" {
+		return decodeCloudEvent(data)
+	}
+
+	return decodeEnvelope(data)
+}
+
+func decodeEnvelope(data []byte) (event.Event, error) {
+	if err := Validate(data); err != nil {
+		return event.Event{}, err
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return event.Event{}, fmt.Errorf("message decode error: %w", err)
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, env.Time)
+	if err != nil {
+		return event.Event{}, fmt.Errorf("message decode error: %w", err)
+	}
+
+	return event.Event{
+		ID:            env.ID,
+		Type:          env.Type,
+		Version:       env.SchemaVersion,
+		AggregateID:   env.AggregateID,
+		Sequence:      env.Sequence,
+		CorrelationID: env.CorrelationID,
+		Time:          t,
+		Payload:       env.Payload,
+	}, nil
+}
+
+func decodeCloudEvent(data []byte) (event.Event, error) {
+	var ce CloudEvent
+	if err := json.Unmarshal(data, &ce); err != nil {
+		return event.Event{}, fmt.Errorf("message decode error: %w", err)
+	}
+
+	typ := ""
+	for k, v := range cloudEventTypes {
+		if v == ce.Type {
+			typ = k
+			break
+		}
+	}
+	if typ == "" {
+		return event.Event{}, fmt.Errorf("%w: unknown CloudEvents type %s", ErrSchema, ce.Type)
+	}
+
+	if err := ValidatePayload(typ, ce.SchemaVersion, ce.Data); err != nil {
+		return event.Event{}, err
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, ce.Time)
+	if err != nil {
+		return event.Event{}, fmt.Errorf("message decode error: %w", err)
+	}
+
+	return event.Event{
+		ID:            ce.ID,
+		Type:          typ,
+		Version:       ce.SchemaVersion,
+		AggregateID:   ce.Subject,
+		Sequence:      ce.Sequence,
+		CorrelationID: ce.CorrelationID,
+		Time:          t,
+		Payload:       ce.Data,
+	}, nil
+}
@@ -0,0 +1,126 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"wallet/app/event"
+)
+
+// Message formats.
+const (
+	FormatEnvelope    = "envelope"
+	FormatCloudEvents = "cloudevents"
+)
+
+// Encoder encodes an event into a queue message.
+type Encoder interface {
+	Encode(event.Event) ([]byte, error)
+}
+
+// NewEncoder returns the Encoder of the format, source
+// is the CloudEvents source of the published events.
+func NewEncoder(format, source string) (Encoder, error) {
+	switch format {
+	case FormatEnvelope:
+		return EnvelopeEncoder{}, nil
+	case FormatCloudEvents:
+		return CloudEventsEncoder{Source: source}, nil
+	}
+	return nil, fmt.Errorf("unknown message format %q", format)
+}
+
+// EnvelopeEncoder encodes events as Envelope.
+type EnvelopeEncoder struct{}
+
+// Encode validates and encodes the event envelope.
+func (EnvelopeEncoder) Encode(e event.Event) ([]byte, error) {
+	data, err := json.Marshal(NewEnvelope(e))
+	if err != nil {
+		return nil, fmt.Errorf("message encode error: %w", err)
+	}
+
+	if err = Validate(data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// cloudEventTypes maps event types to CloudEvents types.
+var cloudEventTypes = map[string]string{
+	event.WalletCreated:          "wallet.created",
+	event.WalletRenamed:          "wallet.renamed",
+	event.WalletDeleted:          "wallet.deleted",
+	event.WalletDeposited:        "wallet.deposited",
+	event.WalletWithdrawn:        "wallet.withdrawn",
+	event.WalletTransferSent:     "wallet.transfer.sent",
+	event.WalletTransferReceived: "wallet.transfer.received",
+	event.WalletHoldPlaced:       "wallet.hold.placed",
+	event.WalletHoldCaptured:     "wallet.hold.captured",
+	event.WalletHoldReleased:     "wallet.hold.released",
+	event.TransactionReversed:    "wallet.transaction.reversed",
+	event.WalletLimitsChanged:    "wallet.limits.changed",
+	event.WalletFeeCharged:       "wallet.fee.charged",
+	event.WalletFeeCollected:     "wallet.fee.collected",
+	event.WalletCreditChanged:    "wallet.credit.changed",
+	event.WalletOverdraftStarted: "wallet.overdraft.started",
+	event.WalletOverdraftEnded:   "wallet.overdraft.ended",
+	event.WalletOverdraftCharged: "wallet.overdraft.charged",
+	event.WalletInterestChanged:  "wallet.interest.changed",
+	event.WalletInterestPaid:     "wallet.interest.paid",
+	event.EventDiscarded:         "wallet.event.discarded",
+}
+
+// CloudEvent is a CloudEvents 1.0 event in the structured JSON mode,
+// correlationid, sequence and schemaversion are extension attributes.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	CorrelationID   string          `json:"correlationid,omitempty"`
+	Sequence        uint64          `json:"sequence"`
+	SchemaVersion   int             `json:"schemaversion"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// CloudEventsEncoder encodes events as structured CloudEvents.
+type CloudEventsEncoder struct {
+	Source string
+}
+
+// Encode validates the payload and encodes the event as a CloudEvent.
+func (c CloudEventsEncoder) Encode(e event.Event) ([]byte, error) {
+	typ, found := cloudEventTypes[e.Type]
+	if !found {
+		return nil, fmt.Errorf("%w: unknown event type %s", ErrSchema, e.Type)
+	}
+
+	if err := ValidatePayload(e.Type, e.Version, e.Payload); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(CloudEvent{
+		SpecVersion:     "1.0",
+		ID:              e.ID,
+		Source:          c.Source,
+		Type:            typ,
+		Subject:         e.AggregateID,
+		Time:            e.Time.UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+		CorrelationID:   e.CorrelationID,
+		Sequence:        e.Sequence,
+		SchemaVersion:   e.Version,
+		Data:            e.Payload,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("message encode error: %w", err)
+	}
+
+	return data, nil
+}
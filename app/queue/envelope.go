@@ -0,0 +1,35 @@
+package queue
+
+import (
+	"encoding/json"
+	"time"
+
+	"wallet/app/event"
+)
+
+// Envelope is the message published to the queue, Payload is the
+// event type payload at SchemaVersion, see the schema directory.
+type Envelope struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	Time          string          `json:"time"`
+	AggregateID   string          `json:"aggregate_id"`
+	Sequence      uint64          `json:"sequence"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// NewEnvelope wraps an event into the message envelope.
+func NewEnvelope(e event.Event) Envelope {
+	return Envelope{
+		ID:            e.ID,
+		Type:          e.Type,
+		SchemaVersion: e.Version,
+		Time:          e.Time.UTC().Format(time.RFC3339Nano),
+		AggregateID:   e.AggregateID,
+		Sequence:      e.Sequence,
+		CorrelationID: e.CorrelationID,
+		Payload:       e.Payload,
+	}
+}
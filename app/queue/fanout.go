@@ -0,0 +1,65 @@
+package queue
+
+import (
+	"errors"
+
+	"wallet/app/event"
+)
+
+// FanOut is a publisher writing every event to several publishers,
+// an event is acknowledged only if all of them accepted it, so a retried
+// event is published again by the publishers which accepted it before.
+type FanOut struct {
+	publishers []Publisher
+}
+
+// NewFanOut is a FanOut constructor.
+func NewFanOut(publishers ...Publisher) *FanOut {
+	return &FanOut{
+		publishers: publishers,
+	}
+}
+
+// Wallet sends a wallet lifecycle event to every publisher.
+func (q *FanOut) Wallet(e event.Event, ch chan error) {
+	ch <- q.publish(func(p Publisher, errCh chan error) { p.Wallet(e, errCh) })
+}
+
+// Operation sends a balance change event to every publisher.
+func (q *FanOut) Operation(e event.Event, ch chan error) {
+	ch <- q.publish(func(p Publisher, errCh chan error) { p.Operation(e, errCh) })
+}
+
+// Publish sends the events to every publisher, as a batch
+// to the publishers which support it.
+func (q *FanOut) Publish(events []event.Event) error {
+	return q.publish(func(p Publisher, errCh chan error) {
+		if b, ok := p.(BatchPublisher); ok {
+			errCh <- b.Publish(events)
+			return
+		}
+		errCh <- publishEach(p, events)
+	})
+}
+
+// publish calls send on the publishers concurrently and joins their errors.
+func (q *FanOut) publish(send func(Publisher, chan error)) error {
+	errCh := make(chan error, len(q.publishers))
+	for _, p := range q.publishers {
+		go send(p, errCh)
+	}
+
+	errs := make([]error, 0, len(q.publishers))
+	for range q.publishers {
+		errs = append(errs, <-errCh)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Stop stops every publisher.
+func (q *FanOut) Stop() {
+	for _, p := range q.publishers {
+		p.Stop()
+	}
+}
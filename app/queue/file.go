@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"wallet/app/event"
+)
+
+// File is a publisher appending encoded events to a JSON Lines file.
+type File struct {
+	f       *os.File
+	encoder Encoder
+	sync.Mutex
+}
+
+// NewFile opens or creates the file to append events encoded by encoder.
+func NewFile(path string, encoder Encoder) (*File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("NewFile error: %w", err)
+	}
+
+	return &File{
+		f:       f,
+		encoder: encoder,
+	}, nil
+}
+
+// Wallet appends a wallet lifecycle event to the file.
+func (q *File) Wallet(e event.Event, ch chan error) {
+	ch <- q.publish(e)
+}
+
+// Operation appends a balance change event to the file.
+func (q *File) Operation(e event.Event, ch chan error) {
+	ch <- q.publish(e)
+}
+
+// Publish appends the events with a single write and fsync.
+func (q *File) Publish(events []event.Event) error {
+	var lines []byte
+	for _, e := range events {
+		payload, err := q.encoder.Encode(e)
+		if err != nil {
+			return err
+		}
+		lines = append(append(lines, payload...), '\n')
+	}
+
+	q.Lock()
+	defer q.Unlock()
+
+	if q.f == nil {
+		return ErrClosed
+	}
+
+	if _, err := q.f.Write(lines); err != nil {
+		return fmt.Errorf("cannot write message to the file: %w", err)
+	}
+
+	return q.f.Sync()
+}
+
+// publish writes the event as a line and fsyncs the file,
+// so an acknowledged event survives a crash.
+func (q *File) publish(e event.Event) error {
+	return q.Publish([]event.Event{e})
+}
+
+// Stop closes the file.
+func (q *File) Stop() {
+	q.Lock()
+	defer q.Unlock()
+
+	if q.f == nil {
+		return
+	}
+
+	if err := q.f.Close(); err != nil {
+		log.Println(err.Error())
+	}
+	q.f = nil
+}
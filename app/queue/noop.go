@@ -0,0 +1,24 @@
+package queue
+
+import "wallet/app/event"
+
+// Noop is a publisher which drops every event.
+type Noop struct{}
+
+// Wallet acknowledges the event without publishing it.
+func (Noop) Wallet(_ event.Event, ch chan error) {
+	ch <- nil
+}
+
+// Operation acknowledges the event without publishing it.
+func (Noop) Operation(_ event.Event, ch chan error) {
+	ch <- nil
+}
+
+// Publish drops the events.
+func (Noop) Publish([]event.Event) error {
+	return nil
+}
+
+// Stop does nothing.
+func (Noop) Stop() {}
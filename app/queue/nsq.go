@@ -2,10 +2,10 @@
 package queue
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
-	"time"
+
+	"wallet/app/event"
 
 	"github.com/nsqio/go-nsq"
 )
@@ -15,20 +15,16 @@ const topic = "wallet"
 // NSQ is a struct for queue producer.
 type NSQ struct {
 	producer *nsq.Producer
+	encoder  Encoder
 }
 
-type message struct {
-	Timestamp string  `json:"timestamp,omitempty"`
-	Content   float64 `json:"content,omitempty"`
-	Name      string  `json:"name,omitempty"`
-}
-
-// NewNSQ reads config and instantiates a producer.
-func NewNSQ() (*NSQ, error) {
+// NewNSQ instantiates a producer connected to nsqd
+// at addr publishing messages encoded by encoder.
+func NewNSQ(addr string, encoder Encoder) (*NSQ, error) {
 	config := nsq.NewConfig()
 
-	log.Println("starting nsq producer at port 4150")
-	producer, err := nsq.NewProducer("127.0.0.1:4150", config)
+	log.Printf("starting nsq producer at %s", addr)
+	producer, err := nsq.NewProducer(addr, config)
 	if err != nil {
 		return nil, fmt.Errorf("NewNSQ error: %w", err)
 	}
@@ -40,6 +36,7 @@ func NewNSQ() (*NSQ, error) {
 
 	return &NSQ{
 		producer: producer,
+		encoder:  encoder,
 	}, nil
 }
 
@@ -49,41 +46,44 @@ func (q *NSQ) Stop() {
 	q.producer.Stop()
 }
 
-// Wallet sends a message to the queue.
-func (q *NSQ) Wallet(name string, ch chan error) {
-	msg := message{
-		Timestamp: time.Now().String(),
-		Name:      name,
-	}
+// Wallet sends a wallet lifecycle event to the queue.
+func (q *NSQ) Wallet(e event.Event, ch chan error) {
+	ch <- q.publish(e)
+}
+
+// Operation sends a balance change event to the queue.
+func (q *NSQ) Operation(e event.Event, ch chan error) {
+	ch <- q.publish(e)
+}
 
-	payload, err := json.Marshal(msg)
+// publish encodes the event and sends it to the queue.
+func (q *NSQ) publish(e event.Event) error {
+	payload, err := q.encoder.Encode(e)
 	if err != nil {
-		log.Println(err)
+		return err
 	}
 
-	err = q.producer.Publish(topic, payload)
-	if err != nil {
-		ch <- fmt.Errorf("cannot publish message to the queue: %w", err)
+	if err = q.producer.Publish(topic, payload); err != nil {
+		return fmt.Errorf("cannot publish message to the queue: %w", err)
 	}
-	ch <- nil
+
+	return nil
 }
 
-// Operation sends a message to the queue.
-func (q *NSQ) Operation(name string, content float64, ch chan error) {
-	msg := message{
-		Timestamp: time.Now().String(),
-		Name:      name,
-		Content:   content,
+// Publish sends the events to the queue with a single MultiPublish.
+func (q *NSQ) Publish(events []event.Event) error {
+	bodies := make([][]byte, 0, len(events))
+	for _, e := range events {
+		payload, err := q.encoder.Encode(e)
+		if err != nil {
+			return err
+		}
+		bodies = append(bodies, payload)
 	}
 
-	payload, err := json.Marshal(msg)
-	if err != nil {
-		log.Println(err)
+	if err := q.producer.MultiPublish(topic, bodies); err != nil {
+		return fmt.Errorf("cannot publish messages to the queue: %w", err)
 	}
 
-	err = q.producer.Publish(topic, payload)
-	if err != nil {
-		ch <- fmt.Errorf("cannot publish message to the queue: %w", err)
-	}
-	ch <- nil
+	return nil
 }
@@ -0,0 +1,261 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"wallet/app/event"
+)
+
+// ErrPredecessorFailed is returned for an event which is not published
+// because an earlier event of its aggregate failed.
+var ErrPredecessorFailed = errors.New("earlier event of the aggregate failed")
+
+// BatchPublisher publishes several events at once, all or none of them.
+type BatchPublisher interface {
+	Publish([]event.Event) error
+}
+
+// PipelineOptions contains the worker pool settings.
+type PipelineOptions struct {
+	// Workers is the number of publishing goroutines, events of one
+	// aggregate always go to the same worker so they keep their order.
+	Workers int
+	// Buffer is the queue capacity of a worker, Submit blocks when it is full.
+	Buffer int
+	// BatchSize is the largest number of events published at once.
+	BatchSize int
+	// FlushInterval is how long a worker waits to fill a batch.
+	FlushInterval time.Duration
+}
+
+// Stats are the pipeline counters, Blocked counts the submits which
+// waited for buffer space and BlockedTime is the total wait.
+type Stats struct {
+	Workers     int           `json:"workers"`
+	Capacity    int           `json:"capacity"`
+	Queued      int           `json:"queued"`
+	Submitted   int64         `json:"submitted"`
+	Published   int64         `json:"published"`
+	Failed      int64         `json:"failed"`
+	Batches     int64         `json:"batches"`
+	Blocked     int64         `json:"blocked"`
+	BlockedTime time.Duration `json:"blocked_time"`
+}
+
+// Pipeline publishes events in batches on a pool of workers and
+// reports the result of every batch to done.
+//
+// An event is submitted with the epoch of its aggregate, which the caller
+// increases every time done reports a failure of the aggregate. A failed
+// event fails the events of its aggregate submitted with the same or an
+// older epoch with ErrPredecessorFailed, as they were submitted before
+// the caller knew about the failure, so an aggregate is never published
+// out of order.
+type Pipeline struct {
+	publisher Service
+	opts      PipelineOptions
+	done      func([]event.Event, error)
+	workers   []chan item
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+
+	submitted, published, failed, batches, blocked, blockedTime atomic.Int64
+}
+
+// item is a submitted event.
+type item struct {
+	e     event.Event
+	epoch uint64
+}
+
+// NewPipeline starts the workers of a Pipeline.
+func NewPipeline(publisher Service, opts PipelineOptions, done func([]event.Event, error)) *Pipeline {
+	p := &Pipeline{
+		publisher: publisher,
+		opts:      opts,
+		done:      done,
+		workers:   make([]chan item, opts.Workers),
+	}
+
+	for i := range p.workers {
+		p.workers[i] = make(chan item, opts.Buffer)
+		p.wg.Add(1)
+		go p.work(p.workers[i])
+	}
+
+	return p
+}
+
+// Submit queues the event, it blocks while the worker queue is full
+// and returns ctx.Err() if ctx is done first.
+func (p *Pipeline) Submit(ctx context.Context, e event.Event, epoch uint64) error {
+	h := fnv.New32a()
+	h.Write([]byte(e.AggregateID))
+	w := p.workers[h.Sum32()%uint32(len(p.workers))]
+	it := item{e: e, epoch: epoch}
+
+	select {
+	case w <- it:
+		p.submitted.Add(1)
+		return nil
+	default:
+	}
+
+	p.blocked.Add(1)
+	start := time.Now()
+	defer func() { p.blockedTime.Add(int64(time.Since(start))) }()
+
+	select {
+	case w <- it:
+		p.submitted.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Drain stops accepting events and waits until the queued ones
+// are published or ctx is done. Submit must not be called after it.
+func (p *Pipeline) Drain(ctx context.Context) error {
+	p.closeOnce.Do(func() {
+		for _, w := range p.workers {
+			close(w)
+		}
+	})
+
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the current counters.
+func (p *Pipeline) Stats() Stats {
+	queued := 0
+	for _, w := range p.workers {
+		queued += len(w)
+	}
+
+	return Stats{
+		Workers:     len(p.workers),
+		Capacity:    len(p.workers) * p.opts.Buffer,
+		Queued:      queued,
+		Submitted:   p.submitted.Load(),
+		Published:   p.published.Load(),
+		Failed:      p.failed.Load(),
+		Batches:     p.batches.Load(),
+		Blocked:     p.blocked.Load(),
+		BlockedTime: time.Duration(p.blockedTime.Load()),
+	}
+}
+
+// work collects batches from the worker queue until it is closed.
+func (p *Pipeline) work(in chan item) {
+	defer p.wg.Done()
+
+	// failed holds the epoch of the last failure by aggregate id.
+	failed := make(map[string]uint64)
+
+	for it := range in {
+		batch := []item{it}
+		timer := time.NewTimer(p.opts.FlushInterval)
+
+	collect:
+		for len(batch) < p.opts.BatchSize {
+			select {
+			case it, ok := <-in:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, it)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		p.flush(batch, failed)
+	}
+}
+
+// flush publishes the batch except the events submitted before
+// a failure of their aggregate was reported.
+func (p *Pipeline) flush(batch []item, failed map[string]uint64) {
+	publish := make([]event.Event, 0, len(batch))
+	epochs := make(map[string]uint64)
+	var skipped []event.Event
+
+	for _, it := range batch {
+		if epoch, found := failed[it.e.AggregateID]; found && it.epoch <= epoch {
+			skipped = append(skipped, it.e)
+			continue
+		}
+
+		delete(failed, it.e.AggregateID)
+		publish = append(publish, it.e)
+		epochs[it.e.AggregateID] = it.epoch
+	}
+
+	if len(skipped) > 0 {
+		p.done(skipped, ErrPredecessorFailed)
+	}
+	if len(publish) == 0 {
+		return
+	}
+
+	p.batches.Add(1)
+	if err := p.publish(publish); err != nil {
+		for id, epoch := range epochs {
+			failed[id] = epoch
+		}
+
+		p.failed.Add(int64(len(publish)))
+		p.done(publish, err)
+		return
+	}
+
+	p.published.Add(int64(len(publish)))
+	p.done(publish, nil)
+}
+
+// publish sends the events with one call of a BatchPublisher
+// or one by one, waiting for every acknowledgement.
+func (p *Pipeline) publish(events []event.Event) error {
+	if b, ok := p.publisher.(BatchPublisher); ok {
+		return b.Publish(events)
+	}
+
+	return publishEach(p.publisher, events)
+}
+
+// publishEach sends the events one by one in order.
+func publishEach(s Service, events []event.Event) error {
+	errCh := make(chan error, 1)
+	for _, e := range events {
+		switch e.Type {
+		case event.WalletCreated, event.WalletRenamed, event.WalletDeleted, event.WalletLimitsChanged,
+			event.WalletCreditChanged, event.WalletInterestChanged:
+			go s.Wallet(e, errCh)
+		default:
+			go s.Operation(e, errCh)
+		}
+
+		if err := <-errCh; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,7 +1,72 @@
 package queue
 
+import (
+	"fmt"
+
+	"wallet/app/event"
+)
+
+// Publisher backends.
+const (
+	BackendNSQ  = "nsq"
+	BackendBus  = "bus"
+	BackendFile = "file"
+	BackendNoop = "noop"
+)
+
 // Service has all methods for queue publisher.
 type Service interface {
-	Wallet(string, chan error)
-	Operation(string, float64, chan error)
+	Wallet(event.Event, chan error)
+	Operation(event.Event, chan error)
+}
+
+// Publisher is a Service which is stopped on shutdown.
+type Publisher interface {
+	Service
+	Stop()
+}
+
+// Options contains settings of the publisher backends.
+type Options struct {
+	// NSQAddr is the nsqd TCP address.
+	NSQAddr string
+	// File is the JSON Lines file of the file backend.
+	File string
+	// BusBuffer is the subscriber channel capacity of the bus backend.
+	BusBuffer int
+	Encoder   Encoder
+}
+
+// Open returns the publisher of a backend or
+// a FanOut publisher for several backends.
+func Open(backends []string, opts Options) (Publisher, error) {
+	publishers := make([]Publisher, 0, len(backends))
+	for _, backend := range backends {
+		p, err := open(backend, opts)
+		if err != nil {
+			NewFanOut(publishers...).Stop()
+			return nil, err
+		}
+		publishers = append(publishers, p)
+	}
+
+	if len(publishers) == 1 {
+		return publishers[0], nil
+	}
+
+	return NewFanOut(publishers...), nil
+}
+
+func open(backend string, opts Options) (Publisher, error) {
+	switch backend {
+	case BackendNSQ:
+		return NewNSQ(opts.NSQAddr, opts.Encoder)
+	case BackendBus:
+		return NewBus(opts.BusBuffer), nil
+	case BackendFile:
+		return NewFile(opts.File, opts.Encoder)
+	case BackendNoop:
+		return Noop{}, nil
+	}
+	return nil, fmt.Errorf("unknown queue backend %q", backend)
 }
@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// schemas are the JSON Schema files of the envelope and the payloads,
+// a payload schema is named after the event type and version.
+//
+//go:embed schema/*.json
+var schemas embed.FS
+
+// ErrSchema is returned for a message that doesn't match its schema.
+var ErrSchema = errors.New("message doesn't match schema")
+
+// schema is the subset of JSON Schema used by the schema files.
+type schema struct {
+	Type       string             `json:"type"`
+	Required   []string           `json:"required"`
+	Properties map[string]*schema `json:"properties"`
+	Enum       []any              `json:"enum"`
+	Minimum    *float64           `json:"minimum"`
+	MinLength  int                `json:"minLength"`
+	Pattern    string             `json:"pattern"`
+	Format     string             `json:"format"`
+}
+
+// loadSchema reads a schema file by name.
+func loadSchema(name string) (*schema, error) {
+	data, err := schemas.ReadFile("schema/" + name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: no schema %s", ErrSchema, name)
+	}
+
+	var s schema
+	if err = json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("schema %s decode error: %w", name, err)
+	}
+
+	return &s, nil
+}
+
+// Validate checks an encoded envelope and its payload against their schemas.
+func Validate(data []byte) error {
+	s, err := loadSchema("envelope.v1.json")
+	if err != nil {
+		return err
+	}
+
+	var env Envelope
+	if err = validate(s, data, &env, "envelope"); err != nil {
+		return err
+	}
+
+	return ValidatePayload(env.Type, env.SchemaVersion, env.Payload)
+}
+
+// ValidatePayload checks an event payload against the schema of its type and version.
+func ValidatePayload(typ string, version int, payload []byte) error {
+	name := fmt.Sprintf("%s.v%d.json", typ, version)
+	s, err := loadSchema(name)
+	if err != nil {
+		return err
+	}
+
+	var v map[string]any
+	return validate(s, payload, &v, name)
+}
+
+// validate decodes data into v and checks it against s.
+func validate(s *schema, data []byte, v any, name string) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrSchema, name, err.Error())
+	}
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrSchema, name, err.Error())
+	}
+
+	return s.check(doc, name)
+}
+
+// check validates a decoded JSON value, path names the value in errors.
+func (s *schema) check(v any, path string) error {
+	if err := s.checkType(v, path); err != nil {
+		return err
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if e == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%w: %s: %v is not allowed", ErrSchema, path, v)
+		}
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, found := v[name]; !found {
+				return fmt.Errorf("%w: %s: %s is required", ErrSchema, path, name)
+			}
+		}
+		for name, p := range s.Properties {
+			if value, found := v[name]; found {
+				if err := p.check(value, path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		if len(v) < s.MinLength {
+			return fmt.Errorf("%w: %s: shorter than %d", ErrSchema, path, s.MinLength)
+		}
+		if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(v) {
+			return fmt.Errorf("%w: %s: doesn't match %s", ErrSchema, path, s.Pattern)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				return fmt.Errorf("%w: %s: not an RFC 3339 time", ErrSchema, path)
+			}
+		}
+	case float64:
+		if s.Minimum != nil && v < *s.Minimum {
+			return fmt.Errorf("%w: %s: less than %v", ErrSchema, path, *s.Minimum)
+		}
+	}
+
+	return nil
+}
+
+func (s *schema) checkType(v any, path string) error {
+	ok := true
+	switch s.Type {
+	case "":
+	case "object":
+		_, ok = v.(map[string]any)
+	case "string":
+		_, ok = v.(string)
+	case "number":
+		_, ok = v.(float64)
+	case "integer":
+		f, isNumber := v.(float64)
+		ok = isNumber && f == float64(int64(f))
+	case "boolean":
+		_, ok = v.(bool)
+	}
+
+	if !ok {
+		return fmt.Errorf("%w: %s: not %s", ErrSchema, path, s.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package queue
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/fx"
+)
+
+// samples are payloads of every event type filled the way the
+// stores fill them, they are checked by TestCompatibility.
+var samples = map[string]any{
+	event.WalletCreated: event.Created{WalletID: "a1", Name: "main", Currency: "USD"},
+	event.WalletRenamed: event.Renamed{WalletID: "a1", Name: "savings"},
+	event.WalletDeleted: event.Deleted{WalletID: "a1"},
+	event.WalletDeposited: event.Operation{
+		WalletID: "a1", TransactionID: "1", Amount: 100000, Currency: "USD", BalanceAfter: 100000,
+	},
+	event.WalletWithdrawn: event.Operation{
+		WalletID: "a1", TransactionID: "2", Amount: 50000, Currency: "USD", BalanceAfter: 50000,
+	},
+	event.WalletTransferSent: event.Transfer{
+		WalletID: "a1", TransactionID: "3", Counterparty: "b2",
+		Amount: 10000, Currency: "USD", BalanceAfter: 40000, Rate: fx.One,
+	},
+	event.WalletTransferReceived: event.Transfer{
+		WalletID: "b2", TransactionID: "4", Counterparty: "a1",
+		Amount: 10000, Currency: "USD", BalanceAfter: 10000, Rate: fx.One,
+	},
+	event.WalletHoldPlaced: event.HoldPlaced{
+		WalletID: "a1", HoldID: "1", Amount: 20000, Currency: "USD", ExpiresAt: time.Now().UTC(),
+	},
+	event.WalletHoldCaptured: event.HoldCaptured{
+		WalletID: "a1", HoldID: "1", TransactionID: "5",
+		Amount: 15000, Released: 5000, Currency: "USD", BalanceAfter: 25000,
+	},
+	event.WalletHoldReleased: event.HoldReleased{
+		WalletID: "a1", HoldID: "2", Amount: 20000, Currency: "USD", Reason: "expired",
+	},
+	event.WalletLimitsChanged: event.LimitsChanged{
+		WalletID: "a1", Tier: "basic", MaxWithdrawal: 5000000, DailyOutgoing: 10000000,
+	},
+	event.TransactionReversed: event.Reversed{
+		WalletID: "b2", TransactionID: "6", Reverses: "4", Type: "reversal_out",
+		Counterparty: "a1", Amount: 10000, Currency: "USD", BalanceAfter: 0,
+	},
+	event.WalletFeeCharged: event.FeeCharged{
+		WalletID: "a1", TransactionID: "7", ChargedFor: "6", Amount: 500, Currency: "USD", BalanceAfter: 19500,
+		FeeWallet: "fees-usd",
+	},
+	event.WalletFeeCollected: event.FeeCollected{
+		WalletID: "fees-usd", TransactionID: "8", ChargedTo: "a1", ChargedFor: "6", Amount: 500, Currency: "USD",
+		BalanceAfter: 500,
+	},
+	event.WalletCreditChanged: event.CreditChanged{
+		WalletID: "a1", CreditLimit: 1000000, Currency: "USD",
+	},
+	event.WalletOverdraftStarted: event.Overdraft{
+		WalletID: "a1", Balance: -50000, CreditLimit: 1000000, Currency: "USD",
+	},
+	event.WalletOverdraftEnded: event.Overdraft{
+		WalletID: "a1", Balance: 10000, CreditLimit: 1000000, Currency: "USD",
+	},
+	event.WalletOverdraftCharged: event.OverdraftCharged{
+		WalletID: "a1", TransactionID: "8", Date: "2024-01-02", Overdraft: 500000,
+		Rate: 5000000, Amount: 2500, Currency: "USD", BalanceAfter: -502500, FeeWallet: "fees-usd",
+	},
+	event.WalletInterestChanged: event.InterestChanged{
+		WalletID: "a1", Rate: 1000000,
+	},
+	event.WalletInterestPaid: event.InterestPaid{
+		WalletID: "a1", TransactionID: "9", Date: "2024-01-02", Balance: 1000000,
+		Rate: 1000000, Amount: 100, Currency: "USD", BalanceAfter: 1000100,
+	},
+	event.EventDiscarded: event.Discarded{EventID: "7", Type: event.WalletDeposited},
+}
+
+// TestCompatibility encodes a sample of every event type and validates
+// it against the published schema of the current version, so a payload
+// change that breaks consumers fails before anything is published.
+func TestCompatibility(t *testing.T) {
+	for _, typ := range event.Types {
+		t.Run(typ, func(t *testing.T) {
+			sample, found := samples[typ]
+			if !found {
+				t.Fatalf("no sample of %s", typ)
+			}
+			if _, found = cloudEventTypes[typ]; !found {
+				t.Fatalf("no CloudEvents type of %s", typ)
+			}
+
+			payload, err := json.Marshal(sample)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			e := event.Event{
+				ID:          "1",
+				Type:        typ,
+				Version:     event.Version,
+				AggregateID: "a1",
+				Sequence:    1,
+				Time:        time.Now(),
+				Payload:     payload,
+			}
+			for _, format := range []string{FormatEnvelope, FormatCloudEvents} {
+				enc, err := NewEncoder(format, "/wallet")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err = enc.Encode(e); err != nil {
+					t.Errorf("%s encode error: %s", format, err.Error())
+				}
+			}
+		})
+	}
+}
@@ -1,17 +1,21 @@
 // Package response marshall response to JSON and send it
-// to the client with proper HTTP status.
+// to the client with proper HTTP status, large responses
+// are streamed as CSV, JSON Lines or plain text.
 package response
 
 import (
 	"encoding/json"
 	"net/http"
+
+	"wallet/app/money"
 )
 
 type message struct {
-	Success bool    `json:"success"`
-	ErrCode string  `json:"err_code,omitempty"`
-	ID      string  `json:"id,omitempty"`
-	Amount  float64 `json:"amount,omitempty"`
+	Success bool         `json:"success"`
+	ErrCode string       `json:"err_code,omitempty"`
+	ID      string       `json:"id,omitempty"`
+	Amount  money.Amount `json:"amount,omitempty"`
+	Fee     any          `json:"fee,omitempty"`
 }
 
 // WalletError status to the client.
@@ -36,7 +40,7 @@ func WalletSuccess(w http.ResponseWriter, status int, id string) {
 }
 
 // OperationError status to the client.
-func OperationError(w http.ResponseWriter, status int, err string, amount float64) {
+func OperationError(w http.ResponseWriter, status int, err string, amount money.Amount) {
 	msg := message{
 		ErrCode: err,
 		Amount:  amount,
@@ -47,10 +51,30 @@ func OperationError(w http.ResponseWriter, status int, err string, amount float6
 }
 
 // OperationSuccess status to the client.
-func OperationSuccess(w http.ResponseWriter, status int, amount float64) {
+func OperationSuccess(w http.ResponseWriter, status int, amount money.Amount) {
+	msg := message{
+		Success: true,
+		Amount:  amount,
+	}
+
+	sendJSON(w, status, msg)
+}
+
+// OperationFee status to the client with the fee charged for the operation.
+func OperationFee(w http.ResponseWriter, status int, amount money.Amount, fee any) {
 	msg := message{
 		Success: true,
 		Amount:  amount,
+		Fee:     fee,
+	}
+
+	sendJSON(w, status, msg)
+}
+
+// Error status to the client.
+func Error(w http.ResponseWriter, status int, err string) {
+	msg := message{
+		ErrCode: err,
 	}
 
 	sendJSON(w, status, msg)
@@ -0,0 +1,195 @@
+package response
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"time"
+)
+
+// Content types of streamed responses.
+const (
+	ContentTypeCSV       = "text/csv; charset=utf-8"
+	ContentTypeJSONLines = "application/x-ndjson"
+	ContentTypeText      = "text/plain; charset=utf-8"
+)
+
+// flushEvery is the number of records written before
+// the response is flushed to the client.
+const flushEvery = 100
+
+// partTimeout is the time to send the next flushed part of a stream,
+// the write deadline moves with every flush so a long stream is not
+// cut off by the server WriteTimeout while a stalled client still is.
+const partTimeout = 10 * time.Second
+
+// Stream writes a response in parts as they are ready, the status and
+// headers are sent with the first write. Filename makes the client
+// save the response as an attachment.
+type Stream struct {
+	w                     http.ResponseWriter
+	rc                    *http.ResponseController
+	status                int
+	contentType, filename string
+	started               bool
+	records               int
+}
+
+// NewStream is a Stream constructor.
+func NewStream(w http.ResponseWriter, status int, contentType, filename string) *Stream {
+	return &Stream{
+		w:           w,
+		rc:          http.NewResponseController(w),
+		status:      status,
+		contentType: contentType,
+		filename:    filename,
+	}
+}
+
+// Started reports whether the headers are sent, after that
+// an error can't be returned to the client as a response.
+func (s *Stream) Started() bool {
+	return s.started
+}
+
+// Write sends the headers on the first call and writes p.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.start()
+	return s.w.Write(p)
+}
+
+// Flush sends the written data to the client and gives
+// it partTimeout more to receive the next part.
+func (s *Stream) Flush() {
+	s.start()
+	s.rc.Flush()
+	s.extend()
+}
+
+// extend moves the write deadline, writers that can't
+// set it keep the server WriteTimeout.
+func (s *Stream) extend() {
+	s.rc.SetWriteDeadline(time.Now().Add(partTimeout))
+}
+
+// record counts a written record and flushes every flushEvery records.
+func (s *Stream) record() {
+	s.records++
+	if s.records%flushEvery == 0 {
+		s.Flush()
+	}
+}
+
+func (s *Stream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.extend()
+
+	s.w.Header().Set("Content-Type", s.contentType)
+	if s.filename != "" {
+		s.w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": s.filename}))
+	}
+	s.w.WriteHeader(s.status)
+}
+
+// CSVWriter streams CSV records.
+type CSVWriter struct {
+	*Stream
+	csv *csv.Writer
+}
+
+// CSV returns a CSVWriter of the response.
+func CSV(w http.ResponseWriter, status int, filename string) *CSVWriter {
+	s := NewStream(w, status, ContentTypeCSV, filename)
+	return &CSVWriter{
+		Stream: s,
+		csv:    csv.NewWriter(s),
+	}
+}
+
+// Write writes a CSV record.
+func (c *CSVWriter) Write(record []string) error {
+	if err := c.csv.Write(record); err != nil {
+		return err
+	}
+
+	c.records++
+	if c.records%flushEvery == 0 {
+		return c.Flush()
+	}
+	return nil
+}
+
+// Flush sends the written records to the client.
+func (c *CSVWriter) Flush() error {
+	c.csv.Flush()
+	if err := c.csv.Error(); err != nil {
+		return err
+	}
+
+	c.Stream.Flush()
+	return nil
+}
+
+// JSONLinesWriter streams values as JSON Lines.
+type JSONLinesWriter struct {
+	*Stream
+	enc *json.Encoder
+}
+
+// JSONLines returns a JSONLinesWriter of the response.
+func JSONLines(w http.ResponseWriter, status int, filename string) *JSONLinesWriter {
+	s := NewStream(w, status, ContentTypeJSONLines, filename)
+	return &JSONLinesWriter{
+		Stream: s,
+		enc:    json.NewEncoder(s),
+	}
+}
+
+// Write writes a value as one line.
+func (j *JSONLinesWriter) Write(v any) error {
+	if err := j.enc.Encode(v); err != nil {
+		return err
+	}
+
+	j.record()
+	return nil
+}
+
+// Flush sends the written lines to the client.
+func (j *JSONLinesWriter) Flush() error {
+	j.Stream.Flush()
+	return nil
+}
+
+// TextWriter streams plain text lines.
+type TextWriter struct {
+	*Stream
+}
+
+// Text returns a TextWriter of the response.
+func Text(w http.ResponseWriter, status int, filename string) *TextWriter {
+	return &TextWriter{
+		Stream: NewStream(w, status, ContentTypeText, filename),
+	}
+}
+
+// Printf formats a line, the line break is added.
+func (t *TextWriter) Printf(format string, args ...any) error {
+	if _, err := fmt.Fprintf(t.Stream, format+"\n", args...); err != nil {
+		return err
+	}
+
+	t.record()
+	return nil
+}
+
+// Flush sends the written lines to the client.
+func (t *TextWriter) Flush() error {
+	t.Stream.Flush()
+	return nil
+}
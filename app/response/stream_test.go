@@ -0,0 +1,44 @@
+package response_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"wallet/app/response"
+)
+
+func TestStreamOutlivesWriteTimeout(t *testing.T) {
+	const parts = 5
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := response.Text(w, http.StatusOK, "")
+		for i := 0; i < parts; i++ {
+			if err := s.Printf("part %d", i); err != nil {
+				return
+			}
+			s.Flush()
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("read error after %q: %s", body, err.Error())
+	}
+	if n := strings.Count(string(body), "\n"); n != parts {
+		t.Fatalf("got %d parts, want %d: %q", n, parts, body)
+	}
+}
@@ -0,0 +1,113 @@
+// Package scheduler runs jobs on cron schedules inside the service.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Clock tells the time and waits for it, tests replace
+// the System clock with one they move by hand.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// System is the wall clock.
+var System Clock = systemClock{}
+
+// Job is a task run at the scheduled time at. A job may run again for
+// the same period after a restart, so it must be idempotent.
+type Job func(ctx context.Context, at time.Time) error
+
+// Scheduler runs jobs one at a time when their specs match,
+// a run missed while the service was down is skipped.
+type Scheduler struct {
+	clock Clock
+	jobs  []*job
+}
+
+type job struct {
+	name string
+	spec Spec
+	run  Job
+	next time.Time
+}
+
+// New is a constructor for Scheduler.
+func New(clock Clock) *Scheduler {
+	return &Scheduler{
+		clock: clock,
+	}
+}
+
+// Add registers a job under the name with a spec, see Parse.
+func (s *Scheduler) Add(name, spec string, run Job) error {
+	sp, err := Parse(spec)
+	if err != nil {
+		return fmt.Errorf("job %s error: %w", name, err)
+	}
+	if sp.Next(s.clock.Now()).IsZero() {
+		return fmt.Errorf("job %s error: schedule %q never matches", name, spec)
+	}
+
+	s.jobs = append(s.jobs, &job{
+		name: name,
+		spec: sp,
+		run:  run,
+	})
+
+	return nil
+}
+
+// Run runs the jobs until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.jobs) == 0 {
+		return
+	}
+
+	now := s.clock.Now()
+	for _, j := range s.jobs {
+		j.next = j.spec.Next(now)
+	}
+
+	for {
+		first := s.jobs[0].next
+		for _, j := range s.jobs[1:] {
+			if j.next.Before(first) {
+				first = j.next
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.clock.After(first.Sub(now)):
+		}
+
+		now = s.clock.Now()
+		for _, j := range s.jobs {
+			if j.next.After(now) {
+				continue
+			}
+
+			if err := j.run(ctx, j.next); err != nil {
+				log.Printf("job %s error: %s", j.name, err.Error())
+			}
+			if ctx.Err() != nil {
+				return
+			}
+
+			// a long job may take the place of the next runs.
+			now = s.clock.Now()
+			j.next = j.spec.Next(now)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package scheduler_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"wallet/app/scheduler"
+)
+
+// fakeClock moves to the end of every wait at once and
+// cancels the run when a wait would pass the end time.
+type fakeClock struct {
+	now, end time.Time
+	cancel   context.CancelFunc
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	if c.now.Add(d).After(c.end) {
+		c.cancel()
+		return nil
+	}
+
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// run runs the scheduler from start until end.
+func run(t *testing.T, start, end time.Time, add func(*scheduler.Scheduler) error) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := scheduler.New(&fakeClock{now: start, end: end, cancel: cancel})
+	if err := add(s); err != nil {
+		t.Fatal(err)
+	}
+	s.Run(ctx)
+}
+
+func TestRun(t *testing.T) {
+	start := time.Date(2030, 1, 1, 10, 30, 0, 0, time.UTC)
+
+	var daily, every []time.Time
+	run(t, start, start.Add(48*time.Hour), func(s *scheduler.Scheduler) error {
+		err := s.Add("daily", "@daily", func(ctx context.Context, at time.Time) error {
+			daily = append(daily, at)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// a failing job is run again at its next time.
+		return s.Add("every", "@every 6h", func(ctx context.Context, at time.Time) error {
+			every = append(every, at)
+			return errors.New("failed")
+		})
+	})
+
+	wantDaily := []time.Time{
+		time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2030, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+	if len(daily) != len(wantDaily) || !daily[0].Equal(wantDaily[0]) || !daily[1].Equal(wantDaily[1]) {
+		t.Errorf("daily runs = %v, want %v", daily, wantDaily)
+	}
+	if len(every) != 8 || !every[0].Equal(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("6h runs = %v, want 8 from 12:00", every)
+	}
+}
+
+func TestAddRejectsSpecs(t *testing.T) {
+	s := scheduler.New(scheduler.System)
+	job := func(context.Context, time.Time) error { return nil }
+
+	for _, spec := range []string{"* * *", "0 0 30 2 *"} {
+		if err := s.Add("job", spec, job); err == nil {
+			t.Errorf("Add(%q) succeeded", spec)
+		}
+	}
+}
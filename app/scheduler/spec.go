@@ -0,0 +1,179 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// macros are the named specs.
+var macros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+}
+
+// Spec is a cron schedule in UTC: minute, hour, day of month, month and
+// day of week, each a set of the values it matches. A spec with every
+// set matches the multiples of the interval instead.
+type Spec struct {
+	minute, hour, day, month, weekday uint64
+	// anyDay and anyWeekday are set for the * fields, a day matches
+	// both day fields if one is * and either of them otherwise.
+	anyDay, anyWeekday bool
+	every              time.Duration
+}
+
+// field is the range of a spec field.
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = [5]field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// Parse reads a spec of five fields like "30 2 * * 1-5", a field is *, a
+// value, a range a-b or a list of them, a * or a range may have a step
+// like */15. The macros @hourly, @daily, @midnight, @weekly and @monthly
+// are accepted too, and @every with a duration like "@every 10s".
+func Parse(s string) (Spec, error) {
+	if m, found := macros[s]; found {
+		s = m
+	}
+	if d, found := strings.CutPrefix(s, "@every "); found {
+		every, err := time.ParseDuration(strings.TrimSpace(d))
+		if err != nil || every <= 0 {
+			return Spec{}, fmt.Errorf("schedule %q error: interval %q", s, d)
+		}
+		return Spec{every: every}, nil
+	}
+
+	parts := strings.Fields(s)
+	if len(parts) != len(fields) {
+		return Spec{}, fmt.Errorf("schedule %q error: %d fields, want %d", s, len(parts), len(fields))
+	}
+
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := f.parse(parts[i])
+		if err != nil {
+			return Spec{}, fmt.Errorf("schedule %q error: %w", s, err)
+		}
+		sets[i] = set
+	}
+
+	return Spec{
+		minute:     sets[0],
+		hour:       sets[1],
+		day:        sets[2],
+		month:      sets[3],
+		weekday:    sets[4],
+		anyDay:     parts[2] == "*",
+		anyWeekday: parts[4] == "*",
+	}, nil
+}
+
+// parse returns the set of values of a comma separated field.
+func (f field) parse(s string) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s step %q", f.name, stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			if !isRange && hasStep {
+				return 0, fmt.Errorf("%s step of a value %q", f.name, item)
+			}
+
+			var err error
+			if lo, err = f.value(from); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(to); err != nil {
+					return 0, err
+				}
+				if hi < lo {
+					return 0, fmt.Errorf("%s range %q", f.name, rng)
+				}
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+
+	return set, nil
+}
+
+func (f field) value(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("%s %q is not between %d and %d", f.name, s, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Next returns the first minute after t the spec matches, the zero
+// time if it matches none within five years like "0 0 30 2 *". An
+// interval spec returns the first multiple of the interval after t.
+func (s Spec) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.UTC().Truncate(s.every).Add(s.every)
+	}
+
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	// a field which doesn't match skips to the start of the next
+	// period of the field, so a year is checked in few steps.
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !has(s.hour, t.Hour()):
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s Spec) matchDay(t time.Time) bool {
+	day, weekday := has(s.day, t.Day()), has(s.weekday, int(t.Weekday()))
+	if s.anyDay || s.anyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
@@ -0,0 +1,90 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec  string
+		valid bool
+	}{
+		{spec: "* * * * *", valid: true},
+		{spec: "30 2 * * 1-5", valid: true},
+		{spec: "*/15 0-6/2 1,15 * *", valid: true},
+		{spec: "@daily", valid: true},
+		{spec: "@every 10s", valid: true},
+		{spec: "@every 1h30m", valid: true},
+		{spec: "* * * *"},
+		{spec: "60 * * * *"},
+		{spec: "* 24 * * *"},
+		{spec: "* * 0 * *"},
+		{spec: "* * * 13 *"},
+		{spec: "* * * * 7"},
+		{spec: "*/0 * * * *"},
+		{spec: "5/2 * * * *"},
+		{spec: "10-5 * * * *"},
+		{spec: "a * * * *"},
+		{spec: "@yearly"},
+		{spec: "@every 0s"},
+		{spec: "@every -1m"},
+		{spec: "@every soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := Parse(tt.spec)
+			if (err == nil) != tt.valid {
+				t.Fatalf("Parse(%q) error = %v, want valid %t", tt.spec, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tests := []struct {
+		spec, from, want string
+	}{
+		{spec: "* * * * *", from: "2030-01-01T10:30:00Z", want: "2030-01-01T10:31:00Z"},
+		{spec: "* * * * *", from: "2030-01-01T10:30:59Z", want: "2030-01-01T10:31:00Z"},
+		{spec: "@hourly", from: "2030-01-01T10:30:00Z", want: "2030-01-01T11:00:00Z"},
+		{spec: "@daily", from: "2030-01-01T00:00:00Z", want: "2030-01-02T00:00:00Z"},
+		{spec: "@daily", from: "2030-12-31T23:59:00Z", want: "2031-01-01T00:00:00Z"},
+		{spec: "@monthly", from: "2030-01-31T12:00:00Z", want: "2030-02-01T00:00:00Z"},
+		{spec: "@weekly", from: "2030-01-01T00:00:00Z", want: "2030-01-06T00:00:00Z"},
+		{spec: "30 2 * * 1-5", from: "2030-01-04T03:00:00Z", want: "2030-01-07T02:30:00Z"},
+		{spec: "0 0 29 2 *", from: "2030-03-01T00:00:00Z", want: "2032-02-29T00:00:00Z"},
+		// either day field matches when both are set.
+		{spec: "0 0 15 * 1", from: "2030-01-01T00:00:00Z", want: "2030-01-07T00:00:00Z"},
+		{spec: "0 12 * * *", from: "2030-01-01T14:00:00+02:00", want: "2030-01-02T12:00:00Z"},
+		{spec: "@every 10s", from: "2030-01-01T10:30:05Z", want: "2030-01-01T10:30:10Z"},
+		{spec: "@every 10s", from: "2030-01-01T10:30:10Z", want: "2030-01-01T10:30:20Z"},
+		{spec: "0 0 30 2 *", from: "2030-01-01T00:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec+" from "+tt.from, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want time.Time
+			if tt.want != "" {
+				want = at(tt.want)
+			}
+			if got := s.Next(at(tt.from)); !got.Equal(want) {
+				t.Fatalf("Next = %s, want %s", got, want)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,12 +11,33 @@ import (
 	"os/signal"
 	"time"
 
+	"wallet/app/audit"
+	"wallet/app/config"
+	"wallet/app/correlation"
+	eventStorage "wallet/app/event/store"
+	"wallet/app/fee"
+	"wallet/app/fx"
+	"wallet/app/idempotency"
+	"wallet/app/ledger"
+	ledgerStorage "wallet/app/ledger/store"
+	"wallet/app/money"
 	"wallet/app/operation"
-	operStorage "wallet/app/operation/memory"
+	operStorage "wallet/app/operation/store"
+	"wallet/app/outbox"
+	outboxStorage "wallet/app/outbox/store"
+	"wallet/app/projection"
+	projStorage "wallet/app/projection/store"
 	"wallet/app/queue"
+	"wallet/app/scheduler"
+	"wallet/app/statement"
+	statementStorage "wallet/app/statement/store"
 	"wallet/app/storage"
+	"wallet/app/storage/bolt"
+	"wallet/app/storage/wal"
+	"wallet/app/transaction"
+	transStorage "wallet/app/transaction/store"
 	"wallet/app/wallet"
-	walletStorage "wallet/app/wallet/memory"
+	walletStorage "wallet/app/wallet/store"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -24,16 +46,26 @@ import (
 
 // Server contains http.Server.
 type Server struct {
+	Config config.Config
 	Router *chi.Mux
-	Queue  *queue.NSQ
+	Queue  queue.Service
 	HTTP   *http.Server
+	DB     storage.DB
+	Relay  *outbox.Relay
+	// Scheduler runs the hold expiry, overdraft and interest jobs.
+	Scheduler *scheduler.Scheduler
+
+	// Consumer feeds Projector, both are nil if the projections are disabled.
+	Consumer  queue.Consumer
+	Projector *projection.Projector
 }
 
 // New is a constructor which initializes new Server.
-func New(queue *queue.NSQ) *Server {
+func New(cfg config.Config, queue queue.Service) *Server {
 	r := chi.NewRouter()
 
 	return &Server{
+		Config: cfg,
 		Router: r,
 		Queue:  queue,
 		HTTP: &http.Server{
@@ -50,21 +82,186 @@ func New(queue *queue.NSQ) *Server {
 func (s *Server) SetupMiddleware() {
 	s.Router.Use(middleware.Logger)
 	s.Router.Use(middleware.Recoverer)
+	s.Router.Use(correlation.Middleware)
 }
 
 // SetupApp registers app services.
-func (s *Server) SetupApp() {
-	storage := storage.NewMemory()
-	walletStore := walletStorage.NewStorage(storage.Data)
+func (s *Server) SetupApp() error {
+	provider, err := s.rateProvider()
+	if err != nil {
+		return err
+	}
+
+	quoteService := fx.NewQuoteService(provider, s.Config.FXQuoteTTL)
+	fxHandler := fx.NewHandler(s.Router, quoteService)
+	fxHandler.Register()
+
+	schedule, err := fee.LoadSchedule(s.Config.FeesFile)
+	if err != nil {
+		return err
+	}
+
+	feeHandler := fee.NewHandler(s.Router, schedule)
+	feeHandler.Register()
+
+	s.DB, err = OpenDB(s.Config)
+	if err != nil {
+		return err
+	}
+
+	walletStore := walletStorage.NewStorage(s.DB)
 
-	walletService := wallet.NewAppService(walletStore, s.Queue)
+	walletService := wallet.NewAppService(walletStore)
+	// fees and overdraft interest are paid into the house wallets, the
+	// server doesn't start without the wallet of a currency it charges.
+	currencies := schedule.Currencies()
+	if s.Config.OverdraftRate != 0 {
+		currencies = money.Currencies()
+	}
+	if err = walletService.OpenFeeWallets(context.Background(), currencies); err != nil {
+		return err
+	}
 	walletHandler := wallet.NewHandler(s.Router, *walletService)
 	walletHandler.Register()
 
-	operStore := operStorage.NewStorage(storage.Data)
-	operationService := operation.NewWalletService(operStore, s.Queue)
-	operationHandler := operation.NewHandler(s.Router, *operationService)
+	tiers, err := operation.LoadTiers(s.Config.LimitsFile)
+	if err != nil {
+		return err
+	}
+	interest, err := operation.LoadInterestRates(s.Config.InterestFile)
+	if err != nil {
+		return err
+	}
+
+	operStore := operStorage.NewStorage(s.DB)
+	operationService := operation.NewWalletService(operStore, quoteService, schedule, operation.HoldOptions{
+		TTL:    s.Config.HoldTTL,
+		MaxTTL: s.Config.HoldMaxTTL,
+	}, tiers, s.Config.OverdraftRate, interest)
+	idempotencyStore := idempotency.NewStore(s.Config.IdempotencyTTL)
+	s.Scheduler = scheduler.New(scheduler.System)
+	expiry := "@every " + s.Config.HoldExpiryInterval.String()
+	if err = s.Scheduler.Add("hold expiry", expiry, operationService.ExpiryJob); err != nil {
+		return err
+	}
+	if err = s.Scheduler.Add("overdraft", s.Config.OverdraftSchedule, operationService.OverdraftJob); err != nil {
+		return err
+	}
+	if err = s.Scheduler.Add("interest", s.Config.InterestSchedule, operationService.InterestJob); err != nil {
+		return err
+	}
+	operationHandler := operation.NewHandler(s.Router, *operationService, idempotencyStore.Middleware)
 	operationHandler.Register()
+
+	transStore := transStorage.NewStorage(s.DB)
+	historyService := transaction.NewHistoryService(transStore)
+	transHandler := transaction.NewHandler(s.Router, *historyService)
+	transHandler.Register()
+
+	statementStore := statementStorage.NewStorage(s.DB)
+	statementService := statement.NewStatementService(statementStore)
+	statementHandler := statement.NewHandler(s.Router, *statementService)
+	statementHandler.Register()
+
+	ledgerStore := ledgerStorage.NewStorage(s.DB)
+	journalService := ledger.NewJournalService(ledgerStore)
+	ledgerHandler := ledger.NewHandler(s.Router, *journalService)
+	ledgerHandler.Register()
+
+	outboxStore := outboxStorage.NewStorage(s.DB)
+	s.Relay = outbox.NewRelay(outboxStore, s.Queue, outbox.Options{
+		PollInterval: s.Config.OutboxPollInterval,
+		BatchSize:    s.Config.OutboxBatch,
+		Pipeline: queue.PipelineOptions{
+			Workers:       s.Config.PublishWorkers,
+			Buffer:        s.Config.PublishBuffer,
+			BatchSize:     s.Config.PublishBatch,
+			FlushInterval: s.Config.PublishFlushInterval,
+		},
+		MinBackoff:  s.Config.OutboxMinBackoff,
+		MaxBackoff:  s.Config.OutboxMaxBackoff,
+		Jitter:      s.Config.OutboxJitter,
+		MaxAttempts: s.Config.OutboxMaxAttempts,
+		DeadLetters: s.Config.DeadLetters,
+	})
+
+	deadLetterService := outbox.NewDeadLetterService(outboxStore)
+	outboxHandler := outbox.NewHandler(s.Router, *deadLetterService, s.Relay)
+	outboxHandler.Register()
+
+	eventStore := eventStorage.NewStorage(s.DB)
+	trailService := audit.NewTrailService(eventStore)
+	auditHandler := audit.NewHandler(s.Router, *trailService)
+	auditHandler.Register()
+
+	if s.Config.Consumer != "" {
+		if err = s.setupProjections(eventStore); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setupProjections rebuilds the read models from the recorded
+// events and subscribes the projector to the configured consumer.
+func (s *Server) setupProjections(events projection.EventLog) error {
+	projStore := projStorage.NewStorage()
+	s.Projector = projection.NewProjector(projStore)
+
+	if err := s.Projector.Rebuild(context.Background(), events); err != nil {
+		return err
+	}
+
+	readService := projection.NewReadService(projStore)
+	projHandler := projection.NewHandler(s.Router, *readService)
+	projHandler.Register()
+
+	if s.Config.Consumer == "nsq" {
+		s.Consumer = queue.NewNSQConsumer(s.Config.NSQAddr, s.Config.NSQChannel)
+		return nil
+	}
+
+	source, ok := s.Queue.(queue.Source)
+	if !ok {
+		return fmt.Errorf("queue %v can't be consumed in process", s.Config.Queue)
+	}
+
+	var err error
+	s.Consumer, err = source.Consumer()
+	return err
+}
+
+// OpenDB opens the configured storage backend.
+func OpenDB(cfg config.Config) (storage.DB, error) {
+	if cfg.Storage == config.StorageBolt {
+		log.Printf("opening bolt storage at %s", cfg.BoltPath)
+		return bolt.Open(cfg.BoltPath)
+	}
+
+	if cfg.MemoryDir != "" {
+		log.Printf("opening durable memory storage at %s", cfg.MemoryDir)
+		return storage.OpenMemory(storage.DurableOptions{
+			Dir: cfg.MemoryDir,
+			WAL: wal.Options{
+				Sync:      cfg.WALSync,
+				BatchSize: cfg.WALBatch,
+				Interval:  cfg.WALSyncInterval,
+			},
+			SnapshotInterval: cfg.SnapshotInterval,
+		})
+	}
+
+	return storage.NewMemory(), nil
+}
+
+// rateProvider returns the file-backed fx provider if it is configured
+// and the static one otherwise.
+func (s *Server) rateProvider() (fx.Provider, error) {
+	if s.Config.FXRatesFile != "" {
+		return fx.NewFile(s.Config.FXRatesFile)
+	}
+	return fx.NewStatic(nil)
 }
 
 // Start runs HTTP server.
@@ -83,6 +280,22 @@ func (s *Server) Start() error {
 		return nil
 	})
 
+	errs.Go(func() error {
+		s.Relay.Run(ctx)
+		return nil
+	})
+
+	errs.Go(func() error {
+		s.Scheduler.Run(ctx)
+		return nil
+	})
+
+	if s.Consumer != nil {
+		errs.Go(func() error {
+			return s.Consumer.Consume(ctx, s.Projector.Handle)
+		})
+	}
+
 	<-ctx.Done()
 
 	// Restore default behavior on the interrupt signal and notify user of shutdown.
@@ -97,5 +310,19 @@ func (s *Server) Start() error {
 		log.Println(err.Error())
 	}
 
+	// wait for the relay to finish the current pass and publish the
+	// submitted events before closing the storage.
+	if err := errs.Wait(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Println(err.Error())
+	}
+
+	if err := s.Relay.Drain(timeoutCtx); err != nil {
+		log.Printf("outbox drain error: %s", err.Error())
+	}
+
+	if err := s.DB.Close(); err != nil {
+		log.Println(err.Error())
+	}
+
 	return nil
 }
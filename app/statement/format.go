@@ -0,0 +1,160 @@
+package statement
+
+import (
+	"fmt"
+	"time"
+
+	"wallet/app/fx"
+	"wallet/app/money"
+	"wallet/app/response"
+)
+
+// formatTime formats a statement time, zero is an open period start.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// csvWriter writes the balances as records of their own
+// kind with the same columns as the transactions.
+type csvWriter struct {
+	w *response.CSVWriter
+}
+
+func (c *csvWriter) Opening(b Balance) error {
+	err := c.w.Write([]string{"record", "time", "id", "type", "counterparty", "amount", "rate", "balance", "currency"})
+	if err != nil {
+		return err
+	}
+	return c.balance("opening_balance", b)
+}
+
+func (c *csvWriter) Line(l Line) error {
+	rate := ""
+	if l.Rate != 0 && l.Rate != fx.One {
+		rate = l.Rate.String()
+	}
+
+	return c.w.Write([]string{"transaction", formatTime(l.Time), l.ID, l.Type, l.Counterparty, l.Amount.String(), rate, l.Balance.String(), ""})
+}
+
+func (c *csvWriter) Closing(b Balance) error {
+	if err := c.balance("closing_balance", b); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func (c *csvWriter) balance(record string, b Balance) error {
+	return c.w.Write([]string{record, formatTime(b.Time), "", "", "", "", "", b.Amount.String(), string(b.Currency)})
+}
+
+// jsonBalance and jsonLine are the JSON Lines records,
+// Record tells them apart.
+type jsonBalance struct {
+	Record   string         `json:"record"`
+	WalletID string         `json:"wallet_id"`
+	Currency money.Currency `json:"currency"`
+	Time     string         `json:"time,omitempty"`
+	Balance  money.Amount   `json:"balance"`
+}
+
+type jsonLine struct {
+	Record       string       `json:"record"`
+	ID           string       `json:"id"`
+	Type         string       `json:"type"`
+	Counterparty string       `json:"counterparty,omitempty"`
+	Amount       money.Amount `json:"amount"`
+	Rate         fx.Rate      `json:"rate,omitempty"`
+	Balance      money.Amount `json:"balance"`
+	Time         time.Time    `json:"time"`
+}
+
+type jsonLinesWriter struct {
+	w *response.JSONLinesWriter
+}
+
+func (j *jsonLinesWriter) Opening(b Balance) error {
+	return j.balance("opening_balance", b)
+}
+
+func (j *jsonLinesWriter) Line(l Line) error {
+	return j.w.Write(jsonLine{
+		Record:       "transaction",
+		ID:           l.ID,
+		Type:         l.Type,
+		Counterparty: l.Counterparty,
+		Amount:       l.Amount,
+		Rate:         l.Rate,
+		Balance:      l.Balance,
+		Time:         l.Time,
+	})
+}
+
+func (j *jsonLinesWriter) Closing(b Balance) error {
+	if err := j.balance("closing_balance", b); err != nil {
+		return err
+	}
+	return j.w.Flush()
+}
+
+func (j *jsonLinesWriter) balance(record string, b Balance) error {
+	return j.w.Write(jsonBalance{
+		Record:   record,
+		WalletID: b.WalletID,
+		Currency: b.Currency,
+		Time:     formatTime(b.Time),
+		Balance:  b.Amount,
+	})
+}
+
+// textWriter writes a statement for people to read,
+// transactions are aligned in columns.
+type textWriter struct {
+	w *response.TextWriter
+}
+
+const textColumns = "%-20s  %-10s  %-12s  %-10s  %16s  %16s"
+
+func (t *textWriter) Opening(b Balance) error {
+	from := formatTime(b.Time)
+	if from == "" {
+		from = "the first transaction"
+	}
+
+	return t.lines(
+		fmt.Sprintf("Statement of wallet %s (%s)", b.WalletID, b.Currency),
+		"From "+from,
+		"",
+		fmt.Sprintf("Opening balance: %s %s", b.Amount, b.Currency),
+		"",
+		fmt.Sprintf(textColumns, "TIME", "ID", "TYPE", "PARTY", "AMOUNT", "BALANCE"),
+	)
+}
+
+func (t *textWriter) Line(l Line) error {
+	return t.w.Printf(textColumns, formatTime(l.Time), l.ID, l.Type, l.Counterparty, l.Amount, l.Balance)
+}
+
+func (t *textWriter) Closing(b Balance) error {
+	err := t.lines(
+		"",
+		fmt.Sprintf("Closing balance: %s %s", b.Amount, b.Currency),
+		"To "+formatTime(b.Time),
+	)
+	if err != nil {
+		return err
+	}
+	return t.w.Flush()
+}
+
+func (t *textWriter) lines(lines ...string) error {
+	for _, l := range lines {
+		if err := t.w.Printf("%s", l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package statement
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"wallet/app/oops"
+	"wallet/app/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// dateLayout is the layout of from and to dates without time.
+const dateLayout = "2006-01-02"
+
+// Handler contains StatementService and a router.
+type Handler struct {
+	router    *chi.Mux
+	statement StatementService
+}
+
+// NewHandler is a constructor which accepts StatementService and
+// returns a pointer to the Handler.
+func NewHandler(router *chi.Mux, service StatementService) *Handler {
+	return &Handler{
+		router:    router,
+		statement: service,
+	}
+}
+
+// Register statement routes.
+func (h *Handler) Register() {
+	h.router.Group(func(r chi.Router) {
+		r.Get("/wallets/{id}/statement", h.item)
+	})
+}
+
+// item reads from, to and format query parameters, the format
+// is csv by default. Once the statement is being streamed an error
+// can only be logged, the client gets it without the closing balance.
+func (h *Handler) item(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, "")
+		return
+	}
+
+	p, err := parsePeriod(r)
+	if err != nil {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadFilterMessage, id)
+		return
+	}
+
+	var (
+		sw     Writer
+		stream *response.Stream
+	)
+	filename := "statement-" + id
+	switch r.URL.Query().Get("format") {
+	case "", FormatCSV:
+		cw := response.CSV(w, http.StatusOK, filename+".csv")
+		sw, stream = &csvWriter{w: cw}, cw.Stream
+	case FormatJSONLines:
+		jw := response.JSONLines(w, http.StatusOK, filename+".jsonl")
+		sw, stream = &jsonLinesWriter{w: jw}, jw.Stream
+	case FormatText:
+		tw := response.Text(w, http.StatusOK, filename+".txt")
+		sw, stream = &textWriter{w: tw}, tw.Stream
+	default:
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadFilterMessage, id)
+		return
+	}
+
+	err = h.statement.Write(r.Context(), id, p, sw)
+	if err == nil {
+		return
+	}
+	if stream.Started() {
+		log.Printf("statement %s error: %s", id, err.Error())
+		return
+	}
+
+	switch {
+	case errors.Is(err, oops.ErrNotFound):
+		response.WalletError(w, http.StatusNotFound, oops.ErrNotFoundMessage, id)
+	case errors.Is(err, oops.ErrBadFilter):
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadFilterMessage, id)
+	default:
+		response.WalletError(w, http.StatusInternalServerError, oops.ErrIntServMessage, id)
+	}
+}
+
+// parsePeriod reads from and to in RFC 3339 format or as
+// YYYY-MM-DD dates, a to date includes the whole day.
+func parsePeriod(r *http.Request) (Period, error) {
+	q := r.URL.Query()
+
+	var (
+		p   Period
+		err error
+	)
+	if v := q.Get("from"); v != "" {
+		if p.From, err = parseTime(v, false); err != nil {
+			return Period{}, err
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if p.To, err = parseTime(v, true); err != nil {
+			return Period{}, err
+		}
+	}
+
+	return p, nil
+}
+
+func parseTime(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, v); err == nil {
+		if endOfDay {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
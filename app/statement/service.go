@@ -0,0 +1,113 @@
+package statement
+
+import (
+	"context"
+	"time"
+
+	"wallet/app/oops"
+)
+
+// batchSize is the number of transactions read at once, so a statement
+// never holds more of them in memory or keeps the storage busy.
+const batchSize = 500
+
+// StatementService contains Store interface.
+type StatementService struct {
+	store Store
+}
+
+// NewStatementService is a StatementService constructor.
+func NewStatementService(store Store) *StatementService {
+	return &StatementService{
+		store: store,
+	}
+}
+
+// Write renders the wallet statement for the period, nothing is written
+// if the wallet is unknown or the period is invalid.
+func (s *StatementService) Write(ctx context.Context, id string, p Period, w Writer) error {
+	if p.To.IsZero() {
+		p.To = time.Now().UTC()
+	}
+	if p.To.Before(p.From) {
+		return oops.ErrBadFilter
+	}
+
+	currency, err := s.store.Currency(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	balance := Balance{
+		WalletID: id,
+		Currency: currency,
+		Time:     p.From,
+	}
+
+	// the opening balance is the balance after the last transaction
+	// before the period, it is written with the first line and the
+	// period is read from the transaction after it.
+	last, found, err := s.store.LastBefore(ctx, id, p.From)
+	if err != nil {
+		return err
+	}
+
+	opened := false
+	after := ""
+	if found {
+		balance.Amount = last.BalanceAfter
+		after = last.ID
+	}
+
+	for {
+		batch, err := s.store.Transactions(ctx, id, after, batchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, t := range batch {
+			if t.CreatedAt.After(p.To) {
+				return s.close(w, balance, opened, p.To)
+			}
+
+			if !opened {
+				if err = w.Opening(balance); err != nil {
+					return err
+				}
+				opened = true
+			}
+
+			err = w.Line(Line{
+				ID:           t.ID,
+				Type:         t.Type,
+				Counterparty: t.Counterparty,
+				Amount:       t.BalanceAfter - balance.Amount,
+				Rate:         t.Rate,
+				Balance:      t.BalanceAfter,
+				Time:         t.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			balance.Amount = t.BalanceAfter
+		}
+
+		if len(batch) < batchSize {
+			return s.close(w, balance, opened, p.To)
+		}
+		after = batch[len(batch)-1].ID
+	}
+}
+
+// close writes the closing balance, and the opening one
+// if the period has no transactions.
+func (s *StatementService) close(w Writer, balance Balance, opened bool, to time.Time) error {
+	if !opened {
+		if err := w.Opening(balance); err != nil {
+			return err
+		}
+	}
+
+	balance.Time = to
+	return w.Closing(balance)
+}
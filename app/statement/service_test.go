@@ -0,0 +1,127 @@
+package statement_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wallet/app/money"
+	"wallet/app/statement"
+	"wallet/app/statement/store"
+	"wallet/app/storage"
+	"wallet/app/storage/bolt"
+)
+
+// recorder keeps what a statement writes.
+type recorder struct {
+	opening, closing statement.Balance
+	lines            []string
+}
+
+func (r *recorder) Opening(b statement.Balance) error {
+	r.opening = b
+	return nil
+}
+
+func (r *recorder) Line(l statement.Line) error {
+	r.lines = append(r.lines, l.ID)
+	return nil
+}
+
+func (r *recorder) Closing(b statement.Balance) error {
+	r.closing = b
+	return nil
+}
+
+func TestWrite(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2026, 3, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	// wallet a gets 100 a day from the 1st to the 6th,
+	// wallet b gets a transaction between each of them.
+	seed := func(t *testing.T, db storage.DB) {
+		t.Helper()
+		err := db.Update(func(tx storage.Tx) error {
+			for _, id := range []string{"a", "b"} {
+				if err := tx.PutWallet(id, storage.Wallet{Currency: "USD"}); err != nil {
+					return err
+				}
+			}
+			for d := 1; d <= 6; d++ {
+				for _, id := range []string{"a", "b"} {
+					_, err := tx.AppendTransaction(storage.Transaction{
+						WalletID:     id,
+						Type:         "deposit",
+						Amount:       100,
+						BalanceAfter: money.Amount(d * 100),
+						Currency:     "USD",
+						CreatedAt:    day(d).Add(time.Duration(len(id)) * time.Minute),
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name             string
+		period           statement.Period
+		opening, closing money.Amount
+		lines            []string
+	}{
+		{name: "whole history", period: statement.Period{To: day(7)}, opening: 0, closing: 600, lines: []string{"1", "3", "5", "7", "9", "11"}},
+		{name: "middle", period: statement.Period{From: day(3), To: day(4).Add(time.Hour)}, opening: 200, closing: 400, lines: []string{"5", "7"}},
+		{name: "after the last", period: statement.Period{From: day(7), To: day(8)}, opening: 600, closing: 600},
+		{name: "before the first", period: statement.Period{From: day(0), To: day(1)}, opening: 0, closing: 0},
+	}
+
+	dbs := map[string]func(t *testing.T) storage.DB{
+		"memory": func(*testing.T) storage.DB { return storage.NewMemory() },
+		"bolt": func(t *testing.T) storage.DB {
+			db, err := bolt.Open(filepath.Join(t.TempDir(), "wallet.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return db
+		},
+	}
+
+	for name, open := range dbs {
+		t.Run(name, func(t *testing.T) {
+			db := open(t)
+			seed(t, db)
+			service := statement.NewStatementService(store.NewStorage(db))
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					var r recorder
+					if err := service.Write(context.Background(), "a", tt.period, &r); err != nil {
+						t.Fatal(err)
+					}
+
+					if r.opening.Amount != tt.opening || r.closing.Amount != tt.closing {
+						t.Errorf("balances = %s, %s, want %s, %s", r.opening.Amount, r.closing.Amount, tt.opening, tt.closing)
+					}
+					if len(r.lines) != len(tt.lines) {
+						t.Fatalf("lines = %v, want %v", r.lines, tt.lines)
+					}
+					for i := range r.lines {
+						if r.lines[i] != tt.lines[i] {
+							t.Errorf("lines = %v, want %v", r.lines, tt.lines)
+							break
+						}
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+// Package store contains all implementation to read
+// wallet statements from storage.DB.
+package store
+
+import (
+	"context"
+	"time"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage"
+	"wallet/app/transaction"
+)
+
+// Storage reads statements from storage.DB.
+type Storage struct {
+	db storage.DB
+}
+
+// NewStorage is a constructor for storage.
+func NewStorage(db storage.DB) *Storage {
+	return &Storage{
+		db: db,
+	}
+}
+
+// Currency returns the wallet currency.
+func (s *Storage) Currency(ctx context.Context, id string) (money.Currency, error) {
+	var currency money.Currency
+
+	err := s.db.View(func(tx storage.Tx) error {
+		w, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return oops.ErrNotFound
+		}
+
+		currency = w.Currency
+		return nil
+	})
+
+	return currency, err
+}
+
+// Transactions returns up to limit wallet transactions after the transaction id in order.
+func (s *Storage) Transactions(ctx context.Context, id, after string, limit int) ([]transaction.Transaction, error) {
+	var transactions []transaction.Transaction
+
+	err := s.db.View(func(tx storage.Tx) error {
+		history, err := tx.HistoryAfter(id, after, limit)
+		if err != nil {
+			return err
+		}
+
+		transactions = make([]transaction.Transaction, 0, len(history))
+		for _, t := range history {
+			transactions = append(transactions, toTransaction(t))
+		}
+
+		return nil
+	})
+
+	return transactions, err
+}
+
+// LastBefore returns the last wallet transaction made before the time.
+func (s *Storage) LastBefore(ctx context.Context, id string, before time.Time) (transaction.Transaction, bool, error) {
+	var last transaction.Transaction
+	var found bool
+
+	err := s.db.View(func(tx storage.Tx) error {
+		t, ok, err := tx.HistoryBefore(id, before)
+		if err != nil {
+			return err
+		}
+
+		last, found = toTransaction(t), ok
+		return nil
+	})
+
+	return last, found, err
+}
+
+func toTransaction(t storage.Transaction) transaction.Transaction {
+	return transaction.Transaction{
+		ID:           t.ID,
+		WalletID:     t.WalletID,
+		Type:         t.Type,
+		Amount:       t.Amount,
+		Currency:     t.Currency,
+		Counterparty: t.Counterparty,
+		Rate:         t.Rate,
+		BalanceAfter: t.BalanceAfter,
+		CreatedAt:    t.CreatedAt,
+	}
+}
@@ -0,0 +1,70 @@
+// Package statement produces wallet account statements: an opening
+// balance, every transaction with the running balance and a closing
+// balance, streamed in CSV, JSON Lines or plain text.
+package statement
+
+import (
+	"context"
+	"time"
+
+	"wallet/app/fx"
+	"wallet/app/money"
+	"wallet/app/transaction"
+)
+
+// Statement formats.
+const (
+	FormatCSV       = "csv"
+	FormatJSONLines = "jsonl"
+	FormatText      = "txt"
+)
+
+// Period selects transactions made from From to To inclusive,
+// zero From starts from the first transaction and zero To
+// ends at the time the statement is made.
+type Period struct {
+	From, To time.Time
+}
+
+// Balance is the wallet balance at the start or the end of the period.
+type Balance struct {
+	WalletID string
+	Currency money.Currency
+	Time     time.Time
+	Amount   money.Amount
+}
+
+// Line is a statement transaction, Amount is the signed balance change.
+type Line struct {
+	ID           string
+	Type         string
+	Counterparty string
+	Amount       money.Amount
+	Rate         fx.Rate
+	Balance      money.Amount
+	Time         time.Time
+}
+
+// Writer renders a statement in one format, Opening is called
+// before the lines and Closing after them.
+type Writer interface {
+	Opening(Balance) error
+	Line(Line) error
+	Closing(Balance) error
+}
+
+// Store contains all methods to read the statement data from the storage.
+type Store interface {
+	// Currency returns the wallet currency, oops.ErrNotFound for unknown wallets.
+	Currency(ctx context.Context, id string) (money.Currency, error)
+	// Transactions returns up to limit wallet transactions after the
+	// transaction id in order, an empty id starts from the first one.
+	Transactions(ctx context.Context, id, after string, limit int) ([]transaction.Transaction, error)
+	// LastBefore returns the last wallet transaction made before the time.
+	LastBefore(ctx context.Context, id string, before time.Time) (t transaction.Transaction, found bool, err error)
+}
+
+// Service contains all methods from statement service.
+type Service interface {
+	Write(ctx context.Context, id string, p Period, w Writer) error
+}
@@ -0,0 +1,894 @@
+// Package bolt contains a file-backed storage.DB on bbolt.
+package bolt
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage"
+
+	"go.etcd.io/bbolt"
+)
+
+var (
+	bucketMeta         = []byte("meta")
+	bucketWallets      = []byte("wallets")
+	bucketPostings     = []byte("postings")
+	bucketBalances     = []byte("balances")
+	bucketTransactions = []byte("transactions")
+	bucketHistory      = []byte("history")
+	bucketEvents       = []byte("events")
+	bucketPending      = []byte("pending")
+	bucketSequences    = []byte("sequences")
+	bucketDead         = []byte("dead")
+	bucketStreams      = []byte("streams")
+	bucketWatermarks   = []byte("watermarks")
+	bucketHolds        = []byte("holds")
+	bucketWalletHolds  = []byte("wallet_holds")
+	bucketActiveHolds  = []byte("active_holds")
+	bucketReversals    = []byte("reversals")
+	bucketRuns         = []byte("runs")
+
+	keyVersion = []byte("version")
+)
+
+// migrations are applied in order, the index of a migration
+// plus one is the schema version it brings the file to.
+var migrations = []func(*bbolt.Tx) error{
+	func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketWallets, bucketPostings, bucketBalances, bucketTransactions, bucketHistory)
+	},
+	func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketEvents, bucketPending)
+	},
+	func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketSequences)
+	},
+	func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketDead)
+	},
+	// streams indexes the events by aggregate, the events
+	// recorded before are indexed by the migration.
+	func(tx *bbolt.Tx) error {
+		if err := createBuckets(tx, bucketStreams); err != nil {
+			return err
+		}
+
+		return tx.Bucket(bucketEvents).ForEach(func(k, v []byte) error {
+			var e storage.Event
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			return index(tx, e, k)
+		})
+	},
+	// the events recorded before the sequences were added have no
+	// Seq, they are numbered per aggregate in the order they were
+	// appended and the sequences and streams are built again.
+	backfillSequences,
+	// watermarks hold the latest transaction time up to every
+	// transaction, the ones appended before are marked by the migration.
+	markTransactions,
+	func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketHolds, bucketWalletHolds, bucketActiveHolds)
+	},
+	func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketReversals)
+	},
+	func(tx *bbolt.Tx) error {
+		return createBuckets(tx, bucketRuns)
+	},
+}
+
+// DB is a storage.DB in a single bbolt file.
+type DB struct {
+	db *bbolt.DB
+}
+
+// Open opens or creates the database file and migrates its schema.
+func Open(path string) (*DB, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("bolt open error: %w", err)
+	}
+
+	if err = migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{
+		db: db,
+	}, nil
+}
+
+// View runs fn in a read-only transaction.
+func (d *DB) View(fn func(storage.Tx) error) error {
+	return d.db.View(func(tx *bbolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+// Update runs fn in a read-write transaction.
+func (d *DB) Update(fn func(storage.Tx) error) error {
+	return d.db.Update(func(tx *bbolt.Tx) error {
+		return fn(&boltTx{tx: tx})
+	})
+}
+
+// Close closes the database file.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func migrate(db *bbolt.DB) error {
+	return db.Update(func(tx *bbolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+
+		var version uint64
+		if v := meta.Get(keyVersion); v != nil {
+			version = binary.BigEndian.Uint64(v)
+		}
+
+		if version > uint64(len(migrations)) {
+			return fmt.Errorf("bolt schema version %d is newer than %d", version, len(migrations))
+		}
+
+		for ; version < uint64(len(migrations)); version++ {
+			if err = migrations[version](tx); err != nil {
+				return fmt.Errorf("bolt migration %d error: %w", version+1, err)
+			}
+		}
+
+		return meta.Put(keyVersion, itob(version))
+	})
+}
+
+func backfillSequences(tx *bbolt.Tx) error {
+	type row struct {
+		key []byte
+		e   storage.Event
+	}
+
+	var rows []row
+	missing := false
+	err := tx.Bucket(bucketEvents).ForEach(func(k, v []byte) error {
+		var e storage.Event
+		if err := json.Unmarshal(v, &e); err != nil {
+			return err
+		}
+		missing = missing || e.Seq == 0
+		rows = append(rows, row{key: append([]byte(nil), k...), e: e})
+		return nil
+	})
+	if err != nil || !missing {
+		return err
+	}
+
+	for _, name := range [][]byte{bucketSequences, bucketStreams} {
+		if err = tx.DeleteBucket(name); err != nil {
+			return err
+		}
+	}
+	if err = createBuckets(tx, bucketSequences, bucketStreams); err != nil {
+		return err
+	}
+
+	seqs := make(map[string]uint64)
+	for _, r := range rows {
+		seqs[r.e.AggregateID]++
+		r.e.Seq = seqs[r.e.AggregateID]
+
+		if err = put(tx.Bucket(bucketEvents), r.key, r.e); err != nil {
+			return err
+		}
+		if err = index(tx, r.e, r.key); err != nil {
+			return err
+		}
+	}
+
+	sequences := tx.Bucket(bucketSequences)
+	for id, seq := range seqs {
+		if err = sequences.Put([]byte(id), itob(seq)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func markTransactions(tx *bbolt.Tx) error {
+	if err := createBuckets(tx, bucketWatermarks); err != nil {
+		return err
+	}
+
+	var mark time.Time
+	watermarks := tx.Bucket(bucketWatermarks)
+	return tx.Bucket(bucketTransactions).ForEach(func(k, v []byte) error {
+		var tr storage.Transaction
+		if err := json.Unmarshal(v, &tr); err != nil {
+			return err
+		}
+		if tr.CreatedAt.After(mark) {
+			mark = tr.CreatedAt
+		}
+		return putTime(watermarks, k, mark)
+	})
+}
+
+func createBuckets(tx *bbolt.Tx, names ...[]byte) error {
+	for _, name := range names {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type boltTx struct {
+	tx *bbolt.Tx
+}
+
+func (t *boltTx) Wallet(id string) (storage.Wallet, bool, error) {
+	var w storage.Wallet
+
+	v := t.tx.Bucket(bucketWallets).Get([]byte(id))
+	if v == nil {
+		return w, false, nil
+	}
+
+	err := json.Unmarshal(v, &w)
+	return w, err == nil, err
+}
+
+func (t *boltTx) Wallets() (map[string]storage.Wallet, error) {
+	wallets := make(map[string]storage.Wallet)
+
+	err := t.tx.Bucket(bucketWallets).ForEach(func(k, v []byte) error {
+		var w storage.Wallet
+		if err := json.Unmarshal(v, &w); err != nil {
+			return err
+		}
+		wallets[string(k)] = w
+		return nil
+	})
+
+	return wallets, err
+}
+
+func (t *boltTx) PutWallet(id string, w storage.Wallet) error {
+	return put(t.tx.Bucket(bucketWallets), []byte(id), w)
+}
+
+func (t *boltTx) Post(entries ...storage.Entry) (storage.Posting, error) {
+	if err := storage.Balanced(entries); err != nil {
+		return storage.Posting{}, err
+	}
+	balances, err := storage.Posted(entries, t.Balance)
+	if err != nil {
+		return storage.Posting{}, err
+	}
+
+	postings := t.tx.Bucket(bucketPostings)
+	seq, err := postings.NextSequence()
+	if err != nil {
+		return storage.Posting{}, err
+	}
+
+	p := storage.Posting{
+		ID:        strconv.FormatUint(seq, 10),
+		Entries:   entries,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	if err = put(postings, itob(seq), p); err != nil {
+		return storage.Posting{}, err
+	}
+
+	bucket := t.tx.Bucket(bucketBalances)
+	for a, balance := range balances {
+		if err = bucket.Put(accountKey(a), itob(uint64(balance))); err != nil {
+			return storage.Posting{}, err
+		}
+	}
+
+	return p, nil
+}
+
+func (t *boltTx) Balance(a storage.Account) (money.Amount, error) {
+	return decodeAmount(t.tx.Bucket(bucketBalances).Get(accountKey(a))), nil
+}
+
+func (t *boltTx) Balances() (map[storage.Account]money.Amount, error) {
+	balances := make(map[storage.Account]money.Amount)
+
+	err := t.tx.Bucket(bucketBalances).ForEach(func(k, v []byte) error {
+		name, currency, _ := strings.Cut(string(k), "\x00")
+		balances[storage.Account{Name: name, Currency: money.Currency(currency)}] = decodeAmount(v)
+		return nil
+	})
+
+	return balances, err
+}
+
+func (t *boltTx) Postings() ([]storage.Posting, error) {
+	var postings []storage.Posting
+
+	err := t.tx.Bucket(bucketPostings).ForEach(func(k, v []byte) error {
+		var p storage.Posting
+		if err := json.Unmarshal(v, &p); err != nil {
+			return err
+		}
+		postings = append(postings, p)
+		return nil
+	})
+
+	return postings, err
+}
+
+func (t *boltTx) AppendTransaction(tr storage.Transaction) (storage.Transaction, error) {
+	transactions := t.tx.Bucket(bucketTransactions)
+	seq, err := transactions.NextSequence()
+	if err != nil {
+		return storage.Transaction{}, err
+	}
+
+	tr.ID = strconv.FormatUint(seq, 10)
+	if tr.CreatedAt.IsZero() {
+		tr.CreatedAt = time.Now().UTC()
+	}
+
+	if err = put(transactions, itob(seq), tr); err != nil {
+		return storage.Transaction{}, err
+	}
+
+	mark, err := t.watermark(seq - 1)
+	if err != nil {
+		return storage.Transaction{}, err
+	}
+	if tr.CreatedAt.After(mark) {
+		mark = tr.CreatedAt
+	}
+	if err = putTime(t.tx.Bucket(bucketWatermarks), itob(seq), mark); err != nil {
+		return storage.Transaction{}, err
+	}
+
+	history, err := t.tx.Bucket(bucketHistory).CreateBucketIfNotExists([]byte(tr.WalletID))
+	if err != nil {
+		return storage.Transaction{}, err
+	}
+	if err = history.Put(itob(seq), nil); err != nil {
+		return storage.Transaction{}, err
+	}
+
+	if tr.Reverses != "" {
+		reversals, err := t.tx.Bucket(bucketReversals).CreateBucketIfNotExists([]byte(tr.Reverses))
+		if err != nil {
+			return storage.Transaction{}, err
+		}
+		if err = reversals.Put(itob(seq), nil); err != nil {
+			return storage.Transaction{}, err
+		}
+	}
+
+	return tr, nil
+}
+
+func (t *boltTx) Transaction(id string) (storage.Transaction, bool, error) {
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return storage.Transaction{}, false, nil
+	}
+	return t.transaction(itob(seq))
+}
+
+func (t *boltTx) History(walletID string) ([]storage.Transaction, error) {
+	history := t.tx.Bucket(bucketHistory).Bucket([]byte(walletID))
+	if history == nil {
+		return nil, nil
+	}
+	return t.transactions(history)
+}
+
+func (t *boltTx) Reversals(id string) ([]storage.Transaction, error) {
+	reversals := t.tx.Bucket(bucketReversals).Bucket([]byte(id))
+	if reversals == nil {
+		return nil, nil
+	}
+	return t.transactions(reversals)
+}
+
+// transactions returns the transactions whose keys are in the index bucket.
+func (t *boltTx) transactions(index *bbolt.Bucket) ([]storage.Transaction, error) {
+	var transactions []storage.Transaction
+	err := index.ForEach(func(k, _ []byte) error {
+		tr, found, err := t.transaction(k)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("bolt index refers to missing transaction %d", binary.BigEndian.Uint64(k))
+		}
+		transactions = append(transactions, tr)
+		return nil
+	})
+
+	return transactions, err
+}
+
+func (t *boltTx) HistoryAfter(walletID, after string, limit int) ([]storage.Transaction, error) {
+	var start uint64
+	if after != "" {
+		seq, err := strconv.ParseUint(after, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unknown transaction %q", after)
+		}
+		start = seq
+	}
+
+	history := t.tx.Bucket(bucketHistory).Bucket([]byte(walletID))
+	if history == nil {
+		return nil, nil
+	}
+
+	var transactions []storage.Transaction
+	c := history.Cursor()
+	for k, _ := c.Seek(itob(start + 1)); k != nil && len(transactions) < limit; k, _ = c.Next() {
+		tr, found, err := t.transaction(k)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return nil, fmt.Errorf("bolt history refers to missing transaction %d", binary.BigEndian.Uint64(k))
+		}
+		transactions = append(transactions, tr)
+	}
+
+	return transactions, nil
+}
+
+func (t *boltTx) HistoryBefore(walletID string, before time.Time) (storage.Transaction, bool, error) {
+	history := t.tx.Bucket(bucketHistory).Bucket([]byte(walletID))
+	if history == nil {
+		return storage.Transaction{}, false, nil
+	}
+
+	// the first transaction of the log with a watermark at the time or
+	// later is found by binary search, the wallet history is read back
+	// from it.
+	var err error
+	first := sort.Search(int(t.tx.Bucket(bucketTransactions).Sequence()), func(i int) bool {
+		mark, merr := t.watermark(uint64(i) + 1)
+		if merr != nil {
+			err = merr
+			return true
+		}
+		return !mark.Before(before)
+	})
+	if err != nil {
+		return storage.Transaction{}, false, err
+	}
+
+	c := history.Cursor()
+	k, _ := c.Seek(itob(uint64(first) + 1))
+	if k == nil {
+		k, _ = c.Last()
+	} else {
+		k, _ = c.Prev()
+	}
+	if k == nil {
+		return storage.Transaction{}, false, nil
+	}
+
+	return t.transaction(k)
+}
+
+// watermark returns the latest time of the transactions up to seq,
+// zero time for none.
+func (t *boltTx) watermark(seq uint64) (time.Time, error) {
+	var mark time.Time
+	if seq == 0 {
+		return mark, nil
+	}
+
+	v := t.tx.Bucket(bucketWatermarks).Get(itob(seq))
+	if v == nil {
+		return mark, fmt.Errorf("bolt watermark of transaction %d is missing", seq)
+	}
+
+	err := mark.UnmarshalBinary(v)
+	return mark, err
+}
+
+func (t *boltTx) transaction(key []byte) (storage.Transaction, bool, error) {
+	var tr storage.Transaction
+
+	v := t.tx.Bucket(bucketTransactions).Get(key)
+	if v == nil {
+		return tr, false, nil
+	}
+
+	err := json.Unmarshal(v, &tr)
+	return tr, err == nil, err
+}
+
+func (t *boltTx) AppendHold(h storage.Hold) (storage.Hold, error) {
+	holds := t.tx.Bucket(bucketHolds)
+	seq, err := holds.NextSequence()
+	if err != nil {
+		return storage.Hold{}, err
+	}
+
+	h.ID = strconv.FormatUint(seq, 10)
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = time.Now().UTC()
+	}
+
+	walletHolds, err := t.tx.Bucket(bucketWalletHolds).CreateBucketIfNotExists([]byte(h.WalletID))
+	if err != nil {
+		return storage.Hold{}, err
+	}
+	if err = walletHolds.Put(itob(seq), nil); err != nil {
+		return storage.Hold{}, err
+	}
+
+	return h, t.putHold(seq, h)
+}
+
+func (t *boltTx) PutHold(h storage.Hold) error {
+	seq, err := strconv.ParseUint(h.ID, 10, 64)
+	if err != nil {
+		return fmt.Errorf("unknown hold %q", h.ID)
+	}
+
+	prev, found, err := t.hold(itob(seq))
+	if err != nil {
+		return err
+	}
+	if !found || prev.WalletID != h.WalletID {
+		return fmt.Errorf("unknown hold %q", h.ID)
+	}
+
+	return t.putHold(seq, h)
+}
+
+// putHold stores the hold and keeps the active holds index.
+func (t *boltTx) putHold(seq uint64, h storage.Hold) error {
+	if err := put(t.tx.Bucket(bucketHolds), itob(seq), h); err != nil {
+		return err
+	}
+
+	active := t.tx.Bucket(bucketActiveHolds)
+	if h.Status == storage.HoldActive {
+		return active.Put(itob(seq), nil)
+	}
+	return active.Delete(itob(seq))
+}
+
+func (t *boltTx) Hold(id string) (storage.Hold, bool, error) {
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return storage.Hold{}, false, nil
+	}
+	return t.hold(itob(seq))
+}
+
+func (t *boltTx) Holds(walletID string) ([]storage.Hold, error) {
+	walletHolds := t.tx.Bucket(bucketWalletHolds).Bucket([]byte(walletID))
+	if walletHolds == nil {
+		return nil, nil
+	}
+	return t.holds(walletHolds)
+}
+
+func (t *boltTx) ActiveHolds() ([]storage.Hold, error) {
+	return t.holds(t.tx.Bucket(bucketActiveHolds))
+}
+
+func (t *boltTx) Run(key string) (storage.Run, bool, error) {
+	v := t.tx.Bucket(bucketRuns).Get([]byte(key))
+	if v == nil {
+		return storage.Run{}, false, nil
+	}
+
+	var r storage.Run
+	if err := json.Unmarshal(v, &r); err != nil {
+		return storage.Run{}, false, err
+	}
+	return r, true, nil
+}
+
+func (t *boltTx) PutRun(r storage.Run) error {
+	return put(t.tx.Bucket(bucketRuns), []byte(r.Key), r)
+}
+
+// holds returns the holds whose keys are in the index bucket.
+func (t *boltTx) holds(index *bbolt.Bucket) ([]storage.Hold, error) {
+	var holds []storage.Hold
+	err := index.ForEach(func(k, _ []byte) error {
+		h, found, err := t.hold(k)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("bolt index refers to missing hold %d", binary.BigEndian.Uint64(k))
+		}
+		holds = append(holds, h)
+		return nil
+	})
+
+	return holds, err
+}
+
+func (t *boltTx) hold(key []byte) (storage.Hold, bool, error) {
+	var h storage.Hold
+
+	v := t.tx.Bucket(bucketHolds).Get(key)
+	if v == nil {
+		return h, false, nil
+	}
+
+	err := json.Unmarshal(v, &h)
+	return h, err == nil, err
+}
+
+func (t *boltTx) AppendEvent(e storage.Event) (storage.Event, error) {
+	events := t.tx.Bucket(bucketEvents)
+	seq, err := events.NextSequence()
+	if err != nil {
+		return storage.Event{}, err
+	}
+
+	e.ID = strconv.FormatUint(seq, 10)
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	e.Delivered = false
+
+	sequences := t.tx.Bucket(bucketSequences)
+	key := []byte(e.AggregateID)
+	if v := sequences.Get(key); v != nil {
+		e.Seq = binary.BigEndian.Uint64(v)
+	}
+	e.Seq++
+	if err = sequences.Put(key, itob(e.Seq)); err != nil {
+		return storage.Event{}, err
+	}
+
+	if err = put(events, itob(seq), e); err != nil {
+		return storage.Event{}, err
+	}
+	if err = index(t.tx, e, itob(seq)); err != nil {
+		return storage.Event{}, err
+	}
+
+	return e, t.tx.Bucket(bucketPending).Put(itob(seq), nil)
+}
+
+func (t *boltTx) Events(after string, limit int) ([]storage.Event, error) {
+	var start uint64
+	if after != "" {
+		seq, err := strconv.ParseUint(after, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unknown event %q", after)
+		}
+		start = seq
+	}
+
+	var events []storage.Event
+	c := t.tx.Bucket(bucketEvents).Cursor()
+	for k, v := c.Seek(itob(start + 1)); k != nil && len(events) < limit; k, v = c.Next() {
+		var e storage.Event
+		if err := json.Unmarshal(v, &e); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+func (t *boltTx) Stream(aggregateID string) ([]storage.Event, error) {
+	stream := t.tx.Bucket(bucketStreams).Bucket([]byte(aggregateID))
+	if stream == nil {
+		return nil, nil
+	}
+
+	events := t.tx.Bucket(bucketEvents)
+
+	var s []storage.Event
+	err := stream.ForEach(func(_, key []byte) error {
+		var e storage.Event
+		if err := json.Unmarshal(events.Get(key), &e); err != nil {
+			return err
+		}
+		s = append(s, e)
+		return nil
+	})
+
+	return s, err
+}
+
+func (t *boltTx) PendingEvents(limit int, skip func(storage.Event) bool) ([]storage.Event, error) {
+	events := t.tx.Bucket(bucketEvents)
+
+	var pending []storage.Event
+	c := t.tx.Bucket(bucketPending).Cursor()
+	for k, _ := c.First(); k != nil && len(pending) < limit; k, _ = c.Next() {
+		var e storage.Event
+		if err := json.Unmarshal(events.Get(k), &e); err != nil {
+			return nil, err
+		}
+		if skip == nil || !skip(e) {
+			pending = append(pending, e)
+		}
+	}
+
+	return pending, nil
+}
+
+func (t *boltTx) MarkDelivered(id string) error {
+	seq, e, err := t.event(id)
+	if err != nil {
+		return err
+	}
+
+	e.Delivered = true
+	if err = put(t.tx.Bucket(bucketEvents), itob(seq), e); err != nil {
+		return err
+	}
+
+	return t.tx.Bucket(bucketPending).Delete(itob(seq))
+}
+
+func (t *boltTx) DeadLetter(id, reason string) error {
+	seq, e, err := t.event(id)
+	if err != nil {
+		return err
+	}
+
+	pending := t.tx.Bucket(bucketPending)
+	if pending.Get(itob(seq)) == nil {
+		return fmt.Errorf("event %q is not pending", id)
+	}
+
+	e.Dead, e.Reason, e.DeadAt = true, reason, time.Now().UTC()
+	if err = put(t.tx.Bucket(bucketEvents), itob(seq), e); err != nil {
+		return err
+	}
+	if err = pending.Delete(itob(seq)); err != nil {
+		return err
+	}
+
+	return t.tx.Bucket(bucketDead).Put(itob(seq), nil)
+}
+
+func (t *boltTx) DeadLetters() ([]storage.Event, error) {
+	events := t.tx.Bucket(bucketEvents)
+
+	var dead []storage.Event
+	c := t.tx.Bucket(bucketDead).Cursor()
+	for k, _ := c.First(); k != nil; k, _ = c.Next() {
+		var e storage.Event
+		if err := json.Unmarshal(events.Get(k), &e); err != nil {
+			return nil, err
+		}
+		dead = append(dead, e)
+	}
+
+	return dead, nil
+}
+
+func (t *boltTx) Requeue(id string) error {
+	seq, e, err := t.deadEvent(id)
+	if err != nil {
+		return err
+	}
+
+	e.Dead, e.Reason, e.DeadAt = false, "", time.Time{}
+	if err = put(t.tx.Bucket(bucketEvents), itob(seq), e); err != nil {
+		return err
+	}
+	if err = t.tx.Bucket(bucketDead).Delete(itob(seq)); err != nil {
+		return err
+	}
+
+	return t.tx.Bucket(bucketPending).Put(itob(seq), nil)
+}
+
+func (t *boltTx) Discard(id string) error {
+	seq, e, err := t.deadEvent(id)
+	if err != nil {
+		return err
+	}
+
+	e.Dead, e.Discarded = false, true
+	if err = put(t.tx.Bucket(bucketEvents), itob(seq), e); err != nil {
+		return err
+	}
+	if err = t.tx.Bucket(bucketDead).Delete(itob(seq)); err != nil {
+		return err
+	}
+
+	return t.tx.Bucket(bucketPending).Put(itob(seq), nil)
+}
+
+// event reads an event by id.
+func (t *boltTx) event(id string) (uint64, storage.Event, error) {
+	seq, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, storage.Event{}, fmt.Errorf("unknown event %q", id)
+	}
+
+	v := t.tx.Bucket(bucketEvents).Get(itob(seq))
+	if v == nil {
+		return 0, storage.Event{}, fmt.Errorf("unknown event %q", id)
+	}
+
+	var e storage.Event
+	err = json.Unmarshal(v, &e)
+	return seq, e, err
+}
+
+// deadEvent reads a dead-lettered event by id.
+func (t *boltTx) deadEvent(id string) (uint64, storage.Event, error) {
+	seq, e, err := t.event(id)
+	if err != nil || !e.Dead {
+		return 0, storage.Event{}, oops.ErrNotFound
+	}
+	return seq, e, nil
+}
+
+// index adds the event key to the stream of its aggregate by sequence.
+func index(tx *bbolt.Tx, e storage.Event, key []byte) error {
+	stream, err := tx.Bucket(bucketStreams).CreateBucketIfNotExists([]byte(e.AggregateID))
+	if err != nil {
+		return err
+	}
+	return stream.Put(itob(e.Seq), key)
+}
+
+func put(b *bbolt.Bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+func accountKey(a storage.Account) []byte {
+	return []byte(a.Name + "\x00" + string(a.Currency))
+}
+
+func decodeAmount(v []byte) money.Amount {
+	if v == nil {
+		return 0
+	}
+	return money.Amount(binary.BigEndian.Uint64(v))
+}
+
+// putTime stores the time at the key.
+func putTime(b *bbolt.Bucket, key []byte, t time.Time) error {
+	data, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+// itob encodes n as 8 bytes so keys are sorted numerically.
+func itob(n uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	return b
+}
@@ -0,0 +1,113 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"go.etcd.io/bbolt"
+
+	"wallet/app/storage"
+)
+
+// writeV2 creates a file at schema version 2, before the events had
+// a sequence per aggregate.
+func writeV2(t *testing.T, path string, rows []string) {
+	t.Helper()
+
+	db, err := bbolt.Open(path, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, migration := range migrations[:2] {
+			if err := migration(tx); err != nil {
+				return err
+			}
+		}
+
+		events := tx.Bucket(bucketEvents)
+		for _, row := range rows {
+			seq, err := events.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err = events.Put(itob(seq), []byte(row)); err != nil {
+				return err
+			}
+			if err = tx.Bucket(bucketPending).Put(itob(seq), nil); err != nil {
+				return err
+			}
+		}
+
+		meta, err := tx.CreateBucketIfNotExists(bucketMeta)
+		if err != nil {
+			return err
+		}
+		return meta.Put(keyVersion, itob(2))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateBackfillsSequences(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallet.db")
+	writeV2(t, path, []string{
+		`{"ID":"1","AggregateID":"a","Name":"Wallet_Created","Version":1}`,
+		`{"ID":"2","AggregateID":"b","Name":"Wallet_Created","Version":1}`,
+		`{"ID":"3","AggregateID":"a","Name":"Wallet_Deposited","Version":1}`,
+		`{"ID":"4","AggregateID":"a","Name":"Wallet_Withdrawn","Version":1}`,
+	})
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Update(func(tx storage.Tx) error {
+		e, err := tx.AppendEvent(storage.Event{AggregateID: "a", Name: "Wallet_Deposited", Version: 1})
+		if err != nil {
+			return err
+		}
+		if e.Seq != 4 {
+			t.Errorf("appended seq = %d, want 4", e.Seq)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		aggregate string
+		ids       []string
+	}{
+		{aggregate: "a", ids: []string{"1", "3", "4", "5"}},
+		{aggregate: "b", ids: []string{"2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.aggregate, func(t *testing.T) {
+			var stream []storage.Event
+			err := db.View(func(tx storage.Tx) error {
+				stream, err = tx.Stream(tt.aggregate)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(stream) != len(tt.ids) {
+				t.Fatalf("stream has %d events, want %d", len(stream), len(tt.ids))
+			}
+			for i, e := range stream {
+				if e.ID != tt.ids[i] || e.Seq != uint64(i+1) {
+					t.Errorf("event %d = %s seq %d, want %s seq %d", i, e.ID, e.Seq, tt.ids[i], i+1)
+				}
+			}
+		})
+	}
+}
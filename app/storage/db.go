@@ -0,0 +1,262 @@
+// Package storage is a data storage.
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"wallet/app/fx"
+	"wallet/app/money"
+)
+
+// Transaction types.
+const (
+	TxDeposit           = "deposit"
+	TxWithdrawal        = "withdrawal"
+	TxTransferOut       = "transfer_out"
+	TxTransferIn        = "transfer_in"
+	TxCapture           = "capture"
+	TxReversalOut       = "reversal_out"
+	TxReversalIn        = "reversal_in"
+	TxFee               = "fee"
+	TxFeeIncome         = "fee_income"
+	TxOverdraftInterest = "overdraft_interest"
+	TxInterest          = "interest"
+)
+
+// Hold statuses, only an active hold reduces the available balance.
+const (
+	HoldActive   = "active"
+	HoldCaptured = "captured"
+	HoldReleased = "released"
+	HoldExpired  = "expired"
+)
+
+// ErrReadOnly is returned by Tx write methods called inside View.
+var ErrReadOnly = errors.New("read-only transaction")
+
+// Wallet contains data fields of a wallet, the wallet balance
+// is derived from the journal, see WalletBalance. Held is the sum
+// of the active holds, the available balance is the balance less Held.
+// Limits override the limits of the Tier, empty Tier is the default one.
+// CreditLimit is how far the balance may go below zero and ChargedOn is
+// the UTC date of the last overdraft interest charge. InterestRate is
+// the daily interest of a positive balance in percent, zero takes the
+// rate of the wallet product. Revisions are the names and statuses
+// the wallet had, a wallet created before they were kept has none.
+type Wallet struct {
+	Name, Status string
+	Currency     money.Currency
+	Held         money.Amount
+	Tier         string
+	Limits       Limits
+	CreditLimit  money.Amount
+	ChargedOn    string
+	InterestRate fx.Rate
+	Revisions    []Revision
+}
+
+// Credit returns the part of the credit limit not used by the wallet
+// balance and its holds.
+func (w Wallet) Credit(balance money.Amount) money.Amount {
+	available := balance - w.Held
+	if available >= 0 {
+		return w.CreditLimit
+	}
+	if w.CreditLimit+available < 0 {
+		return 0
+	}
+	return w.CreditLimit + available
+}
+
+// Revision is the name and status of a wallet from the time on.
+type Revision struct {
+	Name, Status string
+	Time         time.Time
+}
+
+// Revise returns the revisions with the next one appended, a revision
+// made while the clock was behind takes the time of the last one, so
+// the revisions stay in the order of time.
+func Revise(revisions []Revision, r Revision) []Revision {
+	if n := len(revisions); n > 0 && r.Time.Before(revisions[n-1].Time) {
+		r.Time = revisions[n-1].Time
+	}
+
+	// the stored wallets may share the array, so it is copied.
+	return append(revisions[:len(revisions):len(revisions)], r)
+}
+
+// RevisionAt returns the revision of the wallet at the time,
+// found is false if the wallet had none by then.
+func (w Wallet) RevisionAt(t time.Time) (r Revision, found bool) {
+	n := sort.Search(len(w.Revisions), func(i int) bool {
+		return w.Revisions[i].Time.After(t)
+	})
+	if n == 0 {
+		return Revision{}, false
+	}
+
+	return w.Revisions[n-1], true
+}
+
+// Limits restricts the operations of a wallet, amounts are in the
+// wallet currency and a zero limit is not set.
+type Limits struct {
+	MaxWithdrawal, DailyOutgoing, MonthlyOutgoing, MaxBalance money.Amount
+	HourlyTransfers                                           int
+}
+
+// Transaction is an immutable record of a wallet balance change.
+// A reversal takes Amount from the wallet or gives it back and
+// Reverses is the id of the reversed transaction.
+type Transaction struct {
+	ID, WalletID, Type, Counterparty string
+	Amount, BalanceAfter             money.Amount
+	Currency                         money.Currency
+	Rate                             fx.Rate
+	Reverses                         string
+	CreatedAt                        time.Time
+}
+
+// Hold reserves Amount of a wallet balance until ExpiresAt, it is
+// closed by a capture of Captured with TransactionID, a release or
+// the expiry.
+type Hold struct {
+	ID, WalletID, Status string
+	Amount, Captured     money.Amount
+	Currency             money.Currency
+	TransactionID        string
+	CreatedAt, ExpiresAt time.Time
+	ClosedAt             time.Time
+}
+
+// Run is a completed run of a scheduled job, Key names the job and the
+// period the run covers, so a job doesn't repeat the work of a period.
+type Run struct {
+	Key string
+	At  time.Time
+}
+
+// InterestRun is the run key of the interest paid for the UTC date.
+func InterestRun(date string) string {
+	return "interest/" + date
+}
+
+// OverdraftRun is the run key of the overdraft interest charged for the UTC date.
+func OverdraftRun(date string) string {
+	return "overdraft/" + date
+}
+
+// Event is an outbox record of a change to be published to the queue,
+// events of one aggregate are published in the order of their ids.
+// Seq numbers the events of an aggregate starting from 1, Payload
+// is the JSON of the event type at the schema Version. An event which
+// can't be published is dead-lettered with the Reason until it is
+// requeued or discarded, a discarded event is pending again to be
+// published as a tombstone keeping its Seq.
+type Event struct {
+	ID, AggregateID, Name string
+	Version               int
+	Seq                   uint64
+	CorrelationID         string
+	Payload               json.RawMessage
+	CreatedAt             time.Time
+	Delivered             bool
+
+	Dead, Discarded bool
+	Reason          string
+	DeadAt          time.Time
+}
+
+// DB is a transactional data storage, stores work with the data only
+// inside View or Update so every operation is applied as a single unit.
+type DB interface {
+	// View runs fn in a read-only transaction.
+	View(fn func(Tx) error) error
+	// Update runs fn in a read-write transaction, changes are
+	// committed if fn returns nil and discarded otherwise.
+	Update(fn func(Tx) error) error
+	Close() error
+}
+
+// Tx is a unit of work over the storage.
+type Tx interface {
+	// Wallet returns a wallet by id, found is false for unknown ids.
+	Wallet(id string) (w Wallet, found bool, err error)
+	// Wallets returns all wallets by id.
+	Wallets() (map[string]Wallet, error)
+	PutWallet(id string, w Wallet) error
+
+	// Post validates that entries are balanced, appends them to
+	// the journal and updates account balances.
+	Post(entries ...Entry) (Posting, error)
+	// Balance returns a journal account balance.
+	Balance(a Account) (money.Amount, error)
+	// Balances returns balances of all journal accounts.
+	Balances() (map[Account]money.Amount, error)
+	// Postings returns the whole journal in order.
+	Postings() ([]Posting, error)
+
+	// AppendTransaction assigns a sequential id and, unless it is set,
+	// timestamp to the transaction and appends it to the log.
+	AppendTransaction(t Transaction) (Transaction, error)
+	// Transaction returns a transaction by id.
+	Transaction(id string) (t Transaction, found bool, err error)
+	// History returns wallet transactions in order.
+	History(walletID string) ([]Transaction, error)
+	// HistoryAfter returns up to limit wallet transactions after the
+	// transaction id in order, an empty id starts from the first one.
+	HistoryAfter(walletID, after string, limit int) ([]Transaction, error)
+	// HistoryBefore returns the last wallet transaction appended before
+	// the log reached the time. The transactions up to it were made before
+	// the time also if the clock went back meanwhile, so the history after
+	// it holds all the ones made at the time or later.
+	HistoryBefore(walletID string, before time.Time) (t Transaction, found bool, err error)
+	// Reversals returns the transactions reversing the transaction id in order.
+	Reversals(id string) ([]Transaction, error)
+
+	// AppendHold assigns a sequential id and, unless it is set,
+	// timestamp to the hold and stores it.
+	AppendHold(h Hold) (Hold, error)
+	// PutHold replaces a stored hold.
+	PutHold(h Hold) error
+	// Hold returns a hold by id, found is false for unknown ids.
+	Hold(id string) (h Hold, found bool, err error)
+	// Holds returns wallet holds in order.
+	Holds(walletID string) ([]Hold, error)
+	// ActiveHolds returns active holds of all wallets in order.
+	ActiveHolds() ([]Hold, error)
+
+	// Run returns a completed job run by key, found is false if it didn't run.
+	Run(key string) (r Run, found bool, err error)
+	// PutRun records a completed job run.
+	PutRun(r Run) error
+
+	// AppendEvent assigns a sequential id, the next sequence number of its
+	// aggregate and, unless it is set, timestamp to the event and appends
+	// it to the outbox.
+	AppendEvent(e Event) (Event, error)
+	// Events returns up to limit events after the event id in order,
+	// an empty id starts from the first event.
+	Events(after string, limit int) ([]Event, error)
+	// Stream returns the events of an aggregate in order.
+	Stream(aggregateID string) ([]Event, error)
+	// PendingEvents returns up to limit undelivered events in order
+	// without the ones skip returns true for, skip may be nil.
+	PendingEvents(limit int, skip func(Event) bool) ([]Event, error)
+	// MarkDelivered marks the event as published.
+	MarkDelivered(id string) error
+
+	// DeadLetter moves a pending event to the dead letters.
+	DeadLetter(id, reason string) error
+	// DeadLetters returns dead-lettered events in order.
+	DeadLetters() ([]Event, error)
+	// Requeue moves a dead-lettered event back to the pending events,
+	// Discard does it marking the event discarded, both return
+	// oops.ErrNotFound for other events.
+	Requeue(id string) error
+	Discard(id string) error
+}
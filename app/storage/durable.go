@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallet/app/storage/wal"
+)
+
+const (
+	walFile      = "wal.log"
+	snapshotFile = "snapshot.json"
+)
+
+// DurableOptions contains the directory with the log and snapshots,
+// the log fsync policy and how often snapshots are taken.
+type DurableOptions struct {
+	Dir              string
+	WAL              wal.Options
+	SnapshotInterval time.Duration
+}
+
+// record is a committed transaction in the log.
+type record struct {
+	Seq uint64
+	Ops []op
+}
+
+// snapshot is the whole state of Memory after the record Seq,
+// balances and history are derived from it on load.
+type snapshot struct {
+	Seq          uint64
+	Wallets      map[string]Wallet
+	Transactions []Transaction
+	Journal      []Posting
+	Events       []Event
+	Holds        []Hold
+	Runs         map[string]Run
+}
+
+// OpenMemory recovers Memory from the latest snapshot in dir and the
+// log records after it, then writes every committed change to the log.
+func OpenMemory(opts DurableOptions) (*Memory, error) {
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("memory dir error: %w", err)
+	}
+
+	m := NewMemory()
+	if err := m.loadSnapshot(filepath.Join(opts.Dir, snapshotFile)); err != nil {
+		return nil, err
+	}
+
+	l, err := wal.Open(filepath.Join(opts.Dir, walFile), opts.WAL)
+	if err != nil {
+		return nil, err
+	}
+
+	err = l.Replay(func(payload []byte) error {
+		var r record
+		if err := json.Unmarshal(payload, &r); err != nil {
+			return fmt.Errorf("wal record decode error: %w", err)
+		}
+
+		// the log may still contain records saved in the snapshot
+		// if the process stopped before the log was truncated. A seq
+		// of a failed commit is skipped, so the seqs may have gaps.
+		if r.Seq <= m.seq {
+			return nil
+		}
+
+		for _, o := range r.Ops {
+			m.apply(o)
+		}
+		m.seq = r.Seq
+
+		return nil
+	})
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	m.dir = opts.Dir
+	m.log = l
+	log.Printf("memory storage recovered at record %d", m.seq)
+
+	if opts.SnapshotInterval > 0 {
+		m.snapshots = newSnapshotter(m, opts.SnapshotInterval)
+	}
+
+	return m, nil
+}
+
+// Close takes a final snapshot and closes the log of a durable Memory.
+func (m *Memory) Close() error {
+	if m.log == nil {
+		return nil
+	}
+
+	if m.snapshots != nil {
+		m.snapshots.close()
+		if err := m.Snapshot(); err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	return m.log.Close()
+}
+
+// commit writes ops to the log as the next record, the caller must hold
+// the lock. The seq is taken before the write and not reused if it fails,
+// so a record of a failed commit can never hide a later one on replay.
+func (m *Memory) commit(ops []op) error {
+	m.seq++
+
+	data, err := json.Marshal(record{Seq: m.seq, Ops: ops})
+	if err != nil {
+		return err
+	}
+
+	return m.log.Append(data)
+}
+
+// Snapshot atomically replaces the snapshot file of a durable
+// Memory with the current state and truncates the log.
+func (m *Memory) Snapshot() error {
+	if m.log == nil {
+		return errors.New("snapshot of non-durable memory storage")
+	}
+
+	// the read lock blocks Update, so no record is appended meanwhile.
+	m.RLock()
+	defer m.RUnlock()
+
+	path := filepath.Join(m.dir, snapshotFile)
+
+	data, err := json.Marshal(snapshot{
+		Seq:          m.seq,
+		Wallets:      m.wallets,
+		Transactions: m.transactions,
+		Journal:      m.journal,
+		Events:       m.events,
+		Holds:        m.holds,
+		Runs:         m.runs,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err = writeFile(tmp, data); err != nil {
+		return fmt.Errorf("snapshot write error: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("snapshot rename error: %w", err)
+	}
+
+	return m.log.Reset()
+}
+
+func (m *Memory) loadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot read error: %w", err)
+	}
+
+	var s snapshot
+	if err = json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("snapshot decode error: %w", err)
+	}
+
+	for id, w := range s.Wallets {
+		m.wallets[id] = w
+	}
+	for _, p := range s.Journal {
+		m.applyPosting(p)
+	}
+	for _, t := range s.Transactions {
+		m.applyTransaction(t)
+	}
+	for _, e := range s.Events {
+		m.applyEvent(e)
+	}
+	for _, h := range s.Holds {
+		m.applyHold(h)
+	}
+	for key, r := range s.Runs {
+		m.runs[key] = r
+	}
+	m.seq = s.Seq
+
+	return nil
+}
+
+// writeFile writes data and fsyncs the file before returning.
+func writeFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// snapshotter takes snapshots of Memory in background.
+type snapshotter struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newSnapshotter(m *Memory, interval time.Duration) *snapshotter {
+	s := &snapshotter{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if err := m.Snapshot(); err != nil {
+					log.Println(err.Error())
+				}
+			}
+		}
+	}()
+
+	return s
+}
+
+func (s *snapshotter) close() {
+	close(s.stop)
+	<-s.done
+}
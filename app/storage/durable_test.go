@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"testing"
+
+	"wallet/app/storage/wal"
+)
+
+func openMemory(t *testing.T, dir string) *Memory {
+	t.Helper()
+	m, err := OpenMemory(DurableOptions{Dir: dir, WAL: wal.Options{Sync: wal.SyncAlways}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func putWallet(t *testing.T, m *Memory, id string) {
+	t.Helper()
+	err := m.Update(func(tx Tx) error {
+		return tx.PutWallet(id, Wallet{Name: id, Status: "active", Currency: "USD"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoverSkippedSeq(t *testing.T) {
+	dir := t.TempDir()
+
+	m := openMemory(t, dir)
+	putWallet(t, m, "a")
+	// a failed commit takes a seq which is never written.
+	m.seq++
+	putWallet(t, m, "b")
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m = openMemory(t, dir)
+	defer m.Close()
+
+	if m.seq != 3 {
+		t.Errorf("seq = %d, want 3", m.seq)
+	}
+	for _, id := range []string{"a", "b"} {
+		if _, found := m.wallets[id]; !found {
+			t.Errorf("wallet %s is not recovered", id)
+		}
+	}
+}
+
+func TestRecoverAfterSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	m := openMemory(t, dir)
+	putWallet(t, m, "a")
+	if err := m.Snapshot(); err != nil {
+		t.Fatal(err)
+	}
+	putWallet(t, m, "b")
+	if err := m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	m = openMemory(t, dir)
+	defer m.Close()
+
+	if m.seq != 2 || len(m.wallets) != 2 {
+		t.Fatalf("recovered seq %d with %d wallets, want 2 and 2", m.seq, len(m.wallets))
+	}
+	// the next commit continues after the recovered seq.
+	putWallet(t, m, "c")
+	if m.seq != 3 {
+		t.Fatalf("seq = %d, want 3", m.seq)
+	}
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+)
+
+// System account names, every system account is kept per currency.
+const (
+	AccountCashIn   = "system:cash-in"
+	AccountCashOut  = "system:cash-out"
+	AccountFees     = "system:fees"
+	AccountFX       = "system:fx"
+	AccountInterest = "system:interest"
+)
+
+// feeWalletPrefix starts the ids of the house fee wallets,
+// generated wallet ids never contain a dash.
+const feeWalletPrefix = "fees-"
+
+// FeeWallet returns the id of the house wallet collecting the fees in
+// the currency.
+func FeeWallet(currency money.Currency) string {
+	return feeWalletPrefix + strings.ToLower(string(currency))
+}
+
+// IsFeeWallet reports whether the id is reserved for a house fee wallet,
+// clients can't change these wallets or move their money out.
+func IsFeeWallet(id string) bool {
+	return strings.HasPrefix(id, feeWalletPrefix)
+}
+
+// Account is a journal account in one currency.
+type Account struct {
+	Name     string
+	Currency money.Currency
+}
+
+// WalletAccount returns the journal account of a wallet.
+func WalletAccount(id string, currency money.Currency) Account {
+	return Account{Name: "wallet:" + id, Currency: currency}
+}
+
+// Entry is one line of a posting, a positive amount increases
+// the account balance and a negative one decreases it.
+type Entry struct {
+	Account Account
+	Amount  money.Amount
+}
+
+// Posting is a balanced set of entries, amounts of its
+// entries sum to zero in every currency.
+type Posting struct {
+	ID        string
+	Entries   []Entry
+	CreatedAt time.Time
+}
+
+// WalletBalance returns the journal balance of a wallet.
+func WalletBalance(tx Tx, id string) (money.Amount, error) {
+	w, found, err := tx.Wallet(id)
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, oops.ErrNotFound
+	}
+
+	return tx.Balance(WalletAccount(id, w.Currency))
+}
+
+// CheckJournal replays the journal and verifies that every currency
+// sums to zero and that stored balances match it.
+func CheckJournal(tx Tx) error {
+	postings, err := tx.Postings()
+	if err != nil {
+		return err
+	}
+
+	balances, err := tx.Balances()
+	if err != nil {
+		return err
+	}
+
+	replayed := make(map[Account]money.Amount, len(balances))
+	for _, p := range postings {
+		if err = Balanced(p.Entries); err != nil {
+			return err
+		}
+		for _, e := range p.Entries {
+			replayed[e.Account] += e.Amount
+		}
+	}
+
+	for account, amount := range balances {
+		if replayed[account] != amount {
+			return oops.ErrUnbalanced
+		}
+	}
+	for account, amount := range replayed {
+		if balances[account] != amount {
+			return oops.ErrUnbalanced
+		}
+	}
+
+	return nil
+}
+
+// Balanced returns an error if entries don't sum to zero in every currency.
+func Balanced(entries []Entry) error {
+	var err error
+	sums := make(map[money.Currency]money.Amount)
+	for _, e := range entries {
+		if sums[e.Account.Currency], err = sums[e.Account.Currency].Add(e.Amount); err != nil {
+			return err
+		}
+	}
+
+	for _, sum := range sums {
+		if sum != 0 {
+			return oops.ErrUnbalanced
+		}
+	}
+
+	return nil
+}
+
+// Posted returns the balances of the accounts of the entries after they
+// are posted, balance returns the current ones. It returns
+// oops.ErrAmountOverflow if a balance is out of the Amount range.
+func Posted(entries []Entry, balance func(Account) (money.Amount, error)) (map[Account]money.Amount, error) {
+	balances := make(map[Account]money.Amount, len(entries))
+	for _, e := range entries {
+		b, found := balances[e.Account]
+		if !found {
+			var err error
+			if b, err = balance(e.Account); err != nil {
+				return nil, err
+			}
+		}
+
+		b, err := b.Add(e.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("account %s balance error: %w", e.Account.Name, err)
+		}
+		balances[e.Account] = b
+	}
+
+	return balances, nil
+}
@@ -1,21 +1,740 @@
-// Package storage is a data storage.
 package storage
 
-// Wallet contains data fields for map.
-type Wallet struct {
-	Name, Status string
-	Balance      float64
-}
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage/wal"
+)
 
-// Memory has map data to store wallets.
+// Memory is an in-memory DB, Update holds an exclusive lock and
+// rolls back its changes if the function returns an error.
+// A Memory opened with OpenMemory is durable, see durable.go.
 type Memory struct {
-	Data map[string]Wallet
+	wallets map[string]Wallet
+	// transactions is an append-only log, ID of a transaction
+	// is its position in the log starting from 1.
+	transactions []Transaction
+	// watermarks holds the latest transaction time up to every
+	// position, it never goes back even if the clock does.
+	watermarks []time.Time
+	// history indexes transactions positions by wallet id and
+	// reversals by the id of the reversed transaction.
+	history   map[string][]int
+	reversals map[string][]int
+	// journal is an append-only double-entry journal and
+	// balances are account balances derived from it.
+	journal  []Posting
+	balances map[Account]money.Amount
+	// events is the outbox, ID of an event is its position
+	// in it starting from 1, pending holds undelivered positions.
+	events  []Event
+	pending []int
+	// dead holds dead-lettered positions.
+	dead []int
+	// sequences holds the last event sequence number and
+	// streams the event positions by aggregate id.
+	sequences map[string]uint64
+	streams   map[string][]int
+	// holds is a log of holds, ID of a hold is its position in it
+	// starting from 1, walletHolds indexes the positions by wallet
+	// and active holds the positions of active holds.
+	holds       []Hold
+	walletHolds map[string][]int
+	active      []int
+	// runs are the completed job runs by key.
+	runs map[string]Run
+
+	// dir, log and seq are set for a durable Memory, seq is the
+	// number of the last log record, a failed commit uses one too.
+	dir       string
+	log       *wal.Log
+	seq       uint64
+	snapshots *snapshotter
+	sync.RWMutex
 }
 
 // NewMemory is a constructor which initiates a new map.
 func NewMemory() *Memory {
-	data := make(map[string]Wallet)
 	return &Memory{
-		Data: data,
+		wallets:     make(map[string]Wallet),
+		history:     make(map[string][]int),
+		reversals:   make(map[string][]int),
+		balances:    make(map[Account]money.Amount),
+		sequences:   make(map[string]uint64),
+		streams:     make(map[string][]int),
+		walletHolds: make(map[string][]int),
+		runs:        make(map[string]Run),
+	}
+}
+
+// View runs fn in a read-only transaction.
+func (m *Memory) View(fn func(Tx) error) error {
+	m.RLock()
+	defer m.RUnlock()
+
+	return fn(&memTx{m: m})
+}
+
+// Update runs fn in a read-write transaction. A durable Memory
+// commits the changes only after they are written to the log.
+func (m *Memory) Update(fn func(Tx) error) error {
+	m.Lock()
+	defer m.Unlock()
+
+	tx := &memTx{m: m, writable: true}
+	if err := fn(tx); err != nil {
+		tx.rollback()
+		return err
+	}
+
+	if m.log != nil && len(tx.ops) > 0 {
+		if err := m.commit(tx.ops); err != nil {
+			tx.rollback()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// op is a change made by a transaction, it is the unit of the
+// write-ahead log and is applied again on recovery.
+type op struct {
+	Wallet      *walletOp    `json:"wallet,omitempty"`
+	Posting     *Posting     `json:"posting,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+	Event       *Event       `json:"event,omitempty"`
+	Delivered   string       `json:"delivered,omitempty"`
+	DeadLetter  *deadOp      `json:"dead_letter,omitempty"`
+	Requeue     string       `json:"requeue,omitempty"`
+	Discard     string       `json:"discard,omitempty"`
+	Hold        *Hold        `json:"hold,omitempty"`
+	Run         *Run         `json:"run,omitempty"`
+}
+
+type deadOp struct {
+	ID, Reason string
+	At         time.Time
+}
+
+type walletOp struct {
+	ID     string
+	Wallet Wallet
+}
+
+func (m *Memory) apply(o op) {
+	switch {
+	case o.Wallet != nil:
+		m.wallets[o.Wallet.ID] = o.Wallet.Wallet
+	case o.Posting != nil:
+		m.applyPosting(*o.Posting)
+	case o.Transaction != nil:
+		m.applyTransaction(*o.Transaction)
+	case o.Event != nil:
+		m.applyEvent(*o.Event)
+	case o.Delivered != "":
+		m.applyDelivered(o.Delivered)
+	case o.DeadLetter != nil:
+		m.applyDeadLetter(*o.DeadLetter)
+	case o.Requeue != "":
+		m.applyRequeue(o.Requeue)
+	case o.Discard != "":
+		m.applyDiscard(o.Discard)
+	case o.Hold != nil:
+		m.applyHold(*o.Hold)
+	case o.Run != nil:
+		m.runs[o.Run.Key] = *o.Run
+	}
+}
+
+func (m *Memory) applyPosting(p Posting) {
+	for _, e := range p.Entries {
+		m.balances[e.Account] += e.Amount
+	}
+	m.journal = append(m.journal, p)
+}
+
+func (m *Memory) applyTransaction(t Transaction) {
+	m.history[t.WalletID] = append(m.history[t.WalletID], len(m.transactions))
+	if t.Reverses != "" {
+		m.reversals[t.Reverses] = append(m.reversals[t.Reverses], len(m.transactions))
+	}
+	m.transactions = append(m.transactions, t)
+
+	mark := t.CreatedAt
+	if n := len(m.watermarks); n > 0 && m.watermarks[n-1].After(mark) {
+		mark = m.watermarks[n-1]
+	}
+	m.watermarks = append(m.watermarks, mark)
+}
+
+func (m *Memory) applyEvent(e Event) {
+	switch {
+	case e.Dead:
+		m.dead = append(m.dead, len(m.events))
+	case !e.Delivered:
+		m.pending = append(m.pending, len(m.events))
+	}
+	m.streams[e.AggregateID] = append(m.streams[e.AggregateID], len(m.events))
+	m.events = append(m.events, e)
+	m.sequences[e.AggregateID] = e.Seq
+}
+
+func (m *Memory) applyDelivered(id string) {
+	pos, _ := strconv.Atoi(id)
+	m.events[pos-1].Delivered = true
+	m.pending = without(m.pending, pos-1)
+}
+
+func (m *Memory) applyDeadLetter(o deadOp) {
+	pos, _ := strconv.Atoi(o.ID)
+	e := &m.events[pos-1]
+	e.Dead, e.Reason, e.DeadAt = true, o.Reason, o.At
+
+	m.pending = without(m.pending, pos-1)
+	m.dead = with(m.dead, pos-1)
+}
+
+func (m *Memory) applyRequeue(id string) {
+	pos, _ := strconv.Atoi(id)
+	e := &m.events[pos-1]
+	e.Dead, e.Reason, e.DeadAt = false, "", time.Time{}
+
+	m.dead = without(m.dead, pos-1)
+	m.pending = with(m.pending, pos-1)
+}
+
+func (m *Memory) applyDiscard(id string) {
+	pos, _ := strconv.Atoi(id)
+	e := &m.events[pos-1]
+	e.Dead, e.Discarded = false, true
+
+	m.dead = without(m.dead, pos-1)
+	m.pending = with(m.pending, pos-1)
+}
+
+// applyHold appends a new hold or replaces a stored one.
+func (m *Memory) applyHold(h Hold) {
+	pos, _ := strconv.Atoi(h.ID)
+	pos--
+
+	if pos == len(m.holds) {
+		m.holds = append(m.holds, h)
+		m.walletHolds[h.WalletID] = append(m.walletHolds[h.WalletID], pos)
+	} else {
+		m.holds[pos] = h
+	}
+
+	if h.Status == HoldActive {
+		if !contains(m.active, pos) {
+			m.active = with(m.active, pos)
+		}
+	} else {
+		m.active = without(m.active, pos)
+	}
+}
+
+// with returns sorted positions with pos added.
+func with(positions []int, pos int) []int {
+	i := sort.SearchInts(positions, pos)
+	positions = append(positions, 0)
+	copy(positions[i+1:], positions[i:])
+	positions[i] = pos
+	return positions
+}
+
+// contains reports whether sorted positions contain pos.
+func contains(positions []int, pos int) bool {
+	i := sort.SearchInts(positions, pos)
+	return i < len(positions) && positions[i] == pos
+}
+
+// without returns a copy of positions without pos.
+func without(positions []int, pos int) []int {
+	for i, p := range positions {
+		if p == pos {
+			return append(positions[:i:i], positions[i+1:]...)
+		}
+	}
+	return positions
+}
+
+// memTx works directly with Memory maps, it keeps an undo log
+// to roll the changes back and the list of ops for the wal.
+type memTx struct {
+	m        *Memory
+	writable bool
+	undo     []func()
+	ops      []op
+}
+
+func (tx *memTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+}
+
+func (tx *memTx) Wallet(id string) (Wallet, bool, error) {
+	w, found := tx.m.wallets[id]
+	return w, found, nil
+}
+
+func (tx *memTx) Wallets() (map[string]Wallet, error) {
+	wallets := make(map[string]Wallet, len(tx.m.wallets))
+	for k, v := range tx.m.wallets {
+		wallets[k] = v
+	}
+	return wallets, nil
+}
+
+func (tx *memTx) PutWallet(id string, w Wallet) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+
+	prev, found := tx.m.wallets[id]
+	tx.undo = append(tx.undo, func() {
+		if found {
+			tx.m.wallets[id] = prev
+			return
+		}
+		delete(tx.m.wallets, id)
+	})
+
+	o := op{Wallet: &walletOp{ID: id, Wallet: w}}
+	tx.m.apply(o)
+	tx.ops = append(tx.ops, o)
+
+	return nil
+}
+
+func (tx *memTx) Post(entries ...Entry) (Posting, error) {
+	if !tx.writable {
+		return Posting{}, ErrReadOnly
+	}
+	if err := Balanced(entries); err != nil {
+		return Posting{}, err
+	}
+	if _, err := Posted(entries, tx.Balance); err != nil {
+		return Posting{}, err
+	}
+
+	n := len(tx.m.journal)
+	p := Posting{
+		ID:        strconv.Itoa(n + 1),
+		Entries:   entries,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	tx.undo = append(tx.undo, func() {
+		for _, e := range entries {
+			tx.m.balances[e.Account] -= e.Amount
+		}
+		tx.m.journal = tx.m.journal[:n]
+	})
+
+	tx.m.applyPosting(p)
+	tx.ops = append(tx.ops, op{Posting: &p})
+
+	return p, nil
+}
+
+func (tx *memTx) Balance(a Account) (money.Amount, error) {
+	return tx.m.balances[a], nil
+}
+
+func (tx *memTx) Balances() (map[Account]money.Amount, error) {
+	balances := make(map[Account]money.Amount, len(tx.m.balances))
+	for k, v := range tx.m.balances {
+		balances[k] = v
+	}
+	return balances, nil
+}
+
+func (tx *memTx) Postings() ([]Posting, error) {
+	return append([]Posting(nil), tx.m.journal...), nil
+}
+
+func (tx *memTx) AppendTransaction(t Transaction) (Transaction, error) {
+	if !tx.writable {
+		return Transaction{}, ErrReadOnly
+	}
+
+	n := len(tx.m.transactions)
+	t.ID = strconv.Itoa(n + 1)
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now().UTC()
+	}
+
+	h, r := len(tx.m.history[t.WalletID]), len(tx.m.reversals[t.Reverses])
+	tx.undo = append(tx.undo, func() {
+		tx.m.history[t.WalletID] = tx.m.history[t.WalletID][:h]
+		if t.Reverses != "" {
+			tx.m.reversals[t.Reverses] = tx.m.reversals[t.Reverses][:r]
+		}
+		tx.m.transactions = tx.m.transactions[:n]
+		tx.m.watermarks = tx.m.watermarks[:n]
+	})
+
+	tx.m.applyTransaction(t)
+	tx.ops = append(tx.ops, op{Transaction: &t})
+
+	return t, nil
+}
+
+func (tx *memTx) Transaction(id string) (Transaction, bool, error) {
+	pos, err := strconv.Atoi(id)
+	if err != nil || pos < 1 || pos > len(tx.m.transactions) {
+		return Transaction{}, false, nil
+	}
+	return tx.m.transactions[pos-1], true, nil
+}
+
+func (tx *memTx) History(walletID string) ([]Transaction, error) {
+	positions := tx.m.history[walletID]
+
+	history := make([]Transaction, 0, len(positions))
+	for _, pos := range positions {
+		history = append(history, tx.m.transactions[pos])
+	}
+	return history, nil
+}
+
+func (tx *memTx) HistoryAfter(walletID, after string, limit int) ([]Transaction, error) {
+	positions := tx.m.history[walletID]
+
+	start := 0
+	if after != "" {
+		pos, err := strconv.Atoi(after)
+		if err != nil || pos < 1 {
+			return nil, fmt.Errorf("unknown transaction %q", after)
+		}
+		// positions are sorted, the transaction id is its position plus one.
+		start = sort.SearchInts(positions, pos)
 	}
+
+	end := len(positions)
+	if end-start > limit {
+		end = start + limit
+	}
+
+	history := make([]Transaction, 0, end-start)
+	for _, pos := range positions[start:end] {
+		history = append(history, tx.m.transactions[pos])
+	}
+	return history, nil
+}
+
+func (tx *memTx) HistoryBefore(walletID string, before time.Time) (Transaction, bool, error) {
+	// n is the number of transactions appended before the log reached the time.
+	n := sort.Search(len(tx.m.watermarks), func(i int) bool {
+		return !tx.m.watermarks[i].Before(before)
+	})
+
+	positions := tx.m.history[walletID]
+	i := sort.SearchInts(positions, n)
+	if i == 0 {
+		return Transaction{}, false, nil
+	}
+	return tx.m.transactions[positions[i-1]], true, nil
+}
+
+func (tx *memTx) Reversals(id string) ([]Transaction, error) {
+	positions := tx.m.reversals[id]
+
+	reversals := make([]Transaction, 0, len(positions))
+	for _, pos := range positions {
+		reversals = append(reversals, tx.m.transactions[pos])
+	}
+	return reversals, nil
+}
+
+func (tx *memTx) AppendHold(h Hold) (Hold, error) {
+	if !tx.writable {
+		return Hold{}, ErrReadOnly
+	}
+
+	n := len(tx.m.holds)
+	h.ID = strconv.Itoa(n + 1)
+	if h.CreatedAt.IsZero() {
+		h.CreatedAt = time.Now().UTC()
+	}
+
+	w := len(tx.m.walletHolds[h.WalletID])
+	active := append([]int(nil), tx.m.active...)
+	tx.undo = append(tx.undo, func() {
+		tx.m.walletHolds[h.WalletID] = tx.m.walletHolds[h.WalletID][:w]
+		tx.m.holds = tx.m.holds[:n]
+		tx.m.active = active
+	})
+
+	tx.m.applyHold(h)
+	tx.ops = append(tx.ops, op{Hold: &h})
+
+	return h, nil
+}
+
+func (tx *memTx) PutHold(h Hold) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+
+	pos, err := strconv.Atoi(h.ID)
+	if err != nil || pos < 1 || pos > len(tx.m.holds) || tx.m.holds[pos-1].WalletID != h.WalletID {
+		return fmt.Errorf("unknown hold %q", h.ID)
+	}
+
+	prev := tx.m.holds[pos-1]
+	active := append([]int(nil), tx.m.active...)
+	tx.undo = append(tx.undo, func() {
+		tx.m.holds[pos-1] = prev
+		tx.m.active = active
+	})
+
+	tx.m.applyHold(h)
+	tx.ops = append(tx.ops, op{Hold: &h})
+
+	return nil
+}
+
+func (tx *memTx) Hold(id string) (Hold, bool, error) {
+	pos, err := strconv.Atoi(id)
+	if err != nil || pos < 1 || pos > len(tx.m.holds) {
+		return Hold{}, false, nil
+	}
+	return tx.m.holds[pos-1], true, nil
+}
+
+func (tx *memTx) Holds(walletID string) ([]Hold, error) {
+	return tx.m.holdsAt(tx.m.walletHolds[walletID]), nil
+}
+
+func (tx *memTx) ActiveHolds() ([]Hold, error) {
+	return tx.m.holdsAt(tx.m.active), nil
+}
+
+func (m *Memory) holdsAt(positions []int) []Hold {
+	holds := make([]Hold, 0, len(positions))
+	for _, pos := range positions {
+		holds = append(holds, m.holds[pos])
+	}
+	return holds
+}
+
+func (tx *memTx) Run(key string) (Run, bool, error) {
+	r, found := tx.m.runs[key]
+	return r, found, nil
+}
+
+func (tx *memTx) PutRun(r Run) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+
+	prev, found := tx.m.runs[r.Key]
+	tx.undo = append(tx.undo, func() {
+		if found {
+			tx.m.runs[r.Key] = prev
+			return
+		}
+		delete(tx.m.runs, r.Key)
+	})
+
+	o := op{Run: &r}
+	tx.m.apply(o)
+	tx.ops = append(tx.ops, o)
+
+	return nil
+}
+
+func (tx *memTx) AppendEvent(e Event) (Event, error) {
+	if !tx.writable {
+		return Event{}, ErrReadOnly
+	}
+
+	n := len(tx.m.events)
+	e.ID = strconv.Itoa(n + 1)
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	e.Delivered = false
+
+	prev, found := tx.m.sequences[e.AggregateID]
+	e.Seq = prev + 1
+
+	p := len(tx.m.pending)
+	s := len(tx.m.streams[e.AggregateID])
+	tx.undo = append(tx.undo, func() {
+		if found {
+			tx.m.sequences[e.AggregateID] = prev
+			tx.m.streams[e.AggregateID] = tx.m.streams[e.AggregateID][:s]
+		} else {
+			delete(tx.m.sequences, e.AggregateID)
+			delete(tx.m.streams, e.AggregateID)
+		}
+		tx.m.pending = tx.m.pending[:p]
+		tx.m.events = tx.m.events[:n]
+	})
+
+	tx.m.applyEvent(e)
+	tx.ops = append(tx.ops, op{Event: &e})
+
+	return e, nil
+}
+
+func (tx *memTx) Events(after string, limit int) ([]Event, error) {
+	start := 0
+	if after != "" {
+		pos, err := strconv.Atoi(after)
+		if err != nil || pos < 1 {
+			return nil, fmt.Errorf("unknown event %q", after)
+		}
+		start = pos
+	}
+
+	if start > len(tx.m.events) {
+		start = len(tx.m.events)
+	}
+	end := len(tx.m.events)
+	if end-start > limit {
+		end = start + limit
+	}
+
+	return append([]Event(nil), tx.m.events[start:end]...), nil
+}
+
+func (tx *memTx) Stream(aggregateID string) ([]Event, error) {
+	positions := tx.m.streams[aggregateID]
+
+	events := make([]Event, 0, len(positions))
+	for _, pos := range positions {
+		events = append(events, tx.m.events[pos])
+	}
+	return events, nil
+}
+
+func (tx *memTx) PendingEvents(limit int, skip func(Event) bool) ([]Event, error) {
+	var events []Event
+	for _, pos := range tx.m.pending {
+		if len(events) == limit {
+			break
+		}
+
+		e := tx.m.events[pos]
+		if skip == nil || !skip(e) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (tx *memTx) MarkDelivered(id string) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+
+	pos, err := strconv.Atoi(id)
+	if err != nil || pos < 1 || pos > len(tx.m.events) {
+		return fmt.Errorf("unknown event %q", id)
+	}
+	if tx.m.events[pos-1].Delivered {
+		return nil
+	}
+
+	pending := append([]int(nil), tx.m.pending...)
+	tx.undo = append(tx.undo, func() {
+		tx.m.events[pos-1].Delivered = false
+		tx.m.pending = pending
+	})
+
+	tx.m.applyDelivered(id)
+	tx.ops = append(tx.ops, op{Delivered: id})
+
+	return nil
+}
+
+func (tx *memTx) DeadLetter(id, reason string) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+
+	pos, err := strconv.Atoi(id)
+	if err != nil || !contains(tx.m.pending, pos-1) {
+		return fmt.Errorf("event %q is not pending", id)
+	}
+
+	tx.saveEvent(pos - 1)
+
+	o := deadOp{ID: id, Reason: reason, At: time.Now().UTC()}
+	tx.m.applyDeadLetter(o)
+	tx.ops = append(tx.ops, op{DeadLetter: &o})
+
+	return nil
+}
+
+func (tx *memTx) DeadLetters() ([]Event, error) {
+	events := make([]Event, 0, len(tx.m.dead))
+	for _, pos := range tx.m.dead {
+		events = append(events, tx.m.events[pos])
+	}
+	return events, nil
+}
+
+func (tx *memTx) Requeue(id string) error {
+	pos, err := tx.deadPosition(id)
+	if err != nil {
+		return err
+	}
+
+	tx.saveEvent(pos)
+	tx.m.applyRequeue(id)
+	tx.ops = append(tx.ops, op{Requeue: id})
+
+	return nil
+}
+
+func (tx *memTx) Discard(id string) error {
+	pos, err := tx.deadPosition(id)
+	if err != nil {
+		return err
+	}
+
+	tx.saveEvent(pos)
+	tx.m.applyDiscard(id)
+	tx.ops = append(tx.ops, op{Discard: id})
+
+	return nil
+}
+
+// deadPosition returns the position of a dead-lettered event.
+func (tx *memTx) deadPosition(id string) (int, error) {
+	if !tx.writable {
+		return 0, ErrReadOnly
+	}
+
+	pos, err := strconv.Atoi(id)
+	if err != nil || pos < 1 || pos > len(tx.m.events) || !tx.m.events[pos-1].Dead {
+		return 0, oops.ErrNotFound
+	}
+
+	return pos - 1, nil
+}
+
+// saveEvent adds undo of a change to the event at pos and the outbox queues.
+func (tx *memTx) saveEvent(pos int) {
+	e := tx.m.events[pos]
+	pending := append([]int(nil), tx.m.pending...)
+	dead := append([]int(nil), tx.m.dead...)
+
+	tx.undo = append(tx.undo, func() {
+		tx.m.events[pos] = e
+		tx.m.pending = pending
+		tx.m.dead = dead
+	})
 }
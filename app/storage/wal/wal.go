@@ -0,0 +1,311 @@
+// Package wal contains an append-only write-ahead log file
+// with checksummed records.
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sync policies.
+const (
+	// SyncAlways fsyncs the file after every record.
+	SyncAlways = "always"
+	// SyncBatch fsyncs the file after every BatchSize records.
+	SyncBatch = "batch"
+	// SyncInterval fsyncs the file in background every Interval.
+	SyncInterval = "interval"
+)
+
+// headerSize is the record header: payload length and its CRC-32C.
+const headerSize = 8
+
+// ErrCorrupted is returned by Replay for a damaged record
+// which is not the last one in the file.
+var ErrCorrupted = errors.New("wal corrupted")
+
+var table = crc32.MakeTable(crc32.Castagnoli)
+
+// Options contains the fsync policy of the log.
+type Options struct {
+	Sync      string
+	BatchSize int
+	Interval  time.Duration
+}
+
+// Log is a write-ahead log file, broken is set when a failed
+// record couldn't be removed and no record can follow it.
+type Log struct {
+	file     *os.File
+	opts     Options
+	unsynced int
+	broken   error
+	stop     chan struct{}
+	done     chan struct{}
+	sync.Mutex
+}
+
+// Open opens or creates the log file.
+func Open(path string, opts Options) (*Log, error) {
+	switch opts.Sync {
+	case SyncAlways:
+	case SyncBatch:
+		if opts.BatchSize <= 0 {
+			return nil, fmt.Errorf("wal batch size must be positive")
+		}
+	case SyncInterval:
+		if opts.Interval <= 0 {
+			return nil, fmt.Errorf("wal sync interval must be positive")
+		}
+	default:
+		return nil, fmt.Errorf("unknown wal sync policy %q", opts.Sync)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("wal open error: %w", err)
+	}
+
+	l := &Log{
+		file: file,
+		opts: opts,
+	}
+
+	if opts.Sync == SyncInterval {
+		l.stop = make(chan struct{})
+		l.done = make(chan struct{})
+		go l.syncLoop()
+	}
+
+	return l, nil
+}
+
+// Replay calls fn for every record in order. A torn last record left by
+// a crash during a write is truncated, a damaged record followed by a
+// valid one is reported as ErrCorrupted. After Replay the log is ready
+// for Append.
+func (l *Log) Replay(fn func([]byte) error) error {
+	l.Lock()
+	defer l.Unlock()
+
+	info, err := l.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	if _, err = l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	var offset int64
+	for offset < size {
+		payload, err := l.read(size - offset)
+		if err != nil {
+			// the length of a damaged record can't tell whether it reaches
+			// the end of the file, so it is torn only if no record follows.
+			if next, found := l.next(offset+1, size); found {
+				return fmt.Errorf("%w: record at offset %d before the one at %d: %s", ErrCorrupted, offset, next, err.Error())
+			}
+
+			log.Printf("wal: truncating torn record at offset %d: %s", offset, err.Error())
+			if err = l.file.Truncate(offset); err != nil {
+				return err
+			}
+			break
+		}
+
+		if err = fn(payload); err != nil {
+			return err
+		}
+		offset += headerSize + int64(len(payload))
+	}
+
+	_, err = l.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// read reads one record at the current file offset and returns its payload.
+func (l *Log) read(remaining int64) ([]byte, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(l.file, header); err != nil {
+		return nil, err
+	}
+
+	payload, err := checkHeader(header, remaining)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = io.ReadFull(l.file, payload); err != nil {
+		return nil, err
+	}
+
+	if crc32.Checksum(payload, table) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, errors.New("checksum mismatch")
+	}
+
+	return payload, nil
+}
+
+// next returns the offset of the first valid record from the offset on.
+func (l *Log) next(offset, size int64) (int64, bool) {
+	header := make([]byte, headerSize)
+	for ; offset+headerSize < size; offset++ {
+		if _, err := l.file.ReadAt(header, offset); err != nil {
+			return 0, false
+		}
+
+		payload, err := checkHeader(header, size-offset)
+		if err != nil {
+			continue
+		}
+		if _, err = l.file.ReadAt(payload, offset+headerSize); err != nil {
+			continue
+		}
+		if crc32.Checksum(payload, table) == binary.BigEndian.Uint32(header[4:]) {
+			return offset, true
+		}
+	}
+
+	return 0, false
+}
+
+// checkHeader returns the buffer of the payload if its length fits into
+// the remaining size. Records are never empty, so a zeroed tail left by
+// a crash doesn't read as records.
+func checkHeader(header []byte, remaining int64) ([]byte, error) {
+	length := int64(binary.BigEndian.Uint32(header[:4]))
+	if length == 0 {
+		return nil, errors.New("empty record")
+	}
+	if length > remaining-headerSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return make([]byte, length), nil
+}
+
+// Append writes a record and syncs the file according to the policy.
+// A record which fails to be written or synced is removed, so the
+// failed transaction is never replayed.
+func (l *Log) Append(payload []byte) error {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.broken != nil {
+		return l.broken
+	}
+	if len(payload) == 0 {
+		return errors.New("wal write error: empty record")
+	}
+
+	offset, err := l.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("wal write error: %w", err)
+	}
+
+	record := make([]byte, headerSize+len(payload))
+	binary.BigEndian.PutUint32(record[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, table))
+	copy(record[headerSize:], payload)
+
+	if _, err = l.file.Write(record); err != nil {
+		return l.rollback(offset, fmt.Errorf("wal write error: %w", err))
+	}
+	l.unsynced++
+
+	if l.opts.Sync == SyncAlways || (l.opts.Sync == SyncBatch && l.unsynced >= l.opts.BatchSize) {
+		if err = l.sync(); err != nil {
+			return l.rollback(offset, err)
+		}
+	}
+
+	return nil
+}
+
+// rollback truncates the log to the offset of a failed record and
+// returns its error. The log is broken if the record stays in it.
+func (l *Log) rollback(offset int64, cause error) error {
+	err := l.file.Truncate(offset)
+	if err == nil {
+		_, err = l.file.Seek(offset, io.SeekStart)
+	}
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		l.broken = fmt.Errorf("wal broken: record at offset %d not removed: %s: %w", offset, err.Error(), cause)
+		return l.broken
+	}
+
+	return cause
+}
+
+// Reset truncates the log after its records were saved in a snapshot.
+func (l *Log) Reset() error {
+	l.Lock()
+	defer l.Unlock()
+
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	return l.sync()
+}
+
+// Close syncs and closes the file.
+func (l *Log) Close() error {
+	if l.stop != nil {
+		close(l.stop)
+		<-l.done
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if err := l.sync(); err != nil {
+		return err
+	}
+	return l.file.Close()
+}
+
+func (l *Log) sync() error {
+	if err := l.file.Sync(); err != nil {
+		return fmt.Errorf("wal sync error: %w", err)
+	}
+	l.unsynced = 0
+	return nil
+}
+
+func (l *Log) syncLoop() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			l.Lock()
+			if l.unsynced > 0 {
+				if err := l.sync(); err != nil {
+					log.Println(err.Error())
+				}
+			}
+			l.Unlock()
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package wal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// write creates a log file with the records and returns its path
+// and the offsets where the records start.
+func write(t *testing.T, records ...string) (string, []int64) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wal.log")
+
+	l, err := Open(path, Options{Sync: SyncAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offsets []int64
+	var offset int64
+	for _, r := range records {
+		offsets = append(offsets, offset)
+		if err = l.Append([]byte(r)); err != nil {
+			t.Fatal(err)
+		}
+		offset += headerSize + int64(len(r))
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, offsets
+}
+
+func replay(path string) ([]string, error) {
+	l, err := Open(path, Options{Sync: SyncAlways})
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	var got []string
+	err = l.Replay(func(p []byte) error {
+		got = append(got, string(p))
+		return nil
+	})
+	return got, err
+}
+
+func TestReplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		damage   func(t *testing.T, f *os.File, offsets []int64, size int64)
+		want     []string
+		wantErr  error
+		wantSize func(offsets []int64, size int64) int64
+	}{
+		{
+			name:     "intact",
+			damage:   func(*testing.T, *os.File, []int64, int64) {},
+			want:     []string{"one", "two", "three"},
+			wantSize: func(_ []int64, size int64) int64 { return size },
+		},
+		{
+			name: "torn payload",
+			damage: func(t *testing.T, f *os.File, _ []int64, size int64) {
+				truncate(t, f, size-2)
+			},
+			want:     []string{"one", "two"},
+			wantSize: func(offsets []int64, _ int64) int64 { return offsets[2] },
+		},
+		{
+			name: "torn header",
+			damage: func(t *testing.T, f *os.File, offsets []int64, _ int64) {
+				truncate(t, f, offsets[2]+3)
+			},
+			want:     []string{"one", "two"},
+			wantSize: func(offsets []int64, _ int64) int64 { return offsets[2] },
+		},
+		{
+			name: "zeroed tail",
+			damage: func(t *testing.T, f *os.File, offsets []int64, size int64) {
+				writeAt(t, f, make([]byte, size-offsets[2]), offsets[2])
+			},
+			want:     []string{"one", "two"},
+			wantSize: func(offsets []int64, _ int64) int64 { return offsets[2] },
+		},
+		{
+			name: "damaged last payload",
+			damage: func(t *testing.T, f *os.File, _ []int64, size int64) {
+				writeAt(t, f, []byte("X"), size-1)
+			},
+			want:     []string{"one", "two"},
+			wantSize: func(offsets []int64, _ int64) int64 { return offsets[2] },
+		},
+		{
+			name: "damaged middle payload",
+			damage: func(t *testing.T, f *os.File, offsets []int64, _ int64) {
+				writeAt(t, f, []byte("X"), offsets[1]+headerSize)
+			},
+			want:    []string{"one"},
+			wantErr: ErrCorrupted,
+		},
+		{
+			name: "length past the end",
+			damage: func(t *testing.T, f *os.File, offsets []int64, _ int64) {
+				writeAt(t, f, []byte{0x7f, 0, 0, 0}, offsets[1])
+			},
+			want:    []string{"one"},
+			wantErr: ErrCorrupted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, offsets := write(t, "one", "two", "three")
+
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.damage(t, f, offsets, size(t, path))
+			f.Close()
+			before := size(t, path)
+
+			got, err := replay(path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Replay error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Replay records = %q, want %q", got, tt.want)
+			}
+
+			want := before
+			if tt.wantSize != nil {
+				want = tt.wantSize(offsets, before)
+			}
+			if after := size(t, path); after != want {
+				t.Errorf("size after Replay = %d, want %d", after, want)
+			}
+		})
+	}
+}
+
+func TestAppendAfterReplay(t *testing.T) {
+	path, _ := write(t, "one", "two")
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncate(t, f, size(t, path)-1)
+	f.Close()
+
+	l, err := Open(path, Options{Sync: SyncAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Replay(func([]byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Append([]byte("three")); err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+
+	got, err := replay(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"one", "three"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("records = %q, want %q", got, want)
+	}
+}
+
+func TestAppendRejectsEmpty(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "wal.log"), Options{Sync: SyncAlways})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err = l.Append(nil); err == nil {
+		t.Fatal("empty record appended")
+	}
+}
+
+func truncate(t *testing.T, f *os.File, size int64) {
+	t.Helper()
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeAt(t *testing.T, f *os.File, b []byte, offset int64) {
+	t.Helper()
+	if _, err := f.WriteAt(b, offset); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func size(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Size()
+}
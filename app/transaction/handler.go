@@ -0,0 +1,94 @@
+package transaction
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wallet/app/oops"
+	"wallet/app/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler contains HistoryService and a router.
+type Handler struct {
+	router  *chi.Mux
+	history HistoryService
+}
+
+// NewHandler is a constructor which accepts HistoryService and
+// returns a pointer to the Handler.
+func NewHandler(router *chi.Mux, service HistoryService) *Handler {
+	return &Handler{
+		router:  router,
+		history: service,
+	}
+}
+
+// Register transaction routes.
+func (h *Handler) Register() {
+	h.router.Group(func(r chi.Router) {
+		r.Get("/wallets/{id}/transactions", h.list)
+	})
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadReqMessage, "")
+		return
+	}
+
+	filter, err := parseFilter(r)
+	if err != nil {
+		response.WalletError(w, http.StatusBadRequest, oops.ErrBadFilterMessage, id)
+		return
+	}
+
+	page, err := h.history.List(r.Context(), id, filter)
+	if err != nil {
+		switch {
+		case errors.Is(err, oops.ErrNotFound):
+			response.WalletError(w, http.StatusNotFound, oops.ErrNotFoundMessage, id)
+		case errors.Is(err, oops.ErrBadFilter):
+			response.WalletError(w, http.StatusBadRequest, oops.ErrBadFilterMessage, id)
+		default:
+			response.WalletError(w, http.StatusInternalServerError, oops.ErrIntServMessage, id)
+		}
+		return
+	}
+
+	response.Data(w, http.StatusOK, page)
+}
+
+// parseFilter reads cursor, limit, type, from and to query parameters,
+// dates are in RFC 3339 format.
+func parseFilter(r *http.Request) (Filter, error) {
+	q := r.URL.Query()
+
+	f := Filter{
+		Cursor: q.Get("cursor"),
+		Type:   q.Get("type"),
+	}
+
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			return Filter{}, err
+		}
+	}
+	if v := q.Get("from"); v != "" {
+		if f.From, err = time.Parse(time.RFC3339, v); err != nil {
+			return Filter{}, err
+		}
+	}
+	if v := q.Get("to"); v != "" {
+		if f.To, err = time.Parse(time.RFC3339, v); err != nil {
+			return Filter{}, err
+		}
+	}
+
+	return f, nil
+}
@@ -0,0 +1,52 @@
+// Package transaction has a business logic for wallet transaction history.
+package transaction
+
+import (
+	"context"
+
+	"wallet/app/oops"
+	"wallet/app/storage"
+)
+
+const (
+	// DefaultLimit is a page size when the client doesn't set one.
+	DefaultLimit = 50
+	// MaxLimit is the largest allowed page size.
+	MaxLimit = 500
+)
+
+// HistoryService contains Store interface.
+type HistoryService struct {
+	store Store
+}
+
+// NewHistoryService is a HistoryService constructor.
+func NewHistoryService(store Store) *HistoryService {
+	return &HistoryService{
+		store: store,
+	}
+}
+
+// List returns a page of wallet transactions.
+func (s *HistoryService) List(ctx context.Context, id string, f Filter) (Page, error) {
+	if f.Limit == 0 {
+		f.Limit = DefaultLimit
+	}
+	if f.Limit < 0 || f.Limit > MaxLimit {
+		return Page{}, oops.ErrBadFilter
+	}
+
+	switch f.Type {
+	case "", storage.TxDeposit, storage.TxWithdrawal, storage.TxTransferOut, storage.TxTransferIn, storage.TxCapture,
+		storage.TxReversalOut, storage.TxReversalIn, storage.TxFee, storage.TxFeeIncome,
+		storage.TxOverdraftInterest, storage.TxInterest:
+	default:
+		return Page{}, oops.ErrBadFilter
+	}
+
+	if !f.From.IsZero() && !f.To.IsZero() && f.To.Before(f.From) {
+		return Page{}, oops.ErrBadFilter
+	}
+
+	return s.store.Transactions(ctx, id, f)
+}
@@ -0,0 +1,99 @@
+// Package store contains all implementation to read
+// wallet transactions from storage.DB.
+package store
+
+import (
+	"context"
+
+	"wallet/app/oops"
+	"wallet/app/storage"
+	"wallet/app/transaction"
+)
+
+// Storage works with transactions in storage.DB.
+type Storage struct {
+	db storage.DB
+}
+
+// NewStorage is a constructor for storage.
+func NewStorage(db storage.DB) *Storage {
+	return &Storage{
+		db: db,
+	}
+}
+
+// Transactions returns a page of wallet transactions, newest first.
+func (s *Storage) Transactions(ctx context.Context, id string, f transaction.Filter) (transaction.Page, error) {
+	page := transaction.Page{
+		Transactions: make([]transaction.Transaction, 0, f.Limit),
+	}
+
+	err := s.db.View(func(tx storage.Tx) error {
+		_, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return oops.ErrNotFound
+		}
+
+		history, err := tx.History(id)
+		if err != nil {
+			return err
+		}
+
+		// start right before the cursor position.
+		end := len(history)
+		if f.Cursor != "" {
+			for end > 0 && history[end-1].ID != f.Cursor {
+				end--
+			}
+			if end == 0 {
+				return oops.ErrBadFilter
+			}
+			end--
+		}
+
+		for i := end - 1; i >= 0; i-- {
+			t := history[i]
+			if !match(t, f) {
+				continue
+			}
+
+			if len(page.Transactions) == f.Limit {
+				page.NextCursor = page.Transactions[f.Limit-1].ID
+				break
+			}
+
+			page.Transactions = append(page.Transactions, transaction.Transaction{
+				ID:           t.ID,
+				WalletID:     t.WalletID,
+				Type:         t.Type,
+				Amount:       t.Amount,
+				Currency:     t.Currency,
+				Counterparty: t.Counterparty,
+				Rate:         t.Rate,
+				Reverses:     t.Reverses,
+				BalanceAfter: t.BalanceAfter,
+				CreatedAt:    t.CreatedAt,
+			})
+		}
+
+		return nil
+	})
+
+	return page, err
+}
+
+func match(t storage.Transaction, f transaction.Filter) bool {
+	if f.Type != "" && t.Type != f.Type {
+		return false
+	}
+	if !f.From.IsZero() && t.CreatedAt.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && t.CreatedAt.After(f.To) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package transaction
+
+import (
+	"context"
+	"time"
+
+	"wallet/app/fx"
+	"wallet/app/money"
+)
+
+// Transaction is an immutable record of a wallet balance change,
+// Reverses is the id of the transaction reversed by a reversal.
+type Transaction struct {
+	ID           string         `json:"id"`
+	WalletID     string         `json:"wallet_id"`
+	Type         string         `json:"type"`
+	Amount       money.Amount   `json:"amount"`
+	Currency     money.Currency `json:"currency"`
+	Counterparty string         `json:"counterparty,omitempty"`
+	Rate         fx.Rate        `json:"rate,omitempty"`
+	Reverses     string         `json:"reverses,omitempty"`
+	BalanceAfter money.Amount   `json:"balance_after"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// Filter selects a page of wallet transactions, newest first.
+// Cursor is the id of the last transaction of the previous page,
+// zero From and To mean an open date range.
+type Filter struct {
+	Cursor   string
+	Limit    int
+	Type     string
+	From, To time.Time
+}
+
+// Page contains transactions and a cursor for the next page,
+// NextCursor is empty on the last page.
+type Page struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+}
+
+// Store contains all methods to read transactions from the storage.
+type Store interface {
+	Transactions(context.Context, string, Filter) (Page, error)
+}
+
+// Service contains all methods from transaction service.
+type Service interface {
+	List(context.Context, string, Filter) (Page, error)
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"time"
 
 	"wallet/app/oops"
 	"wallet/app/response"
@@ -52,6 +53,8 @@ func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
 	response.Data(w, http.StatusOK, data)
 }
 
+// item reads an optional as_of query parameter in RFC 3339 format
+// to return the wallet as it was at that instant.
 func (h *Handler) item(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
 	if id == "" {
@@ -59,13 +62,23 @@ func (h *Handler) item(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := h.wallet.Item(r.Context(), id)
-	if err != nil {
-		if errors.Is(err, oops.ErrNotFound) {
-			response.WalletError(w, http.StatusNotFound, oops.ErrNotFoundMessage, id)
+	var (
+		data Wallet
+		err  error
+	)
+	if asOf := r.URL.Query().Get("as_of"); asOf != "" {
+		at, perr := time.Parse(time.RFC3339, asOf)
+		if perr != nil {
+			response.WalletError(w, http.StatusBadRequest, oops.ErrBadFilterMessage, id)
 			return
 		}
-		response.WalletError(w, http.StatusInternalServerError, oops.ErrIntServMessage, id)
+		data, err = h.wallet.ItemAt(r.Context(), id, at)
+	} else {
+		data, err = h.wallet.Item(r.Context(), id)
+	}
+	if err != nil {
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
 		return
 	}
 
@@ -82,7 +95,8 @@ func (h *Handler) create(w http.ResponseWriter, r *http.Request) {
 
 	data, err := h.wallet.Create(r.Context(), requestBody)
 	if err != nil {
-		response.WalletError(w, http.StatusInternalServerError, oops.ErrIntServMessage, "")
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, "")
 		return
 	}
 
@@ -109,7 +123,8 @@ func (h *Handler) update(w http.ResponseWriter, r *http.Request) {
 
 	err = h.wallet.Update(r.Context(), requestBody, id)
 	if err != nil {
-		response.WalletError(w, http.StatusInternalServerError, oops.ErrIntServMessage, id)
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
 		return
 	}
 
@@ -125,9 +140,24 @@ func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
 
 	err := h.wallet.Delete(r.Context(), id)
 	if err != nil {
-		response.WalletError(w, http.StatusInternalServerError, oops.ErrIntServMessage, id)
+		status, msg := errorStatus(err)
+		response.WalletError(w, status, msg, id)
 		return
 	}
 
 	response.Data(w, http.StatusOK, id)
 }
+
+// errorStatus maps an error from the service to HTTP status and error message.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, oops.ErrNotFound):
+		return http.StatusNotFound, oops.ErrNotFoundMessage
+	case errors.Is(err, oops.ErrInvalidCurrency):
+		return http.StatusBadRequest, oops.ErrInvalidCurrencyMessage
+	case errors.Is(err, oops.ErrReservedWallet):
+		return http.StatusForbidden, oops.ErrReservedWalletMessage
+	default:
+		return http.StatusInternalServerError, oops.ErrIntServMessage
+	}
+}
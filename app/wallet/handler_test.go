@@ -0,0 +1,78 @@
+package wallet_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage"
+	"wallet/app/wallet"
+	"wallet/app/wallet/store"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newRouter serves the wallet routes on a memory storage.
+func newRouter(t *testing.T) (*chi.Mux, *wallet.AppService) {
+	t.Helper()
+	router := chi.NewRouter()
+	service := wallet.NewAppService(store.NewStorage(storage.NewMemory()))
+	wallet.NewHandler(router, *service).Register()
+	return router, service
+}
+
+// serve sends the request and returns the status and the error code.
+func serve(t *testing.T, router http.Handler, method, path, body string) (int, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+	var res struct {
+		ErrCode string `json:"err_code"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&res); err != nil {
+		t.Fatalf("%s %s: response decode error: %v", method, path, err)
+	}
+	return rec.Code, res.ErrCode
+}
+
+func TestHandlerErrors(t *testing.T) {
+	router, service := newRouter(t)
+	deleted, err := service.Create(context.Background(), wallet.Request{Name: "deleted", Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = service.Delete(context.Background(), deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err = service.OpenFeeWallets(context.Background(), []money.Currency{"USD"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name, method, path, body string
+		status                   int
+		code                     string
+	}{
+		{"update missing", http.MethodPut, "/wallets/missing", `{"name":"x"}`, http.StatusNotFound, oops.ErrNotFoundMessage},
+		{"update deleted", http.MethodPut, "/wallets/" + deleted.ID, `{"name":"x"}`, http.StatusNotFound, oops.ErrNotFoundMessage},
+		{"delete missing", http.MethodDelete, "/wallet/missing", "", http.StatusNotFound, oops.ErrNotFoundMessage},
+		{"delete deleted", http.MethodDelete, "/wallet/" + deleted.ID, "", http.StatusNotFound, oops.ErrNotFoundMessage},
+		{"item missing", http.MethodGet, "/wallets/missing", "", http.StatusNotFound, oops.ErrNotFoundMessage},
+		{"update fee wallet", http.MethodPut, "/wallets/fees-usd", `{"name":"x"}`, http.StatusForbidden, oops.ErrReservedWalletMessage},
+		{"delete fee wallet", http.MethodDelete, "/wallet/fees-usd", "", http.StatusForbidden, oops.ErrReservedWalletMessage},
+		{"create invalid currency", http.MethodPost, "/wallet", `{"name":"x","currency":"XYZ"}`, http.StatusBadRequest, oops.ErrInvalidCurrencyMessage},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			status, code := serve(t, router, test.method, test.path, test.body)
+			if status != test.status || code != test.code {
+				t.Errorf("got %d %q, want %d %q", status, code, test.status, test.code)
+			}
+		})
+	}
+}
@@ -3,22 +3,24 @@ package wallet
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"time"
 
-	"wallet/app/queue"
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage"
 )
 
-// AppService contains Store interface.
+// AppService contains Store interface. Queue events are recorded
+// by the Store in the outbox and published by outbox.Relay.
 type AppService struct {
-	store    Store
-	producer queue.Service
+	store Store
 }
 
 // NewAppService is a Service constructor.
-func NewAppService(store Store, producer queue.Service) *AppService {
+func NewAppService(store Store) *AppService {
 	return &AppService{
-		store:    store,
-		producer: producer,
+		store: store,
 	}
 }
 
@@ -46,35 +48,54 @@ func (s *AppService) Item(ctx context.Context, id string) (Wallet, error) {
 	return wallet, nil
 }
 
+// ItemAt returns a wallet with the balance and status
+// it had at the instant.
+func (s *AppService) ItemAt(ctx context.Context, id string, at time.Time) (Wallet, error) {
+	return s.store.WalletAt(ctx, id, at)
+}
+
 // Create saves a new wallet into the storage.
 func (s *AppService) Create(ctx context.Context, req Request) (Wallet, error) {
-	wallet, err := s.store.CreateWallet(ctx, req)
+	currency, err := money.ParseCurrency(req.Currency)
 	if err != nil {
 		return Wallet{}, err
 	}
+	req.Currency = string(currency)
 
-	// make a channel to catch an error from the goroutine.
-	errCh := make(chan error, 1)
-	defer close(errCh)
-
-	// publish message to the queue.
-	go s.producer.Wallet("Wallet_Created", errCh)
-
-	// catch error from the channel.
-	err = <-errCh
+	wallet, err := s.store.CreateWallet(ctx, req)
 	if err != nil {
-		log.Printf("queue.Publish error: %s", err.Error())
+		return Wallet{}, err
 	}
 
 	return Wallet{
-		ID:     wallet.ID,
-		Name:   wallet.Name,
-		Status: wallet.Status,
+		ID:       wallet.ID,
+		Name:     wallet.Name,
+		Currency: wallet.Currency,
+		Status:   wallet.Status,
 	}, nil
 }
 
-// Update updates the name in the storage.
+// OpenFeeWallets creates the house fee wallet of every currency unless
+// it exists, an existing one must be active and in its currency.
+func (s *AppService) OpenFeeWallets(ctx context.Context, currencies []money.Currency) error {
+	for _, c := range currencies {
+		id := storage.FeeWallet(c)
+		err := s.store.OpenWallet(ctx, id, Request{Name: "house fees " + string(c), Currency: string(c)})
+		if err != nil {
+			return fmt.Errorf("fee wallet %s error: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
+// Update updates the name in the storage, a house fee wallet can't be
+// renamed.
 func (s *AppService) Update(ctx context.Context, req Request, id string) error {
+	if storage.IsFeeWallet(id) {
+		return oops.ErrReservedWallet
+	}
+
 	err := s.store.UpdateWallet(ctx, req, id)
 	if err != nil {
 		return err
@@ -83,24 +104,16 @@ func (s *AppService) Update(ctx context.Context, req Request, id string) error {
 	return nil
 }
 
-// Delete removes wallet from the storage.
+// Delete removes wallet from the storage, a house fee wallet can't be
+// deleted.
 func (s *AppService) Delete(ctx context.Context, id string) error {
-	err := s.store.DeleteWallet(ctx, id)
-	if err != nil {
-		return err
+	if storage.IsFeeWallet(id) {
+		return oops.ErrReservedWallet
 	}
 
-	// make a channel to catch an error from the goroutine.
-	errCh := make(chan error, 1)
-	defer close(errCh)
-
-	// publish message to the queue.
-	go s.producer.Wallet("Wallet_Deleted", errCh)
-
-	// catch error from the channel.
-	err = <-errCh
+	err := s.store.DeleteWallet(ctx, id)
 	if err != nil {
-		log.Printf("queue.Publish error: %s", err)
+		return err
 	}
 
 	return nil
@@ -0,0 +1,399 @@
+// Package store contains all implementation to work
+// with wallet data in storage.DB.
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage"
+	"wallet/app/wallet"
+)
+
+// Storage works with wallets in storage.DB.
+type Storage struct {
+	db storage.DB
+}
+
+// NewStorage is a constructor for storage.
+func NewStorage(db storage.DB) *Storage {
+	return &Storage{
+		db: db,
+	}
+}
+
+// Wallets returns all data from the storage.
+func (s *Storage) Wallets(ctx context.Context) ([]wallet.Wallet, error) {
+	var wallets []wallet.Wallet
+
+	err := s.db.View(func(tx storage.Tx) error {
+		data, err := tx.Wallets()
+		if err != nil || len(data) == 0 {
+			return err
+		}
+
+		wallets = make([]wallet.Wallet, 0, len(data))
+		for k, v := range data {
+			balance, err := tx.Balance(storage.WalletAccount(k, v.Currency))
+			if err != nil {
+				return err
+			}
+
+			wallets = append(wallets, wallet.Wallet{
+				ID:              k,
+				Name:            v.Name,
+				Status:          v.Status,
+				Currency:        v.Currency,
+				Balance:         balance,
+				Available:       balance - v.Held,
+				CreditLimit:     v.CreditLimit,
+				AvailableCredit: v.Credit(balance),
+			})
+		}
+
+		return nil
+	})
+
+	return wallets, err
+}
+
+// Wallet finds one record in the storage and returns it to the service.
+func (s *Storage) Wallet(ctx context.Context, id string) (wallet.Wallet, error) {
+	var wal wallet.Wallet
+
+	err := s.db.View(func(tx storage.Tx) error {
+		data, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return oops.ErrNotFound
+		}
+
+		balance, err := tx.Balance(storage.WalletAccount(id, data.Currency))
+		if err != nil {
+			return err
+		}
+
+		wal = wallet.Wallet{
+			ID:              id,
+			Name:            data.Name,
+			Currency:        data.Currency,
+			Balance:         balance,
+			Available:       balance - data.Held,
+			CreditLimit:     data.CreditLimit,
+			AvailableCredit: data.Credit(balance),
+			Status:          data.Status,
+		}
+
+		return nil
+	})
+
+	return wal, err
+}
+
+// WalletAt finds the wallet as it was at the instant: the balance is
+// taken from the last transaction appended by it and the name and status
+// from the wallet revisions, the events of a wallet without revisions
+// are replayed instead. Holds aren't kept by instant, so the available
+// balance is left out.
+func (s *Storage) WalletAt(ctx context.Context, id string, at time.Time) (wallet.Wallet, error) {
+	var wal wallet.Wallet
+
+	err := s.db.View(func(tx storage.Tx) error {
+		data, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return oops.ErrNotFound
+		}
+
+		if len(data.Revisions) > 0 {
+			r, found := data.RevisionAt(at)
+			if !found {
+				return oops.ErrNotFound
+			}
+			data.Name, data.Status = r.Name, r.Status
+		} else {
+			events, err := tx.Stream(id)
+			if err != nil {
+				return err
+			}
+			if len(events) > 0 {
+				if data, found, err = walletAt(data, events, at); err != nil {
+					return err
+				}
+				if !found {
+					return oops.ErrNotFound
+				}
+			}
+		}
+
+		t, found, err := tx.HistoryBefore(id, at.Add(time.Nanosecond))
+		if err != nil {
+			return err
+		}
+
+		var balance money.Amount
+		if found {
+			balance = t.BalanceAfter
+		}
+
+		wal = wallet.Wallet{
+			ID:       id,
+			Name:     data.Name,
+			Currency: data.Currency,
+			Balance:  balance,
+			Status:   data.Status,
+		}
+
+		return nil
+	})
+
+	return wal, err
+}
+
+// walletAt replays the wallet events recorded by the instant,
+// found is false if the wallet wasn't created yet. A stream which
+// doesn't start with the creation is replayed over the current data.
+func walletAt(current storage.Wallet, events []storage.Event, at time.Time) (storage.Wallet, bool, error) {
+	var (
+		w     storage.Wallet
+		found bool
+	)
+	if events[0].Name != event.WalletCreated {
+		w, found = current, true
+	}
+
+	for _, e := range events {
+		if e.CreatedAt.After(at) {
+			break
+		}
+
+		switch e.Name {
+		case event.WalletCreated:
+			var p event.Created
+			if err := json.Unmarshal(e.Payload, &p); err != nil {
+				return storage.Wallet{}, false, fmt.Errorf("event %s decode error: %w", e.ID, err)
+			}
+			w = storage.Wallet{Name: p.Name, Status: "active", Currency: p.Currency}
+			found = true
+		case event.WalletRenamed:
+			var p event.Renamed
+			if err := json.Unmarshal(e.Payload, &p); err != nil {
+				return storage.Wallet{}, false, fmt.Errorf("event %s decode error: %w", e.ID, err)
+			}
+			w.Name = p.Name
+		case event.WalletDeleted:
+			w.Status = "inactive"
+		}
+	}
+
+	return w, found, nil
+}
+
+// idAttempts is how many ids CreateWallet generates
+// before it gives up on finding an unused one.
+const idAttempts = 10
+
+// newID generates a wallet id with length 8.
+var newID = func() string {
+	return generateID(8)
+}
+
+// CreateWallet generates an unused id and stores new wallet, the id of
+// a deleted wallet isn't reused.
+func (s *Storage) CreateWallet(ctx context.Context, req wallet.Request) (wallet.Wallet, error) {
+	var id string
+
+	err := s.db.Update(func(tx storage.Tx) error {
+		for i := 0; ; i++ {
+			if i == idAttempts {
+				return fmt.Errorf("wallet id error: no unused id in %d attempts", idAttempts)
+			}
+
+			id = newID()
+			_, found, err := tx.Wallet(id)
+			if err != nil {
+				return err
+			}
+			if !found {
+				break
+			}
+		}
+
+		return create(ctx, tx, id, req)
+	})
+	if err != nil {
+		return wallet.Wallet{}, err
+	}
+
+	return wallet.Wallet{
+		ID:       id,
+		Name:     req.Name,
+		Status:   "active",
+		Currency: money.Currency(req.Currency),
+	}, nil
+}
+
+// OpenWallet creates the wallet with the id unless it exists.
+func (s *Storage) OpenWallet(ctx context.Context, id string, req wallet.Request) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		w, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return create(ctx, tx, id, req)
+		}
+
+		if w.Status == "inactive" {
+			return fmt.Errorf("wallet %s is deleted", id)
+		}
+		if w.Currency != money.Currency(req.Currency) {
+			return fmt.Errorf("wallet %s is in %s: %w", id, w.Currency, oops.ErrCurrencyMismatch)
+		}
+		return nil
+	})
+}
+
+// create stores a new wallet with the id.
+func create(ctx context.Context, tx storage.Tx, id string, req wallet.Request) error {
+	err := tx.PutWallet(id, storage.Wallet{
+		Name:     req.Name,
+		Status:   "active",
+		Currency: money.Currency(req.Currency),
+		Revisions: []storage.Revision{{
+			Name:   req.Name,
+			Status: "active",
+			Time:   time.Now().UTC(),
+		}},
+	})
+	if err != nil {
+		return err
+	}
+
+	return event.Record(ctx, tx, id, event.WalletCreated, event.Created{
+		WalletID: id,
+		Name:     req.Name,
+		Currency: money.Currency(req.Currency),
+	})
+}
+
+// UpdateWallet updates name of the wallet.
+func (s *Storage) UpdateWallet(ctx context.Context, req wallet.Request, id string) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		wal, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found || wal.Status == "inactive" {
+			return oops.ErrNotFound
+		}
+
+		wal.Name = req.Name
+		revise(&wal)
+		if err = tx.PutWallet(id, wal); err != nil {
+			return err
+		}
+
+		return event.Record(ctx, tx, id, event.WalletRenamed, event.Renamed{
+			WalletID: id,
+			Name:     req.Name,
+		})
+	})
+}
+
+// DeleteWallet marks wallet as inactive, its active holds are released.
+func (s *Storage) DeleteWallet(ctx context.Context, id string) error {
+	return s.db.Update(func(tx storage.Tx) error {
+		wal, found, err := tx.Wallet(id)
+		if err != nil {
+			return err
+		}
+		if !found || wal.Status == "inactive" {
+			return oops.ErrNotFound
+		}
+
+		if err = releaseHolds(ctx, tx, id); err != nil {
+			return err
+		}
+
+		wal.Status, wal.Held = "inactive", 0
+		revise(&wal)
+		if err = tx.PutWallet(id, wal); err != nil {
+			return err
+		}
+
+		return event.Record(ctx, tx, id, event.WalletDeleted, event.Deleted{
+			WalletID: id,
+		})
+	})
+}
+
+// revise records the current name and status of the wallet, a wallet
+// created before the revisions were kept is left to its events.
+func revise(w *storage.Wallet) {
+	if len(w.Revisions) == 0 {
+		return
+	}
+
+	w.Revisions = storage.Revise(w.Revisions, storage.Revision{
+		Name:   w.Name,
+		Status: w.Status,
+		Time:   time.Now().UTC(),
+	})
+}
+
+// releaseHolds releases the active holds of a wallet, so
+// no hold of it is closed after the wallet is deleted.
+func releaseHolds(ctx context.Context, tx storage.Tx, id string) error {
+	holds, err := tx.Holds(id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, h := range holds {
+		if h.Status != storage.HoldActive {
+			continue
+		}
+
+		h.Status, h.ClosedAt = storage.HoldReleased, now
+		if err = tx.PutHold(h); err != nil {
+			return err
+		}
+
+		err = event.Record(ctx, tx, id, event.WalletHoldReleased, event.HoldReleased{
+			WalletID: id,
+			HoldID:   h.ID,
+			Amount:   h.Amount,
+			Currency: h.Currency,
+			Reason:   h.Status,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func generateID(n int) string {
+	const chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	b := make([]byte, n)
+	for i := range b {
+		// the global source is seeded randomly since go 1.20.
+		b[i] = chars[rand.Intn(len(chars))]
+	}
+
+	return string(b)
+}
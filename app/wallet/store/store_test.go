@@ -0,0 +1,279 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wallet/app/event"
+	"wallet/app/money"
+	"wallet/app/oops"
+	"wallet/app/storage"
+	"wallet/app/storage/bolt"
+	"wallet/app/wallet"
+)
+
+// forEachDB runs the test against the memory and the bolt storage.
+func forEachDB(t *testing.T, test func(*testing.T, storage.DB)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, storage.NewMemory())
+	})
+	t.Run("bolt", func(t *testing.T) {
+		db, err := bolt.Open(filepath.Join(t.TempDir(), "wallet.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		test(t, db)
+	})
+}
+
+// withIDs makes newID return the ids in order for the test.
+func withIDs(t *testing.T, ids ...string) {
+	prev := newID
+	t.Cleanup(func() { newID = prev })
+
+	newID = func() string {
+		id := ids[0]
+		if len(ids) > 1 {
+			ids = ids[1:]
+		}
+		return id
+	}
+}
+
+func TestCreateWalletRegeneratesUsedID(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		s := NewStorage(db)
+		ctx := context.Background()
+
+		withIDs(t, "a", "a", "b")
+		first, err := s.CreateWallet(ctx, wallet.Request{Name: "first", Currency: "USD"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.DeleteWallet(ctx, first.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		second, err := s.CreateWallet(ctx, wallet.Request{Name: "second", Currency: "EUR"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if second.ID != "b" {
+			t.Fatalf("second id = %q, want b", second.ID)
+		}
+
+		// the deleted wallet is kept as it was.
+		err = db.View(func(tx storage.Tx) error {
+			w, found, err := tx.Wallet("a")
+			if err != nil {
+				return err
+			}
+			if !found || w.Name != "first" || w.Status != "inactive" {
+				t.Errorf("wallet a = %+v, %v", w, found)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestCreateWalletGivesUp(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		s := NewStorage(db)
+		ctx := context.Background()
+
+		withIDs(t, "a")
+		if _, err := s.CreateWallet(ctx, wallet.Request{Name: "first", Currency: "USD"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.CreateWallet(ctx, wallet.Request{Name: "second", Currency: "USD"}); err == nil {
+			t.Fatal("wallet created with a used id")
+		}
+
+		w, err := s.Wallet(ctx, "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.Name != "first" {
+			t.Fatalf("wallet a name = %q, want first", w.Name)
+		}
+	})
+}
+
+func TestWalletLifecycle(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		s := NewStorage(db)
+		ctx := context.Background()
+
+		w, err := s.CreateWallet(ctx, wallet.Request{Name: "old", Currency: "USD"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.UpdateWallet(ctx, wallet.Request{Name: "new"}, w.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.Wallet(ctx, w.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "new" || got.Currency != "USD" || got.Status != "active" {
+			t.Fatalf("wallet = %+v", got)
+		}
+
+		if err = s.DeleteWallet(ctx, w.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err = s.UpdateWallet(ctx, wallet.Request{Name: "x"}, w.ID); !errors.Is(err, oops.ErrNotFound) {
+			t.Fatalf("update of a deleted wallet error = %v, want %v", err, oops.ErrNotFound)
+		}
+
+		// the revisions keep the changes for WalletAt.
+		if got, err = s.WalletAt(ctx, w.ID, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "new" || got.Status != "inactive" {
+			t.Errorf("wallet now = %+v, want the deleted wallet", got)
+		}
+		if _, err = s.WalletAt(ctx, w.ID, time.Now().Add(-time.Hour)); !errors.Is(err, oops.ErrNotFound) {
+			t.Errorf("wallet an hour ago error = %v, want %v", err, oops.ErrNotFound)
+		}
+	})
+}
+
+func TestWalletAt(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		s := NewStorage(db)
+		day := time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)
+		at := func(hour, min int) time.Time {
+			return day.Add(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute)
+		}
+
+		err := db.Update(func(tx storage.Tx) error {
+			var revisions []storage.Revision
+			for _, r := range []storage.Revision{
+				{Name: "old", Status: "active", Time: at(10, 0)},
+				{Name: "new", Status: "active", Time: at(11, 0)},
+				// the clock went back, so the deletion is kept at 11:00.
+				{Name: "new", Status: "inactive", Time: at(10, 50)},
+			} {
+				revisions = storage.Revise(revisions, r)
+			}
+			if err := tx.PutWallet("w1", storage.Wallet{Currency: "USD", Revisions: revisions}); err != nil {
+				return err
+			}
+
+			// the last transaction is appended after the clock went back.
+			for _, tr := range []storage.Transaction{
+				{Amount: 100, BalanceAfter: 100, CreatedAt: at(10, 30)},
+				{Amount: 200, BalanceAfter: 300, CreatedAt: at(11, 30)},
+				{Amount: -50, BalanceAfter: 250, CreatedAt: at(10, 45)},
+			} {
+				tr.WalletID, tr.Type, tr.Currency = "w1", storage.TxDeposit, "USD"
+				if _, err := tx.AppendTransaction(tr); err != nil {
+					return err
+				}
+			}
+
+			// w2 was created before the revisions were kept.
+			if err := tx.PutWallet("w2", storage.Wallet{Name: "new", Status: "active", Currency: "USD"}); err != nil {
+				return err
+			}
+			for _, e := range []struct {
+				name    string
+				payload any
+				at      time.Time
+			}{
+				{event.WalletCreated, event.Created{WalletID: "w2", Name: "old", Currency: "USD"}, at(10, 0)},
+				{event.WalletRenamed, event.Renamed{WalletID: "w2", Name: "new"}, at(11, 0)},
+			} {
+				data, err := json.Marshal(e.payload)
+				if err != nil {
+					return err
+				}
+				_, err = tx.AppendEvent(storage.Event{AggregateID: "w2", Name: e.name, Payload: data, CreatedAt: e.at})
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			id      string
+			at      time.Time
+			err     error
+			name    string
+			status  string
+			balance money.Amount
+		}{
+			{id: "w1", at: at(9, 0), err: oops.ErrNotFound},
+			{id: "w1", at: at(10, 40), name: "old", status: "active", balance: 100},
+			{id: "w1", at: at(10, 50), name: "old", status: "active", balance: 100},
+			{id: "w1", at: at(11, 0), name: "new", status: "inactive", balance: 100},
+			{id: "w1", at: at(11, 30), name: "new", status: "inactive", balance: 250},
+			{id: "w2", at: at(9, 0), err: oops.ErrNotFound},
+			{id: "w2", at: at(10, 30), name: "old", status: "active"},
+			{id: "w2", at: at(11, 0), name: "new", status: "active"},
+		}
+		for _, tt := range tests {
+			got, err := s.WalletAt(ctx, tt.id, tt.at)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("%s at %s error = %v, want %v", tt.id, tt.at.Format("15:04"), err, tt.err)
+				continue
+			}
+			if tt.err != nil {
+				continue
+			}
+			if got.Name != tt.name || got.Status != tt.status || got.Balance != tt.balance {
+				t.Errorf("%s at %s = %s %s %s, want %s %s %s", tt.id, tt.at.Format("15:04"),
+					got.Name, got.Status, got.Balance, tt.name, tt.status, tt.balance)
+			}
+		}
+	})
+}
+
+func TestOpenWallet(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db storage.DB) {
+		ctx := context.Background()
+		s := NewStorage(db)
+		req := wallet.Request{Name: "house fees USD", Currency: "USD"}
+
+		// the second open finds the wallet created by the first one.
+		for i := 0; i < 2; i++ {
+			if err := s.OpenWallet(ctx, "fees-usd", req); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w, err := s.Wallet(ctx, "fees-usd")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if w.Currency != "USD" || w.Status != "active" {
+			t.Errorf("wallet = %+v, want an active USD wallet", w)
+		}
+
+		err = s.OpenWallet(ctx, "fees-usd", wallet.Request{Name: "house fees EUR", Currency: "EUR"})
+		if !errors.Is(err, oops.ErrCurrencyMismatch) {
+			t.Errorf("open in another currency error = %v, want %v", err, oops.ErrCurrencyMismatch)
+		}
+
+		if err = s.DeleteWallet(ctx, "fees-usd"); err != nil {
+			t.Fatal(err)
+		}
+		if err = s.OpenWallet(ctx, "fees-usd", req); err == nil {
+			t.Error("open of a deleted wallet succeeded")
+		}
+	})
+}
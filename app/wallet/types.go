@@ -1,25 +1,45 @@
 package wallet
 
-import "context"
+import (
+	"context"
+	"time"
 
-// Wallet contains all fields to define wallet.
+	"wallet/app/money"
+)
+
+// Wallet contains all fields to define wallet. Balance is the current
+// balance and Available is the part of it not reserved by holds. The
+// balance may go down to minus CreditLimit and AvailableCredit is the
+// part of the limit not used yet.
 type Wallet struct {
-	ID      string  `json:"id,omitempty"`
-	Name    string  `json:"name,omitempty"`
-	Balance float64 `json:"balance,omitempty"`
-	Status  string  `json:"status,omitempty"`
+	ID              string         `json:"id,omitempty"`
+	Name            string         `json:"name,omitempty"`
+	Currency        money.Currency `json:"currency,omitempty"`
+	Balance         money.Amount   `json:"balance,omitempty"`
+	Available       money.Amount   `json:"available,omitempty"`
+	CreditLimit     money.Amount   `json:"credit_limit,omitempty"`
+	AvailableCredit money.Amount   `json:"available_credit,omitempty"`
+	Status          string         `json:"status,omitempty"`
 }
 
 // Request contains fields for client request.
+// Currency is required on create and ignored on update.
 type Request struct {
-	Name string `json:"name" validate:"required,gte=1"`
+	Name     string `json:"name" validate:"required,gte=1"`
+	Currency string `json:"currency,omitempty"`
 }
 
 // Store contains all methods to store data into the storage.
 type Store interface {
 	Wallets(context.Context) ([]Wallet, error)
 	Wallet(context.Context, string) (Wallet, error)
+	// WalletAt returns the wallet as it was at the instant,
+	// oops.ErrNotFound if it didn't exist yet.
+	WalletAt(ctx context.Context, id string, at time.Time) (Wallet, error)
 	CreateWallet(context.Context, Request) (Wallet, error)
+	// OpenWallet creates the wallet with the id unless it exists,
+	// an existing wallet must be active and in the currency.
+	OpenWallet(ctx context.Context, id string, req Request) error
 	UpdateWallet(context.Context, Request, string) error
 	DeleteWallet(context.Context, string) error
 }
@@ -28,6 +48,7 @@ type Store interface {
 type Service interface {
 	List(context.Context) ([]Wallet, error)
 	Item(context.Context, string) (Wallet, error)
+	ItemAt(ctx context.Context, id string, at time.Time) (Wallet, error)
 	Create(context.Context, Request) (Wallet, error)
 	Update(context.Context, Request, string) error
 	Delete(context.Context, string) error
@@ -6,6 +6,7 @@ require (
 	github.com/go-chi/chi/v5 v5.0.8
 	github.com/go-playground/validator/v10 v10.11.2
 	github.com/nsqio/go-nsq v1.1.0
+	go.etcd.io/bbolt v1.3.7
 	golang.org/x/sync v0.1.0
 )
 